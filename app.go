@@ -2,7 +2,9 @@ package marten
 
 import (
 	"context"
+	"log/slog"
 	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
 	"strings"
@@ -14,25 +16,37 @@ import (
 // App is the core of Marten.
 type App struct {
 	*Router
-	pool       sync.Pool
-	onError    func(*Ctx, error)
-	onStart    []func()
-	onShutdown []func()
+	pool          sync.Pool
+	onError       func(*Ctx, error)
+	onStart       []func()
+	onShutdown    []func()
+	errorMappings []errorMapping
+
+	problemDetails  bool
+	formats         []format
+	jsonCodec       JSONCodec
+	jsonConfig      JSONConfig
+	renderer        Renderer
+	multipartConfig MultipartConfig
+	keyring         *Keyring
+	trustedProxies  []netip.Prefix
+	logger          *slog.Logger
+
+	wsMu       sync.Mutex
+	websockets map[*WebSocket]struct{}
 }
 
 // New creates a new Marten application.
 func New() *App {
 	app := &App{
-		Router: NewRouter(),
-		onError: func(c *Ctx, err error) {
-			if !c.written {
-				_ = c.Text(http.StatusInternalServerError, "Internal Server Error")
-			}
-		},
+		Router:  NewRouter(),
+		formats: defaultFormats(),
 	}
+	app.onError = app.defaultErrorHandler
 	app.pool = sync.Pool{
 		New: func() any {
 			return &Ctx{
+				app:    app,
 				params: make(map[string]string),
 				store:  make(map[string]any),
 			}
@@ -41,7 +55,17 @@ func New() *App {
 	return app
 }
 
+// NewCtx returns a Ctx bound to a for w and r, outside the request pool, so
+// the app's settings apply to it as in ServeHTTP. It is meant for tests;
+// martentest.NewCtxFor wraps it.
+func (a *App) NewCtx(w http.ResponseWriter, r *http.Request) *Ctx {
+	c := a.pool.New().(*Ctx)
+	c.Reset(w, r)
+	return c
+}
+
 // OnError sets a custom error handler.
+// Use ResolveError inside fn to apply the app's error mappings.
 func (a *App) OnError(fn func(*Ctx, error)) {
 	a.onError = fn
 }
@@ -65,9 +89,10 @@ func (a *App) SetTrailingSlash(mode TrailingSlashMode) {
 func (a *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	c := a.pool.Get().(*Ctx)
 	c.Reset(w, r)
-	defer a.pool.Put(c)
+	defer a.release(c)
 
-	handler, routeMw, allowed, redirect := a.lookupWithTrailingSlash(r.Method, r.URL.Path, c.params)
+	handler, routeMw, allowed, pattern, redirect := a.lookupWithTrailingSlash(r.Method, r.URL.Path, c.params)
+	c.route = pattern
 
 	// Handle trailing slash redirect
 	if redirect != "" {
@@ -81,6 +106,9 @@ func (a *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			// Path exists but method not allowed
 			w.Header().Set("Allow", strings.Join(allowed, ", "))
 			handler = func(c *Ctx) error {
+				if c.ProblemDetails() {
+					return c.Problem(NewProblem(http.StatusMethodNotAllowed, ""))
+				}
 				return c.Text(http.StatusMethodNotAllowed, "Method Not Allowed")
 			}
 		} else {
@@ -150,7 +178,11 @@ func (a *App) RunGraceful(addr string, timeout time.Duration) error {
 
 		ctx, cancel := context.WithTimeout(context.Background(), timeout)
 		defer cancel()
-		return server.Shutdown(ctx)
+		if err := server.Shutdown(ctx); err != nil {
+			return err
+		}
+		// Hijacked WebSocket connections are not tracked by http.Server
+		return a.CloseWebSockets(ctx)
 	}
 	return nil
 }
@@ -0,0 +1,399 @@
+package marten
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Bind sources, in the order they are applied. Later sources override earlier ones.
+const (
+	sourceForm   = "form"
+	sourceParam  = "param"
+	sourceQuery  = "query"
+	sourceHeader = "header"
+	sourceCookie = "cookie"
+)
+
+var tagSources = []string{sourceParam, sourceQuery, sourceHeader, sourceCookie}
+
+// Bind fills v from the request. The body is decoded based on Content-Type
+// (application/json, application/xml, application/x-www-form-urlencoded,
+// multipart/form-data);
+// then struct fields tagged with param, query, header or cookie are set from
+// the path, query string, headers and cookies. Fields tagged default:"..."
+// that are still zero receive the default. Strings are converted to ints,
+// floats, bools, time.Time, time.Duration, slices, pointers and any
+// encoding.TextUnmarshaler; conversion failures are reported per field in
+// BindError.Fields.
+//
+//	type ListUsers struct {
+//		Org    string `param:"org"`
+//		Page   int    `query:"page" default:"1"`
+//		Tenant string `header:"X-Tenant"`
+//		Name   string `json:"name"`
+//	}
+func (c *Ctx) Bind(v any) error {
+	c.checkLive()
+	return c.bind(v, false)
+}
+
+// bind implements Bind. An empty body is allowed when optionalBody is set or
+// v has fields bound from other sources.
+func (c *Ctx) bind(v any, optionalBody bool) error {
+	fields := structFields(v)
+	hasTagged := optionalBody
+	for _, f := range fields {
+		if f.tagged() {
+			hasTagged = true
+			break
+		}
+	}
+
+	if err := c.bindBody(v, fields, hasTagged); err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	rv := reflect.ValueOf(v).Elem()
+	var errs []FieldError
+	for _, f := range fields {
+		for _, src := range tagSources {
+			key := f.keys[src]
+			if key == "" {
+				continue
+			}
+			values, ok := c.sourceValues(src, key)
+			if !ok {
+				continue
+			}
+			if err := setValues(rv.FieldByIndex(f.index), values); err != nil {
+				errs = append(errs, FieldError{Field: f.path, Source: src, Key: key, Value: strings.Join(values, ","), Err: err})
+			}
+		}
+		if f.hasDefault {
+			fv := rv.FieldByIndex(f.index)
+			if fv.IsZero() {
+				if err := setValues(fv, []string{f.def}); err != nil {
+					errs = append(errs, FieldError{Field: f.path, Source: "default", Value: f.def, Err: err})
+				}
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return newFieldBindError(errs)
+	}
+	return nil
+}
+
+// bindBody decodes the request body into v. An empty body is an error
+// unless v has fields bound from other sources.
+func (c *Ctx) bindBody(v any, fields []fieldInfo, optional bool) error {
+	if c.Request.Body == nil || c.Request.Body == http.NoBody {
+		if optional {
+			return nil
+		}
+		return &BindError{Message: "empty request body"}
+	}
+
+	contentType := c.Request.Header.Get("Content-Type")
+
+	switch {
+	case strings.HasPrefix(contentType, "application/x-www-form-urlencoded"):
+		if err := c.Request.ParseForm(); err != nil {
+			return &BindError{Message: "invalid form data: " + err.Error()}
+		}
+		return bindForm(c.Request.Form, nil, v, fields)
+
+	case strings.HasPrefix(contentType, "multipart/form-data"):
+		form, err := c.MultipartForm()
+		if err != nil {
+			return err
+		}
+		return bindForm(c.Request.Form, form.File, v, fields)
+
+	case strings.HasPrefix(contentType, MIMEXML), strings.HasPrefix(contentType, "text/xml"):
+		if err := xml.NewDecoder(c.Request.Body).Decode(v); err != nil {
+			if err == io.EOF {
+				if optional {
+					return nil
+				}
+				return &BindError{Message: "empty request body"}
+			}
+			return &BindError{Message: "invalid XML: " + err.Error()}
+		}
+		return nil
+
+	default:
+		// JSON, also the default for backwards compatibility
+		data, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return &BindError{Message: "failed to read request body: " + err.Error()}
+		}
+		if len(bytes.TrimSpace(data)) == 0 {
+			if optional {
+				return nil
+			}
+			return &BindError{Message: "empty request body"}
+		}
+		return c.decodeJSON(data, v)
+	}
+}
+
+// sourceValues returns the raw values for key from a bind source.
+func (c *Ctx) sourceValues(src, key string) ([]string, bool) {
+	switch src {
+	case sourceParam:
+		v, ok := c.params[key]
+		return []string{v}, ok
+	case sourceQuery:
+		if c.Request.URL == nil {
+			return nil, false
+		}
+		v, ok := c.Request.URL.Query()[key]
+		return v, ok
+	case sourceHeader:
+		v, ok := c.Request.Header[http.CanonicalHeaderKey(key)]
+		return v, ok
+	case sourceCookie:
+		ck, err := c.Request.Cookie(key)
+		if err != nil {
+			return nil, false
+		}
+		return []string{ck.Value}, true
+	}
+	return nil, false
+}
+
+// bindForm binds form values (and uploaded files) to v. Struct fields use the
+// form tag, falling back to the json tag name and then the field name.
+// Other targets, such as maps, are filled through a JSON round-trip.
+func bindForm(form url.Values, files map[string][]*multipart.FileHeader, v any, fields []fieldInfo) error {
+	if fields == nil {
+		return bindFormJSON(form, v)
+	}
+
+	rv := reflect.ValueOf(v).Elem()
+	var errs []FieldError
+	for _, f := range fields {
+		key := f.keys[sourceForm]
+		if key == "" {
+			continue
+		}
+		fv := rv.FieldByIndex(f.index)
+		if fh, ok := files[key]; ok && setFiles(fv, fh) {
+			continue
+		}
+		values, ok := form[key]
+		if !ok {
+			continue
+		}
+		if err := setValues(fv, values); err != nil {
+			errs = append(errs, FieldError{Field: f.path, Source: sourceForm, Key: key, Value: strings.Join(values, ","), Err: err})
+		}
+	}
+	if len(errs) > 0 {
+		return newFieldBindError(errs)
+	}
+	return nil
+}
+
+var (
+	fileHeaderType  = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeadersType = reflect.TypeOf([]*multipart.FileHeader(nil))
+)
+
+// setFiles assigns uploaded files to *multipart.FileHeader or []*multipart.FileHeader fields.
+func setFiles(fv reflect.Value, files []*multipart.FileHeader) bool {
+	switch fv.Type() {
+	case fileHeaderType:
+		fv.Set(reflect.ValueOf(files[0]))
+		return true
+	case fileHeadersType:
+		fv.Set(reflect.ValueOf(files))
+		return true
+	}
+	return false
+}
+
+// bindFormJSON binds form values to non-struct targets using JSON.
+func bindFormJSON(form url.Values, v any) error {
+	data := make(map[string]any)
+	for key, values := range form {
+		if len(values) == 1 {
+			data[key] = values[0]
+		} else {
+			data[key] = values
+		}
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return &BindError{Message: "form binding error: " + err.Error()}
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return &BindError{Message: "form binding error: " + err.Error()}
+	}
+	return nil
+}
+
+// BindValid binds the request, checks v's validate tags (see Validate),
+// and then runs the optional validate function.
+func (c *Ctx) BindValid(v any, validate func() error) error {
+	if err := c.Bind(v); err != nil {
+		return err
+	}
+	if err := Validate(v); err != nil {
+		return err
+	}
+	if validate != nil {
+		return validate()
+	}
+	return nil
+}
+
+// BindError represents a binding error.
+type BindError struct {
+	Message string
+	// Fields lists values that could not be converted into struct fields.
+	Fields []FieldError
+	// Line, Column and Offset locate a JSON syntax or type error in the body
+	// (1-based line and column, byte offset). They are zero otherwise.
+	Line   int
+	Column int
+	Offset int64
+}
+
+func (e *BindError) Error() string {
+	return e.Message
+}
+
+// FieldError describes a request value that could not be bound to a field.
+type FieldError struct {
+	Field  string // struct field path, e.g. "Filter.Page"
+	Source string // "param", "query", "header", "cookie", "form" or "default"
+	Key    string // name in the source, e.g. "page"
+	Value  string
+	Err    error
+}
+
+func (e FieldError) Error() string {
+	if e.Source == "default" {
+		return fmt.Sprintf("default for %s %q: %v", e.Field, e.Value, e.Err)
+	}
+	return fmt.Sprintf("%s %q: cannot use %q: %v", e.Source, e.Key, e.Value, e.Err)
+}
+
+func (e FieldError) Unwrap() error {
+	return e.Err
+}
+
+func newFieldBindError(errs []FieldError) *BindError {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Error()
+	}
+	return &BindError{Message: "invalid " + strings.Join(msgs, "; "), Fields: errs}
+}
+
+// fieldInfo describes a bindable struct field.
+type fieldInfo struct {
+	index      []int
+	path       string
+	keys       map[string]string // source -> key
+	def        string
+	hasDefault bool
+}
+
+// tagged reports whether the field is bound from a non-body source.
+func (f fieldInfo) tagged() bool {
+	for _, src := range tagSources {
+		if f.keys[src] != "" {
+			return true
+		}
+	}
+	return false
+}
+
+var fieldCache sync.Map // reflect.Type -> []fieldInfo
+
+// structFields returns the bindable fields of *struct targets (nil otherwise).
+func structFields(v any) []fieldInfo {
+	t := reflect.TypeOf(v)
+	if t == nil || t.Kind() != reflect.Pointer || t.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	t = t.Elem()
+	if cached, ok := fieldCache.Load(t); ok {
+		return cached.([]fieldInfo)
+	}
+	fields := collectFields(t, nil, "")
+	if fields == nil {
+		fields = []fieldInfo{}
+	}
+	fieldCache.Store(t, fields)
+	return fields
+}
+
+func collectFields(t reflect.Type, index []int, prefix string) []fieldInfo {
+	var fields []fieldInfo
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() && !sf.Anonymous {
+			continue
+		}
+		idx := append(append([]int(nil), index...), i)
+		path := prefix + sf.Name
+
+		keys := make(map[string]string)
+		for _, src := range tagSources {
+			if key, ok := sf.Tag.Lookup(src); ok && key != "-" {
+				keys[src] = key
+			}
+		}
+		formKey, hasForm := sf.Tag.Lookup(sourceForm)
+		jsonName, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		def, hasDefault := sf.Tag.Lookup("default")
+
+		// Recurse into untagged structs (embedded or nested), except leaf types
+		if sf.Type.Kind() == reflect.Struct && len(keys) == 0 && !hasForm && !hasDefault && !isLeafType(sf.Type) {
+			nested := prefix
+			if !sf.Anonymous {
+				nested = path + "."
+			}
+			fields = append(fields, collectFields(sf.Type, idx, nested)...)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		switch {
+		case hasForm && formKey != "-":
+			keys[sourceForm] = formKey
+		case hasForm:
+		case jsonName == "-":
+		case jsonName != "":
+			keys[sourceForm] = jsonName
+		default:
+			keys[sourceForm] = sf.Name
+		}
+
+		fields = append(fields, fieldInfo{index: idx, path: path, keys: keys, def: def, hasDefault: hasDefault})
+	}
+	return fields
+}
+
+// isLeafType reports whether struct type t is converted as a single value.
+func isLeafType(t reflect.Type) bool {
+	return t == timeType || reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
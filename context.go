@@ -4,45 +4,61 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
-	"encoding/json"
 	"io"
+	"log/slog"
+	"maps"
 	"mime/multipart"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync/atomic"
 )
 
 // Ctx wraps a request with helpers for clean handler code.
 type Ctx struct {
+	app        *App
 	Request    *http.Request
 	Writer     http.ResponseWriter
 	params     map[string]string
 	store      map[string]any
+	values     map[any]any
+	valuesCtx  *valuesContext
+	cleanup    *cleanup
+	formErr    error
+	resp       ResponseWriter
 	written    bool
 	statusCode int
 	requestID  string
+	route      string
+	logger     *slog.Logger
+	copied     bool
+	released   atomic.Bool
 }
 
 // Param returns a path parameter by name.
 func (c *Ctx) Param(name string) string {
+	c.checkLive()
 	return c.params[name]
 }
 
 // ParamInt returns a path parameter as int (0 if invalid).
 func (c *Ctx) ParamInt(name string) int {
+	c.checkLive()
 	v, _ := strconv.Atoi(c.params[name])
 	return v
 }
 
 // ParamInt64 returns a path parameter as int64 (0 if invalid).
 func (c *Ctx) ParamInt64(name string) int64 {
+	c.checkLive()
 	v, _ := strconv.ParseInt(c.params[name], 10, 64)
 	return v
 }
 
 // Query returns a query parameter by name.
 func (c *Ctx) Query(name string) string {
+	c.checkLive()
 	if c.Request.URL == nil {
 		return ""
 	}
@@ -77,6 +93,7 @@ func (c *Ctx) QueryDefault(name, def string) string {
 
 // QueryValues returns all values for a query parameter.
 func (c *Ctx) QueryValues(name string) []string {
+	c.checkLive()
 	if c.Request.URL == nil {
 		return nil
 	}
@@ -85,7 +102,8 @@ func (c *Ctx) QueryValues(name string) []string {
 
 // Status sets the response status code.
 func (c *Ctx) Status(code int) *Ctx {
-	if !c.written {
+	c.checkLive()
+	if !c.sent() {
 		c.Writer.WriteHeader(code)
 		c.written = true
 		c.statusCode = code
@@ -94,13 +112,33 @@ func (c *Ctx) Status(code int) *Ctx {
 }
 
 // StatusCode returns the response status code (0 if not yet written).
+// It reflects the status actually sent, including responses written to
+// c.Writer directly by middleware or an http.Handler.
 func (c *Ctx) StatusCode() int {
+	c.checkLive()
+	if c.resp.wroteHeader {
+		return c.resp.status
+	}
 	return c.statusCode
 }
 
+// Response returns the ResponseWriter that records the status and size of
+// the response and runs BeforeWrite and AfterWrite hooks.
+func (c *Ctx) Response() *ResponseWriter {
+	c.checkLive()
+	return &c.resp
+}
+
+// sent reports whether the response headers were sent, either by a helper
+// or directly through the writer.
+func (c *Ctx) sent() bool {
+	return c.written || c.resp.wroteHeader || c.resp.hijacked
+}
+
 // Text writes a plain text response.
 func (c *Ctx) Text(code int, text string) error {
-	if !c.written {
+	c.checkLive()
+	if !c.sent() {
 		c.Writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		c.Writer.WriteHeader(code)
 		c.written = true
@@ -110,15 +148,12 @@ func (c *Ctx) Text(code int, text string) error {
 	return err
 }
 
-// JSON writes a JSON response.
+// JSON writes a JSON response using the app's JSON codec and config.
+// The value is encoded before anything is written, so an encoding error
+// leaves the response untouched.
 func (c *Ctx) JSON(code int, v any) error {
-	if !c.written {
-		c.Writer.Header().Set("Content-Type", "application/json; charset=utf-8")
-		c.Writer.WriteHeader(code)
-		c.written = true
-		c.statusCode = code
-	}
-	return json.NewEncoder(c.Writer).Encode(v)
+	c.checkLive()
+	return c.writeJSON(code, "application/json; charset=utf-8", v)
 }
 
 // OK sends a 200 JSON response.
@@ -137,29 +172,29 @@ func (c *Ctx) NoContent() error {
 	return nil
 }
 
-// BadRequest sends a 400 JSON error response.
+// BadRequest sends a 400 error response in the format of Ctx.Error.
 func (c *Ctx) BadRequest(message string) error {
-	return c.JSON(http.StatusBadRequest, E(message))
+	return c.Error(http.StatusBadRequest, message)
 }
 
-// Unauthorized sends a 401 JSON error response.
+// Unauthorized sends a 401 error response in the format of Ctx.Error.
 func (c *Ctx) Unauthorized(message string) error {
-	return c.JSON(http.StatusUnauthorized, E(message))
+	return c.Error(http.StatusUnauthorized, message)
 }
 
-// Forbidden sends a 403 JSON error response.
+// Forbidden sends a 403 error response in the format of Ctx.Error.
 func (c *Ctx) Forbidden(message string) error {
-	return c.JSON(http.StatusForbidden, E(message))
+	return c.Error(http.StatusForbidden, message)
 }
 
-// NotFound sends a 404 JSON error response.
+// NotFound sends a 404 error response in the format of Ctx.Error.
 func (c *Ctx) NotFound(message string) error {
-	return c.JSON(http.StatusNotFound, E(message))
+	return c.Error(http.StatusNotFound, message)
 }
 
-// ServerError sends a 500 JSON error response.
+// ServerError sends a 500 error response in the format of Ctx.Error.
 func (c *Ctx) ServerError(message string) error {
-	return c.JSON(http.StatusInternalServerError, E(message))
+	return c.Error(http.StatusInternalServerError, message)
 }
 
 // E creates a simple error response map.
@@ -172,104 +207,52 @@ type M map[string]any
 
 // Redirect sends a redirect response.
 func (c *Ctx) Redirect(code int, url string) error {
+	c.checkLive()
 	c.Writer.Header().Set("Location", url)
 	c.Status(code)
 	return nil
 }
 
-// Context returns the request's context.
+// Context returns the request's context, carrying the values stored with
+// Key.Set.
 func (c *Ctx) Context() context.Context {
-	if c.Request == nil {
-		return context.Background()
+	c.checkLive()
+	ctx := context.Background()
+	if c.Request != nil {
+		ctx = c.Request.Context()
 	}
-	return c.Request.Context()
-}
-
-// Bind decodes request body into v based on Content-Type.
-// Supports application/json, application/x-www-form-urlencoded, and multipart/form-data.
-func (c *Ctx) Bind(v any) error {
-	if c.Request.Body == nil {
-		return &BindError{Message: "empty request body"}
+	if len(c.values) == 0 {
+		return ctx
 	}
-
-	contentType := c.Request.Header.Get("Content-Type")
-
-	switch {
-	case strings.HasPrefix(contentType, "application/json"):
-		if err := json.NewDecoder(c.Request.Body).Decode(v); err != nil {
-			if err.Error() == "EOF" {
-				return &BindError{Message: "empty request body"}
-			}
-			return &BindError{Message: "invalid JSON: " + err.Error()}
-		}
-		return nil
-
-	case strings.HasPrefix(contentType, "application/x-www-form-urlencoded"):
-		if err := c.Request.ParseForm(); err != nil {
-			return &BindError{Message: "invalid form data: " + err.Error()}
-		}
-		return bindForm(c.Request.Form, v)
-
-	case strings.HasPrefix(contentType, "multipart/form-data"):
-		if err := c.Request.ParseMultipartForm(32 << 20); err != nil { // 32MB max
-			return &BindError{Message: "invalid multipart data: " + err.Error()}
-		}
-		return bindForm(c.Request.Form, v)
-
-	default:
-		// Default to JSON for backwards compatibility
-		if err := json.NewDecoder(c.Request.Body).Decode(v); err != nil {
-			if err.Error() == "EOF" {
-				return &BindError{Message: "empty request body"}
-			}
-			return &BindError{Message: "invalid JSON: " + err.Error()}
-		}
-		return nil
+	if c.valuesCtx == nil || c.valuesCtx.req != c.Request {
+		c.valuesCtx = &valuesContext{Context: ctx, req: c.Request, values: maps.Clone(c.values)}
 	}
+	return c.valuesCtx
 }
 
-// bindForm binds form values to a struct using json tags.
-func bindForm(form url.Values, v any) error {
-	// Use JSON marshal/unmarshal for simplicity
-	data := make(map[string]any)
-	for key, values := range form {
-		if len(values) == 1 {
-			data[key] = values[0]
-		} else {
-			data[key] = values
-		}
-	}
-	b, err := json.Marshal(data)
-	if err != nil {
-		return &BindError{Message: "form binding error: " + err.Error()}
-	}
-	if err := json.Unmarshal(b, v); err != nil {
-		return &BindError{Message: "form binding error: " + err.Error()}
-	}
-	return nil
+// valuesContext is a snapshot of a Ctx's typed values layered over the
+// request's context. Context rebuilds it after a value or the request
+// changes.
+type valuesContext struct {
+	context.Context
+	req    *http.Request
+	values map[any]any
 }
 
-// BindValid decodes JSON and validates using the provided function.
-func (c *Ctx) BindValid(v any, validate func() error) error {
-	if err := c.Bind(v); err != nil {
-		return err
+func (v *valuesContext) Value(key any) any {
+	if val, ok := v.values[key]; ok {
+		return val
 	}
-	return validate()
-}
-
-// BindError represents a binding error.
-type BindError struct {
-	Message string
-}
-
-func (e *BindError) Error() string {
-	return e.Message
+	return v.Context.Value(key)
 }
 
-// RequestID returns a unique request identifier.
+// RequestID returns a unique request identifier: the one set by
+// middleware.RequestID, a valid incoming X-Request-ID (see ValidRequestID),
+// or a new random one.
 func (c *Ctx) RequestID() string {
+	c.checkLive()
 	if c.requestID == "" {
-		if id := c.Request.Header.Get("X-Request-ID"); id != "" {
+		if id := c.Request.Header.Get("X-Request-ID"); ValidRequestID(id) {
 			c.requestID = id
 		} else {
 			b := make([]byte, 8)
@@ -280,19 +263,18 @@ func (c *Ctx) RequestID() string {
 	return c.requestID
 }
 
-// ClientIP extracts the client IP intelligently.
+// ClientIP returns the client's IP address. Behind proxies set with
+// App.TrustedProxies, it walks X-Forwarded-For (or Forwarded) from the
+// right and returns the first address that is not a trusted proxy, falling
+// back to X-Real-IP. Otherwise forwarding headers are ignored and the
+// connection's address is returned.
 func (c *Ctx) ClientIP() string {
+	c.checkLive()
 	if c.Request == nil {
 		return ""
 	}
-	if xff := c.Request.Header.Get("X-Forwarded-For"); xff != "" {
-		if i := strings.Index(xff, ","); i > 0 {
-			return strings.TrimSpace(xff[:i])
-		}
-		return strings.TrimSpace(xff)
-	}
-	if xri := c.Request.Header.Get("X-Real-IP"); xri != "" {
-		return xri
+	if fwd, ok := c.forwarded(); ok {
+		return fwd.ip.String()
 	}
 	addr := c.Request.RemoteAddr
 	if strings.HasPrefix(addr, "[") {
@@ -308,6 +290,7 @@ func (c *Ctx) ClientIP() string {
 
 // Bearer extracts the Bearer token from Authorization header.
 func (c *Ctx) Bearer() string {
+	c.checkLive()
 	auth := c.Request.Header.Get("Authorization")
 	if strings.HasPrefix(auth, "Bearer ") {
 		return auth[7:]
@@ -317,26 +300,44 @@ func (c *Ctx) Bearer() string {
 
 // IsJSON returns true if Content-Type is application/json.
 func (c *Ctx) IsJSON() bool {
+	c.checkLive()
 	return strings.HasPrefix(c.Request.Header.Get("Content-Type"), "application/json")
 }
 
 // IsAJAX returns true if X-Requested-With is XMLHttpRequest.
 func (c *Ctx) IsAJAX() bool {
+	c.checkLive()
 	return c.Request.Header.Get("X-Requested-With") == "XMLHttpRequest"
 }
 
 // Method returns the request method.
 func (c *Ctx) Method() string {
+	c.checkLive()
 	return c.Request.Method
 }
 
 // Path returns the request path.
 func (c *Ctx) Path() string {
+	c.checkLive()
 	return c.Request.URL.Path
 }
 
+// RoutePattern returns the pattern of the matched route, e.g. "/users/:id",
+// or "" if no route matched. Unlike Path it has no IDs in it, so it suits
+// logs and metrics.
+func (c *Ctx) RoutePattern() string {
+	c.checkLive()
+	return c.route
+}
+
+// App returns the application serving the request.
+func (c *Ctx) App() *App {
+	return c.app
+}
+
 // Set stores a value in the request context.
 func (c *Ctx) Set(key string, value any) {
+	c.checkLive()
 	if c.store == nil {
 		c.store = make(map[string]any)
 	}
@@ -345,6 +346,7 @@ func (c *Ctx) Set(key string, value any) {
 
 // Get retrieves a value from the request context.
 func (c *Ctx) Get(key string) any {
+	c.checkLive()
 	if c.store == nil {
 		return nil
 	}
@@ -377,6 +379,7 @@ func (c *Ctx) GetBool(key string) bool {
 
 // Cookie returns a cookie value by name.
 func (c *Ctx) Cookie(name string) string {
+	c.checkLive()
 	cookie, err := c.Request.Cookie(name)
 	if err != nil {
 		return ""
@@ -386,39 +389,59 @@ func (c *Ctx) Cookie(name string) string {
 
 // SetCookie sets a response cookie.
 func (c *Ctx) SetCookie(cookie *http.Cookie) {
+	c.checkLive()
 	http.SetCookie(c.Writer, cookie)
 }
 
 // FormValue returns a form value by name.
 func (c *Ctx) FormValue(name string) string {
+	c.checkLive()
 	return c.Request.FormValue(name)
 }
 
+// FormFile returns an uploaded file from a multipart form, parsed with
+// MultipartForm.
+func (c *Ctx) FormFile(name string) (*multipart.FileHeader, error) {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, err
+	}
+	if fhs := form.File[name]; len(fhs) > 0 {
+		return fhs[0], nil
+	}
+	return nil, http.ErrMissingFile
+}
+
 // File returns a file from multipart form.
+//
+// Deprecated: Use FormFile. To send a file, use SendFile.
 func (c *Ctx) File(name string) (*multipart.FileHeader, error) {
-	_, fh, err := c.Request.FormFile(name)
-	return fh, err
+	return c.FormFile(name)
 }
 
 // Header sets a response header.
 func (c *Ctx) Header(key, value string) *Ctx {
+	c.checkLive()
 	c.Writer.Header().Set(key, value)
 	return c
 }
 
 // GetHeader returns a request header value.
 func (c *Ctx) GetHeader(key string) string {
+	c.checkLive()
 	return c.Request.Header.Get(key)
 }
 
 // Written returns true if the response has been written.
 func (c *Ctx) Written() bool {
-	return c.written
+	c.checkLive()
+	return c.sent()
 }
 
 // HTML writes an HTML response.
 func (c *Ctx) HTML(code int, html string) error {
-	if !c.written {
+	c.checkLive()
+	if !c.sent() {
 		c.Writer.Header().Set("Content-Type", "text/html; charset=utf-8")
 		c.Writer.WriteHeader(code)
 		c.written = true
@@ -430,7 +453,8 @@ func (c *Ctx) HTML(code int, html string) error {
 
 // Blob writes a binary response with the given content type.
 func (c *Ctx) Blob(code int, contentType string, data []byte) error {
-	if !c.written {
+	c.checkLive()
+	if !c.sent() {
 		c.Writer.Header().Set("Content-Type", contentType)
 		c.Writer.WriteHeader(code)
 		c.written = true
@@ -442,7 +466,8 @@ func (c *Ctx) Blob(code int, contentType string, data []byte) error {
 
 // Stream writes data from a reader to the response.
 func (c *Ctx) Stream(code int, contentType string, r io.Reader) error {
-	if !c.written {
+	c.checkLive()
+	if !c.sent() {
 		c.Writer.Header().Set("Content-Type", contentType)
 		c.Writer.WriteHeader(code)
 		c.written = true
@@ -457,6 +482,7 @@ func (c *Ctx) Stream(code int, contentType string, r io.Reader) error {
 
 // QueryParams returns all query parameters.
 func (c *Ctx) QueryParams() url.Values {
+	c.checkLive()
 	if c.Request.URL == nil {
 		return url.Values{}
 	}
@@ -465,16 +491,24 @@ func (c *Ctx) QueryParams() url.Values {
 
 // SetParam sets a path parameter (used internally by router).
 func (c *Ctx) SetParam(key, value string) {
+	c.checkLive()
 	c.params[key] = value
 }
 
 // Reset clears the context for reuse.
 func (c *Ctx) Reset(w http.ResponseWriter, r *http.Request) {
-	c.Writer = w
+	c.resp.reset(w)
+	c.Writer = &c.resp
 	c.Request = r
 	c.written = false
 	c.statusCode = 0
 	c.requestID = ""
+	c.route = ""
+	c.logger = nil
+	c.copied = false
+	c.valuesCtx = nil
+	c.cleanup = nil
+	c.formErr = nil
 	// Clear params map
 	for k := range c.params {
 		delete(c.params, k)
@@ -483,6 +517,7 @@ func (c *Ctx) Reset(w http.ResponseWriter, r *http.Request) {
 	for k := range c.store {
 		delete(c.store, k)
 	}
+	clear(c.values)
 	// Ensure maps are initialized
 	if c.params == nil {
 		c.params = make(map[string]string)
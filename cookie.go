@@ -0,0 +1,272 @@
+package marten
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Cookie errors returned by SignedCookie, EncryptedCookie and Keyring.
+var (
+	ErrNoKeyring     = errors.New("marten: no keyring configured, see App.SetKeyring")
+	ErrInvalidCookie = errors.New("marten: cookie is invalid or has been tampered with")
+	ErrCookieExpired = errors.New("marten: cookie has expired")
+	ErrKeyTooShort   = errors.New("marten: keyring secrets must be at least 32 bytes")
+
+	errCookieVersion = fmt.Errorf("%w: unknown format", ErrInvalidCookie)
+	errCookiePayload = fmt.Errorf("%w: malformed payload", ErrInvalidCookie)
+)
+
+var (
+	cookieEncoding    = base64.RawURLEncoding
+	cookieSignPurpose = []byte("marten cookie signing")
+	cookieSealPurpose = []byte("marten cookie encryption")
+)
+
+// A cookie payload starts with a format version, the issue time and the
+// max-age, followed by the value.
+const (
+	cookieFormat    = 1
+	cookieHeaderLen = 1 + 8 + 8
+)
+
+// Keyring holds the secrets used to sign and encrypt cookies. The first
+// secret signs and encrypts new values; all secrets are tried when reading,
+// so a secret can be rotated by prepending a new one and keeping the old one
+// until issued cookies have expired. Separate keys for signing and
+// encryption are derived from each secret.
+type Keyring struct {
+	signKeys [][]byte
+	aeads    []cipher.AEAD
+}
+
+// NewKeyring creates a keyring from the current secret followed by older
+// ones. Each secret must be at least 32 random bytes.
+//
+//	keys, err := marten.NewKeyring(currentSecret, previousSecret)
+//	app.SetKeyring(keys)
+func NewKeyring(current []byte, previous ...[]byte) (*Keyring, error) {
+	k := &Keyring{}
+	for _, secret := range append([][]byte{current}, previous...) {
+		if len(secret) < 32 {
+			return nil, ErrKeyTooShort
+		}
+		k.signKeys = append(k.signKeys, deriveKey(secret, cookieSignPurpose))
+		block, err := aes.NewCipher(deriveKey(secret, cookieSealPurpose))
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		k.aeads = append(k.aeads, aead)
+	}
+	return k, nil
+}
+
+func deriveKey(secret, purpose []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(purpose)
+	return mac.Sum(nil)
+}
+
+// Sign returns value signed with HMAC-SHA256 for the cookie name. The
+// value stays readable by the client. maxAge > 0 makes Verify reject the
+// value after that duration, whatever the browser does with the cookie.
+func (k *Keyring) Sign(name string, value []byte, maxAge time.Duration) string {
+	payload := cookiePayload(value, maxAge)
+	return cookieEncoding.EncodeToString(payload) + "." + cookieEncoding.EncodeToString(k.mac(k.signKeys[0], name, payload))
+}
+
+// Verify checks a value produced by Sign for the cookie name and returns the
+// original value. It returns ErrInvalidCookie if no key matches and
+// ErrCookieExpired if the embedded max-age has passed.
+func (k *Keyring) Verify(name, signed string) ([]byte, error) {
+	p, s, ok := strings.Cut(signed, ".")
+	if !ok {
+		return nil, ErrInvalidCookie
+	}
+	payload, err := cookieEncoding.DecodeString(p)
+	if err != nil {
+		return nil, ErrInvalidCookie
+	}
+	sig, err := cookieEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCookie
+	}
+	for _, key := range k.signKeys {
+		if hmac.Equal(sig, k.mac(key, name, payload)) {
+			return parseCookiePayload(payload)
+		}
+	}
+	return nil, ErrInvalidCookie
+}
+
+// Encrypt returns value encrypted and authenticated with AES-256-GCM for the
+// cookie name. maxAge works as for Sign.
+func (k *Keyring) Encrypt(name string, value []byte, maxAge time.Duration) (string, error) {
+	aead := k.aeads[0]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+cookieHeaderLen+len(value)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, cookiePayload(value, maxAge), []byte(name))
+	return cookieEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt returns the value of a cookie produced by Encrypt. Errors are as
+// for Verify.
+func (k *Keyring) Decrypt(name, encrypted string) ([]byte, error) {
+	data, err := cookieEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, ErrInvalidCookie
+	}
+	for _, aead := range k.aeads {
+		if len(data) < aead.NonceSize() {
+			return nil, ErrInvalidCookie
+		}
+		nonce, sealed := data[:aead.NonceSize()], data[aead.NonceSize():]
+		if payload, err := aead.Open(nil, nonce, sealed, []byte(name)); err == nil {
+			return parseCookiePayload(payload)
+		}
+	}
+	return nil, ErrInvalidCookie
+}
+
+func (k *Keyring) mac(key []byte, name string, payload []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(name))
+	mac.Write([]byte{0})
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// cookiePayload encodes value with the format version, the issue time and
+// the max-age (both in nanoseconds).
+func cookiePayload(value []byte, maxAge time.Duration) []byte {
+	payload := make([]byte, cookieHeaderLen, cookieHeaderLen+len(value))
+	payload[0] = cookieFormat
+	binary.BigEndian.PutUint64(payload[1:], uint64(time.Now().UnixNano()))
+	binary.BigEndian.PutUint64(payload[9:], uint64(max(maxAge, 0)))
+	return append(payload, value...)
+}
+
+func parseCookiePayload(payload []byte) ([]byte, error) {
+	if len(payload) < cookieHeaderLen {
+		return nil, errCookiePayload
+	}
+	if payload[0] != cookieFormat {
+		return nil, errCookieVersion
+	}
+	issued := time.Unix(0, int64(binary.BigEndian.Uint64(payload[1:])))
+	maxAge := time.Duration(binary.BigEndian.Uint64(payload[9:]))
+	if maxAge > 0 && time.Since(issued) > maxAge {
+		return nil, ErrCookieExpired
+	}
+	return payload[cookieHeaderLen:], nil
+}
+
+// SetKeyring sets the keyring used for signed and encrypted cookies.
+func (a *App) SetKeyring(k *Keyring) {
+	a.keyring = k
+}
+
+// Keyring returns the app's keyring, or nil.
+func (a *App) Keyring() *Keyring {
+	return a.keyring
+}
+
+// SetSignedCookie sets a cookie whose value is signed with the app's
+// keyring. A positive MaxAge or a future Expires is also embedded in the
+// value and enforced by SignedCookie. A negative MaxAge deletes the cookie.
+//
+//	err := c.SetSignedCookie(&http.Cookie{Name: "uid", Value: "42", MaxAge: 3600, HttpOnly: true})
+func (c *Ctx) SetSignedCookie(cookie *http.Cookie) error {
+	return c.setSecureCookie(cookie, func(k *Keyring, ck *http.Cookie, maxAge time.Duration) (string, error) {
+		return k.Sign(ck.Name, []byte(ck.Value), maxAge), nil
+	})
+}
+
+// SignedCookie returns the verified value of a cookie set with
+// SetSignedCookie. It returns http.ErrNoCookie if the cookie is missing,
+// ErrInvalidCookie if it was tampered with or signed by an unknown key, and
+// ErrCookieExpired if its max-age has passed.
+func (c *Ctx) SignedCookie(name string) (string, error) {
+	return c.secureCookie(name, (*Keyring).Verify)
+}
+
+// SetEncryptedCookie sets a cookie whose value is encrypted with the app's
+// keyring, so the client can neither read nor change it. Expiry works as
+// for SetSignedCookie.
+func (c *Ctx) SetEncryptedCookie(cookie *http.Cookie) error {
+	return c.setSecureCookie(cookie, func(k *Keyring, ck *http.Cookie, maxAge time.Duration) (string, error) {
+		return k.Encrypt(ck.Name, []byte(ck.Value), maxAge)
+	})
+}
+
+// EncryptedCookie returns the decrypted value of a cookie set with
+// SetEncryptedCookie. Errors are as for SignedCookie.
+func (c *Ctx) EncryptedCookie(name string) (string, error) {
+	return c.secureCookie(name, (*Keyring).Decrypt)
+}
+
+func (c *Ctx) setSecureCookie(cookie *http.Cookie, encode func(*Keyring, *http.Cookie, time.Duration) (string, error)) error {
+	c.checkLive()
+	if cookie.MaxAge < 0 {
+		c.SetCookie(cookie)
+		return nil
+	}
+	k := c.keyring()
+	if k == nil {
+		return ErrNoKeyring
+	}
+	var maxAge time.Duration
+	switch {
+	case cookie.MaxAge > 0:
+		maxAge = time.Duration(cookie.MaxAge) * time.Second
+	case !cookie.Expires.IsZero():
+		maxAge = max(time.Until(cookie.Expires), time.Nanosecond)
+	}
+	value, err := encode(k, cookie, maxAge)
+	if err != nil {
+		return err
+	}
+	ck := *cookie
+	ck.Value = value
+	c.SetCookie(&ck)
+	return nil
+}
+
+func (c *Ctx) secureCookie(name string, decode func(*Keyring, string, string) ([]byte, error)) (string, error) {
+	c.checkLive()
+	k := c.keyring()
+	if k == nil {
+		return "", ErrNoKeyring
+	}
+	ck, err := c.Request.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	value, err := decode(k, name, ck.Value)
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
+
+func (c *Ctx) keyring() *Keyring {
+	if c.app == nil {
+		return nil
+	}
+	return c.app.keyring
+}
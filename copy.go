@@ -0,0 +1,153 @@
+package marten
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+)
+
+// ErrCtxCopy is returned when writing a response through a copied Ctx.
+var ErrCtxCopy = errors.New("marten: cannot write a response from a copied Ctx")
+
+// releasedMessage is the panic message for use-after-release in debug builds.
+const releasedMessage = "marten: Ctx used after its request finished; use c.Copy() to keep request data for background work"
+
+// Copy returns a detached snapshot of the context that is safe to use after
+// the handler returns, e.g. from a goroutine. Params, stored values, the
+// request ID and request headers are copied. The copy's request context is
+// not cancelled when the request ends. Response helpers on the copy are
+// no-ops that return ErrCtxCopy.
+func (c *Ctx) Copy() *Ctx {
+	c.checkLive()
+	cp := &Ctx{
+		app:        c.app,
+		params:     make(map[string]string, len(c.params)),
+		store:      make(map[string]any, len(c.store)),
+		written:    true,
+		statusCode: c.StatusCode(),
+		route:      c.route,
+		copied:     true,
+	}
+	for k, v := range c.params {
+		cp.params[k] = v
+	}
+	for k, v := range c.store {
+		cp.store[k] = v
+	}
+	cp.values = maps.Clone(c.values)
+	if c.Request != nil {
+		cp.requestID = c.RequestID()
+		cp.Request = c.Request.Clone(context.WithoutCancel(c.Request.Context()))
+		cp.Request.Body = http.NoBody
+	}
+	header := http.Header{}
+	if c.Writer != nil {
+		header = c.Writer.Header().Clone()
+	}
+	cp.Writer = &copyWriter{header: header}
+	return cp
+}
+
+// IsCopy reports whether c was created by Copy.
+func (c *Ctx) IsCopy() bool {
+	return c.copied
+}
+
+// copyWriter is the response writer of a copied Ctx. It discards writes.
+type copyWriter struct {
+	header http.Header
+}
+
+func (w *copyWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *copyWriter) Write([]byte) (int, error) {
+	return 0, ErrCtxCopy
+}
+
+func (w *copyWriter) WriteHeader(int) {}
+
+// release returns c to the pool, or poisons it in debug builds.
+func (a *App) release(c *Ctx) {
+	if c.cleanup != nil {
+		c.cleanup.run()
+		c.cleanup = nil
+	}
+	if debugCtx {
+		c.poison()
+		return
+	}
+	a.pool.Put(c)
+}
+
+// poison marks c as released so any further use panics.
+func (c *Ctx) poison() {
+	c.released.Store(true)
+	c.Request = nil
+	c.Writer = releasedWriter{}
+	c.params = nil
+	c.store = nil
+	c.values = nil
+	c.valuesCtx = nil
+}
+
+// checkLive panics if c is used after release (debug builds only).
+func (c *Ctx) checkLive() {
+	if debugCtx && c.released.Load() {
+		panic(releasedMessage)
+	}
+}
+
+// releasedWriter panics on every call. It replaces the writer of a released Ctx.
+type releasedWriter struct{}
+
+func (releasedWriter) Header() http.Header       { panic(releasedMessage) }
+func (releasedWriter) Write([]byte) (int, error) { panic(releasedMessage) }
+func (releasedWriter) WriteHeader(int)           { panic(releasedMessage) }
+
+// Fork returns a new Ctx for running the rest of a handler chain on another
+// goroutine. The fork writes its response to w and reads r; params and stored
+// values are copied so the fork and c share no mutable state; temporary files
+// of forms the fork parses are removed when c's request ends. Forks are never
+// pooled. Call Join once the fork has finished to merge its stored values.
+func (c *Ctx) Fork(w http.ResponseWriter, r *http.Request) *Ctx {
+	c.checkLive()
+	f := &Ctx{
+		app:       c.app,
+		Request:   r,
+		params:    make(map[string]string, len(c.params)),
+		store:     make(map[string]any, len(c.store)),
+		requestID: c.requestID,
+		route:     c.route,
+		cleanup:   c.requestCleanup(),
+		formErr:   c.formErr,
+	}
+	f.resp.reset(w)
+	f.Writer = &f.resp
+	for k, v := range c.params {
+		f.params[k] = v
+	}
+	for k, v := range c.store {
+		f.store[k] = v
+	}
+	f.values = maps.Clone(c.values)
+	return f
+}
+
+// Join merges stored values and the request ID of a finished fork back into c.
+// The response itself is not merged; the caller decides what to write.
+func (c *Ctx) Join(f *Ctx) {
+	c.checkLive()
+	for k, v := range f.store {
+		c.Set(k, v)
+	}
+	for k, v := range f.values {
+		c.setValue(k, v)
+	}
+	if f.requestID != "" && f.requestID != c.requestID {
+		c.requestID = f.requestID
+		c.logger = nil
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !martendebug
+
+package marten
+
+// debugCtx enables use-after-release detection for pooled contexts.
+// Build with -tags martendebug to turn it on.
+const debugCtx = false
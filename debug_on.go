@@ -0,0 +1,7 @@
+//go:build martendebug
+
+package marten
+
+// debugCtx enables use-after-release detection for pooled contexts.
+// Released contexts are poisoned instead of pooled, and any later use panics.
+const debugCtx = true
@@ -0,0 +1,216 @@
+package marten
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// HTTPError is an error that carries an HTTP status code.
+// Message is sent to the client; Internal is kept for logging only.
+type HTTPError struct {
+	Code     int
+	Message  string
+	Internal error
+	Header   http.Header
+}
+
+// NewHTTPError creates an HTTPError with the given status code and public message.
+// If message is empty, the standard status text is used.
+func NewHTTPError(code int, message string) *HTTPError {
+	if message == "" {
+		message = http.StatusText(code)
+	}
+	return &HTTPError{Code: code, Message: message}
+}
+
+// Error implements the error interface.
+func (e *HTTPError) Error() string {
+	if e.Internal != nil {
+		return fmt.Sprintf("code=%d, message=%s, internal=%v", e.Code, e.Message, e.Internal)
+	}
+	return fmt.Sprintf("code=%d, message=%s", e.Code, e.Message)
+}
+
+// Unwrap returns the internal cause so errors.Is and errors.As can inspect it.
+func (e *HTTPError) Unwrap() error {
+	return e.Internal
+}
+
+// WithInternal sets the internal cause and returns the error for chaining.
+func (e *HTTPError) WithInternal(err error) *HTTPError {
+	e.Internal = err
+	return e
+}
+
+// WithHeader adds a response header and returns the error for chaining.
+func (e *HTTPError) WithHeader(key, value string) *HTTPError {
+	if e.Header == nil {
+		e.Header = make(http.Header)
+	}
+	e.Header.Add(key, value)
+	return e
+}
+
+// Common HTTP error constructors.
+
+// ErrBadRequest returns a 400 HTTPError.
+func ErrBadRequest(message string) *HTTPError {
+	return NewHTTPError(http.StatusBadRequest, message)
+}
+
+// ErrUnauthorized returns a 401 HTTPError.
+func ErrUnauthorized(message string) *HTTPError {
+	return NewHTTPError(http.StatusUnauthorized, message)
+}
+
+// ErrForbidden returns a 403 HTTPError.
+func ErrForbidden(message string) *HTTPError {
+	return NewHTTPError(http.StatusForbidden, message)
+}
+
+// ErrNotFound returns a 404 HTTPError.
+func ErrNotFound(message string) *HTTPError {
+	return NewHTTPError(http.StatusNotFound, message)
+}
+
+// ErrConflict returns a 409 HTTPError.
+func ErrConflict(message string) *HTTPError {
+	return NewHTTPError(http.StatusConflict, message)
+}
+
+// ErrUnprocessable returns a 422 HTTPError.
+func ErrUnprocessable(message string) *HTTPError {
+	return NewHTTPError(http.StatusUnprocessableEntity, message)
+}
+
+// ErrInternal returns a 500 HTTPError.
+func ErrInternal(message string) *HTTPError {
+	return NewHTTPError(http.StatusInternalServerError, message)
+}
+
+// errorMapping maps a matching error to a status code.
+type errorMapping struct {
+	match func(error) bool
+	code  int
+}
+
+// MapError maps errors matching target (via errors.Is) to the given status code.
+//
+//	app.MapError(sql.ErrNoRows, http.StatusNotFound)
+func (a *App) MapError(target error, code int) {
+	a.errorMappings = append(a.errorMappings, errorMapping{
+		match: func(err error) bool { return errors.Is(err, target) },
+		code:  code,
+	})
+}
+
+// MapErrorAs maps errors of type T (via errors.As) to the given status code.
+//
+//	marten.MapErrorAs[*NotFoundError](app, http.StatusNotFound)
+func MapErrorAs[T error](a *App, code int) {
+	a.errorMappings = append(a.errorMappings, errorMapping{
+		match: func(err error) bool {
+			var target T
+			return errors.As(err, &target)
+		},
+		code: code,
+	})
+}
+
+// ResolveError converts err into an HTTPError using, in order: an HTTPError
+// in the chain, registered mappings, built-in error types (Problem,
+// BindError, ParamError, ValidationErrors), and finally 500.
+// Custom error handlers can use it to honour the app's mappings.
+func (a *App) ResolveError(err error) *HTTPError {
+	he, _ := a.resolveError(err)
+	return he
+}
+
+// resolveError is ResolveError that also reports whether err was recognised.
+func (a *App) resolveError(err error) (*HTTPError, bool) {
+	var he *HTTPError
+	if errors.As(err, &he) {
+		return he, true
+	}
+	for _, m := range a.errorMappings {
+		if m.match(err) {
+			return NewHTTPError(m.code, "").WithInternal(err), true
+		}
+	}
+	var p *Problem
+	if errors.As(err, &p) {
+		return NewHTTPError(p.Status, p.Detail).WithInternal(err), true
+	}
+	var be *BindError
+	if errors.As(err, &be) {
+		return NewHTTPError(http.StatusBadRequest, be.Message).WithInternal(err), true
+	}
+	var pe *ParamError
+	if errors.As(err, &pe) {
+		return NewHTTPError(http.StatusBadRequest, pe.Error()).WithInternal(err), true
+	}
+	var ve ValidationErrors
+	if errors.As(err, &ve) {
+		return NewHTTPError(http.StatusUnprocessableEntity, "validation failed").WithInternal(err), true
+	}
+	return NewHTTPError(http.StatusInternalServerError, "").WithInternal(err), false
+}
+
+// defaultErrorHandler writes the resolved error unless a response was already sent.
+// Unrecognised errors keep the plain-text 500 response.
+func (a *App) defaultErrorHandler(c *Ctx, err error) {
+	if c.sent() {
+		return
+	}
+	if a.problemDetails {
+		var p *Problem
+		if errors.As(err, &p) {
+			_ = c.Problem(p)
+			return
+		}
+	}
+	he, ok := a.resolveError(err)
+	for k, v := range he.Header {
+		c.Writer.Header()[k] = v
+	}
+	if a.problemDetails {
+		detail := he.Message
+		if !ok || detail == http.StatusText(he.Code) {
+			detail = ""
+		}
+		p := NewProblem(he.Code, detail)
+		var be *BindError
+		if errors.As(err, &be) {
+			for _, f := range be.Fields {
+				name := f.Key
+				if name == "" {
+					name = f.Field
+				}
+				p.InvalidParams = append(p.InvalidParams, InvalidParam{Name: name, Reason: f.Err.Error()})
+			}
+		}
+		var pe *ParamError
+		if errors.As(err, &pe) {
+			p.InvalidParams = append(p.InvalidParams, InvalidParam{Name: pe.Name, Reason: pe.Err.Error()})
+		}
+		var ve ValidationErrors
+		if errors.As(err, &ve) {
+			for _, f := range ve {
+				p.InvalidParams = append(p.InvalidParams, InvalidParam{Name: f.Field, Reason: f.Message})
+			}
+		}
+		_ = c.Problem(p)
+		return
+	}
+	if !ok {
+		_ = c.Text(http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	var ve ValidationErrors
+	if errors.As(err, &ve) {
+		_ = c.JSON(he.Code, M{"error": he.Message, "fields": ve})
+		return
+	}
+	_ = c.JSON(he.Code, E(he.Message))
+}
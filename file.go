@@ -0,0 +1,127 @@
+package marten
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// SendFile sends the file at path. It supports Range requests (including
+// multipart/byteranges), If-Range and conditional requests via
+// If-Modified-Since and If-None-Match. An ETag derived from the file's size
+// and modification time is set unless one is already present. Missing files
+// and directories return ErrNotFound.
+func (c *Ctx) SendFile(path string) error {
+	c.checkLive()
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrNotFound("file not found")
+		}
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return ErrNotFound("file not found")
+	}
+	if c.Writer.Header().Get("ETag") == "" {
+		c.Writer.Header().Set("ETag", `"`+strconv.FormatInt(info.ModTime().UnixNano(), 36)+"-"+strconv.FormatInt(info.Size(), 36)+`"`)
+	}
+	return c.ServeContent(info.Name(), info.ModTime(), f)
+}
+
+// Attachment sends the file at path as a download named name (default: the
+// file's base name). Non-ASCII names are encoded per RFC 6266.
+func (c *Ctx) Attachment(path, name string) error {
+	c.checkLive()
+	if name == "" {
+		name = filepath.Base(path)
+	}
+	c.Writer.Header().Set("Content-Disposition", ContentDisposition("attachment", name))
+	return c.SendFile(path)
+}
+
+// Inline sends the file at path for display in the browser, suggesting name
+// (default: the file's base name) if the user saves it.
+func (c *Ctx) Inline(path, name string) error {
+	c.checkLive()
+	if name == "" {
+		name = filepath.Base(path)
+	}
+	c.Writer.Header().Set("Content-Disposition", ContentDisposition("inline", name))
+	return c.SendFile(path)
+}
+
+// ServeContent sends content using http.ServeContent: the Content-Type is
+// detected from name's extension or by sniffing, and Range, If-Range,
+// If-Modified-Since and If-None-Match (against a preset ETag header) are
+// honoured. modtime may be zero.
+func (c *Ctx) ServeContent(name string, modtime time.Time, content io.ReadSeeker) error {
+	c.checkLive()
+	// Write to c.Writer itself so its ReadFrom (e.g. sendfile) is used;
+	// StatusCode reads the status back from c.Response().
+	http.ServeContent(c.Writer, c.Request, name, modtime, content)
+	c.written = true
+	if c.statusCode == 0 {
+		c.statusCode = http.StatusOK
+	}
+	return nil
+}
+
+// ContentDisposition formats a Content-Disposition header value (RFC 6266).
+// Names that are not plain ASCII get an ASCII fallback in filename and the
+// exact name in filename* (RFC 8187).
+//
+//	ContentDisposition("attachment", "résumé.pdf")
+//	// attachment; filename="r_sum_.pdf"; filename*=UTF-8''r%C3%A9sum%C3%A9.pdf
+func ContentDisposition(disposition, name string) string {
+	var fallback strings.Builder
+	plain := true
+	for _, r := range name {
+		switch {
+		case r == '"' || r == '\\':
+			fallback.WriteByte('\\')
+			fallback.WriteRune(r)
+		case r < 0x20 || r == 0x7f || r >= utf8.RuneSelf:
+			fallback.WriteByte('_')
+			plain = false
+		default:
+			fallback.WriteRune(r)
+		}
+	}
+	v := disposition + `; filename="` + fallback.String() + `"`
+	if !plain {
+		v += "; filename*=UTF-8''" + encodeExtValue(name)
+	}
+	return v
+}
+
+// encodeExtValue percent-encodes s, keeping RFC 8187 attr-chars.
+func encodeExtValue(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || '0' <= ch && ch <= '9' ||
+			strings.IndexByte("!#$&+-.^_`|~", ch) >= 0 {
+			b.WriteByte(ch)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[ch>>4])
+		b.WriteByte(hex[ch&0x0f])
+	}
+	return b.String()
+}
@@ -9,6 +9,7 @@ import (
 type Group struct {
 	prefix     string
 	middleware []Middleware
+	security   []SecurityScheme
 	router     *Router
 }
 
@@ -39,12 +40,24 @@ func (g *Group) Group(prefix string, mw ...Middleware) *Group {
 	return &Group{
 		prefix:     g.prefix + prefix,
 		middleware: append(g.middleware, mw...),
+		security:   append([]SecurityScheme(nil), g.security...),
 		router:     g.router,
 	}
 }
 
+// Security declares the security schemes of the group's auth middleware.
+// Routes registered in the group afterwards list them in the OpenAPI
+// document.
+//
+//	admin := app.Group("/admin", middleware.BasicAuth(cfg))
+//	admin.Security(middleware.BasicAuthScheme)
+func (g *Group) Security(schemes ...SecurityScheme) *Group {
+	g.security = append(g.security, schemes...)
+	return g
+}
+
 // Handle registers a route within the group.
-func (g *Group) Handle(method, path string, h Handler, mw ...Middleware) {
+func (g *Group) Handle(method, path string, h Handler, mw ...Middleware) *RouteInfo {
 	// Create new slice to avoid mutating original
 	combined := make([]Middleware, 0, len(g.middleware)+len(mw))
 	combined = append(combined, g.middleware...)
@@ -56,40 +69,40 @@ func (g *Group) Handle(method, path string, h Handler, mw ...Middleware) {
 		fullPath = g.prefix + "/" + path
 	}
 	
-	g.router.Handle(method, fullPath, h, combined...)
+	return g.router.Handle(method, fullPath, h, combined...).Security(g.security...)
 }
 
 // GET registers a GET route within the group.
-func (g *Group) GET(path string, h Handler, mw ...Middleware) {
-	g.Handle(http.MethodGet, path, h, mw...)
+func (g *Group) GET(path string, h Handler, mw ...Middleware) *RouteInfo {
+	return g.Handle(http.MethodGet, path, h, mw...)
 }
 
 // POST registers a POST route within the group.
-func (g *Group) POST(path string, h Handler, mw ...Middleware) {
-	g.Handle(http.MethodPost, path, h, mw...)
+func (g *Group) POST(path string, h Handler, mw ...Middleware) *RouteInfo {
+	return g.Handle(http.MethodPost, path, h, mw...)
 }
 
 // PUT registers a PUT route within the group.
-func (g *Group) PUT(path string, h Handler, mw ...Middleware) {
-	g.Handle(http.MethodPut, path, h, mw...)
+func (g *Group) PUT(path string, h Handler, mw ...Middleware) *RouteInfo {
+	return g.Handle(http.MethodPut, path, h, mw...)
 }
 
 // DELETE registers a DELETE route within the group.
-func (g *Group) DELETE(path string, h Handler, mw ...Middleware) {
-	g.Handle(http.MethodDelete, path, h, mw...)
+func (g *Group) DELETE(path string, h Handler, mw ...Middleware) *RouteInfo {
+	return g.Handle(http.MethodDelete, path, h, mw...)
 }
 
 // PATCH registers a PATCH route within the group.
-func (g *Group) PATCH(path string, h Handler, mw ...Middleware) {
-	g.Handle(http.MethodPatch, path, h, mw...)
+func (g *Group) PATCH(path string, h Handler, mw ...Middleware) *RouteInfo {
+	return g.Handle(http.MethodPatch, path, h, mw...)
 }
 
 // HEAD registers a HEAD route within the group.
-func (g *Group) HEAD(path string, h Handler, mw ...Middleware) {
-	g.Handle(http.MethodHead, path, h, mw...)
+func (g *Group) HEAD(path string, h Handler, mw ...Middleware) *RouteInfo {
+	return g.Handle(http.MethodHead, path, h, mw...)
 }
 
 // OPTIONS registers an OPTIONS route within the group.
-func (g *Group) OPTIONS(path string, h Handler, mw ...Middleware) {
-	g.Handle(http.MethodOptions, path, h, mw...)
+func (g *Group) OPTIONS(path string, h Handler, mw ...Middleware) *RouteInfo {
+	return g.Handle(http.MethodOptions, path, h, mw...)
 }
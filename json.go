@@ -0,0 +1,184 @@
+package marten
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// JSONEncoder encodes values to a stream. *json.Encoder implements it.
+type JSONEncoder interface {
+	Encode(v any) error
+	SetEscapeHTML(on bool)
+	SetIndent(prefix, indent string)
+}
+
+// JSONDecoder decodes values from a stream. *json.Decoder implements it.
+type JSONDecoder interface {
+	Decode(v any) error
+	DisallowUnknownFields()
+	UseNumber()
+}
+
+// JSONCodec creates JSON encoders and decoders. Implement it to plug in a
+// faster encoding/json replacement.
+type JSONCodec interface {
+	NewEncoder(w io.Writer) JSONEncoder
+	NewDecoder(r io.Reader) JSONDecoder
+}
+
+// StdJSON is the JSONCodec backed by encoding/json (the default).
+type StdJSON struct{}
+
+// NewEncoder implements JSONCodec.
+func (StdJSON) NewEncoder(w io.Writer) JSONEncoder { return json.NewEncoder(w) }
+
+// NewDecoder implements JSONCodec.
+func (StdJSON) NewDecoder(r io.Reader) JSONDecoder { return json.NewDecoder(r) }
+
+// JSONConfig configures JSON encoding and decoding.
+// The zero value matches encoding/json defaults.
+type JSONConfig struct {
+	// DisallowUnknownFields makes Bind reject object keys with no matching field.
+	DisallowUnknownFields bool
+	// UseNumber makes Bind decode numbers in interface{} values as json.Number.
+	UseNumber bool
+	// DisableHTMLEscape stops escaping <, > and & in responses.
+	DisableHTMLEscape bool
+	// Indent indents every response with the given string (default: compact).
+	Indent string
+	// PrettyQuery names a query parameter that enables indentation for a
+	// single request, e.g. "pretty" for ?pretty (optional).
+	PrettyQuery string
+	// OmitNewline drops the trailing newline encoding/json appends.
+	OmitNewline bool
+}
+
+// SetJSONCodec replaces the JSON implementation used by Ctx.JSON, Bind,
+// Negotiate and problem details.
+func (a *App) SetJSONCodec(codec JSONCodec) {
+	a.jsonCodec = codec
+}
+
+// SetJSONConfig configures JSON encoding and decoding options.
+func (a *App) SetJSONConfig(cfg JSONConfig) {
+	a.jsonConfig = cfg
+}
+
+var bufPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// maxPooledBuf is the largest buffer capacity returned to bufPool, so one
+// large response does not keep its buffer alive for every later request.
+const maxPooledBuf = 64 << 10
+
+// jsonSettings returns the codec and config for this request.
+func (c *Ctx) jsonSettings() (JSONCodec, JSONConfig) {
+	if c.app == nil {
+		return StdJSON{}, JSONConfig{}
+	}
+	codec := c.app.jsonCodec
+	if codec == nil {
+		codec = StdJSON{}
+	}
+	return codec, c.app.jsonConfig
+}
+
+// encodeJSON writes v to w using the app's codec and config.
+func (c *Ctx) encodeJSON(w *bytes.Buffer, v any) error {
+	codec, cfg := c.jsonSettings()
+	enc := codec.NewEncoder(w)
+	if cfg.DisableHTMLEscape {
+		enc.SetEscapeHTML(false)
+	}
+	indent := cfg.Indent
+	if cfg.PrettyQuery != "" && c.Request != nil && c.Request.URL != nil {
+		if q := c.Request.URL.Query(); q.Has(cfg.PrettyQuery) {
+			if v := q.Get(cfg.PrettyQuery); v != "false" && v != "0" {
+				if indent == "" {
+					indent = "  "
+				}
+			}
+		}
+	}
+	if indent != "" {
+		enc.SetIndent("", indent)
+	}
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	if cfg.OmitNewline {
+		if b := w.Bytes(); len(b) > 0 && b[len(b)-1] == '\n' {
+			w.Truncate(w.Len() - 1)
+		}
+	}
+	return nil
+}
+
+// writeJSON encodes v and writes it with the given status and content type.
+// Encoding happens before the header is written, so an encoding error
+// leaves the response untouched.
+func (c *Ctx) writeJSON(code int, contentType string, v any) error {
+	buf := bufPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer func() {
+		if buf.Cap() <= maxPooledBuf {
+			bufPool.Put(buf)
+		}
+	}()
+
+	if err := c.encodeJSON(buf, v); err != nil {
+		return err
+	}
+	return c.Blob(code, contentType, buf.Bytes())
+}
+
+// decodeJSON decodes data into v, reporting syntax and type errors with
+// line and column positions.
+func (c *Ctx) decodeJSON(data []byte, v any) error {
+	codec, cfg := c.jsonSettings()
+	dec := codec.NewDecoder(bytes.NewReader(data))
+	if cfg.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if cfg.UseNumber {
+		dec.UseNumber()
+	}
+	err := dec.Decode(v)
+	if err == nil {
+		return nil
+	}
+
+	be := &BindError{Message: "invalid JSON: " + err.Error()}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		be.Offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		be.Offset = typeErr.Offset
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		be.Offset = int64(len(data))
+	}
+	if be.Offset > 0 {
+		be.Line, be.Column = lineColumn(data, be.Offset)
+		be.Message += fmt.Sprintf(" (line %d, column %d)", be.Line, be.Column)
+	}
+	return be
+}
+
+// lineColumn converts the byte offset reported by encoding/json (the number
+// of bytes read before the error) into 1-based line and column numbers.
+func lineColumn(data []byte, offset int64) (line, col int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	col = int(offset) - bytes.LastIndexByte(before, '\n') - 1
+	return line, col
+}
@@ -0,0 +1,79 @@
+package marten
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// Key is a typed key for request-scoped values, shared by Ctx code and
+// context.Context code. A key is compared by identity, so declare each one
+// once as a package variable.
+//
+//	var UserKey = marten.NewKey[*User]("user")
+//
+//	UserKey.Set(c, user)
+//	user, ok := UserKey.Get(c)
+//	user, ok = UserKey.Value(ctx) // in code that only has c.Context()
+//
+// Values are kept apart from the string-keyed store of Ctx.Set, so keys
+// with the same name never collide; the name is only used by String.
+type Key[T any] struct {
+	name string
+}
+
+// NewKey creates a key for values of type T.
+func NewKey[T any](name string) *Key[T] {
+	return &Key[T]{name: name}
+}
+
+// Name returns the key's name, for debugging.
+func (k *Key[T]) Name() string {
+	return k.name
+}
+
+// String describes the key, e.g. in context.Context's String output.
+func (k *Key[T]) String() string {
+	return fmt.Sprintf("marten.Key[%s](%q)", reflect.TypeFor[T](), k.name)
+}
+
+// Set stores v on c. c.Context() carries it, so Value and ctx.Value(k) find
+// it too.
+func (k *Key[T]) Set(c *Ctx, v T) {
+	c.setValue(k, v)
+}
+
+// Get returns the value set on c, or found in the request's context (e.g.
+// added by net/http middleware with WithValue).
+func (k *Key[T]) Get(c *Ctx) (T, bool) {
+	c.checkLive()
+	if v, ok := c.values[k]; ok {
+		return v.(T), true
+	}
+	if c.Request == nil {
+		var zero T
+		return zero, false
+	}
+	return k.Value(c.Request.Context())
+}
+
+// Value returns the value carried by ctx.
+func (k *Key[T]) Value(ctx context.Context) (T, bool) {
+	v, ok := ctx.Value(k).(T)
+	return v, ok
+}
+
+// WithValue returns a copy of ctx carrying v.
+func (k *Key[T]) WithValue(ctx context.Context, v T) context.Context {
+	return context.WithValue(ctx, k, v)
+}
+
+// setValue stores a typed value; see Key.Set.
+func (c *Ctx) setValue(k, v any) {
+	c.checkLive()
+	if c.values == nil {
+		c.values = make(map[any]any)
+	}
+	c.values[k] = v
+	c.valuesCtx = nil
+}
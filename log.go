@@ -0,0 +1,39 @@
+package marten
+
+import "log/slog"
+
+// SetLogger sets the logger that Ctx.Logger builds on (default:
+// slog.Default()).
+func (a *App) SetLogger(l *slog.Logger) {
+	a.logger = l
+}
+
+// Logger returns the app's logger, or slog.Default() if none was set.
+func (a *App) Logger() *slog.Logger {
+	if a.logger == nil {
+		return slog.Default()
+	}
+	return a.logger
+}
+
+// Logger returns a logger for the request with the request ID, route
+// pattern, method and client IP attached.
+//
+//	c.Logger().Info("user created", "user_id", u.ID)
+func (c *Ctx) Logger() *slog.Logger {
+	c.checkLive()
+	if c.logger == nil {
+		base := slog.Default()
+		if c.app != nil {
+			base = c.app.Logger()
+		}
+		attrs := make([]any, 0, 4)
+		attrs = append(attrs, slog.String("request_id", c.RequestID()))
+		if c.route != "" {
+			attrs = append(attrs, slog.String("route", c.route))
+		}
+		attrs = append(attrs, slog.String("method", c.Request.Method), slog.String("client_ip", c.ClientIP()))
+		c.logger = base.With(attrs...)
+	}
+	return c.logger
+}
@@ -0,0 +1,73 @@
+package martentest
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gomarten/marten"
+)
+
+// Transport is an http.RoundTripper that serves requests with Handler in memory.
+type Transport struct {
+	Handler http.Handler
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	if r.RemoteAddr == "" {
+		r.RemoteAddr = "192.0.2.1:1234"
+	}
+	if r.RequestURI == "" {
+		r.RequestURI = r.URL.RequestURI()
+	}
+	if r.Body == nil {
+		r.Body = http.NoBody
+	}
+	rec := httptest.NewRecorder()
+	t.Handler.ServeHTTP(rec, r)
+	resp := rec.Result()
+	resp.Request = req
+	return resp, nil
+}
+
+// Client returns an *http.Client that serves requests in memory and shares
+// the Tester's cookie jar. Use absolute URLs such as "http://example.com/login".
+func (t *Tester) Client() *http.Client {
+	return &http.Client{
+		Transport: &Transport{Handler: t.handler},
+		Jar:       t.jar,
+	}
+}
+
+// NewCtx returns a standalone Ctx for unit-testing a single handler or
+// middleware, and the recorder that captures its response. The Ctx has no
+// App, so it uses default settings; see NewCtxFor.
+//
+//	c, rec := martentest.NewCtx(httptest.NewRequest("GET", "/users/1", nil), "id", "1")
+//	err := showUser(c)
+func NewCtx(req *http.Request, params ...string) (*marten.Ctx, *httptest.ResponseRecorder) {
+	rec := httptest.NewRecorder()
+	c := &marten.Ctx{}
+	c.Reset(rec, req)
+	setParams(c, params)
+	return c, rec
+}
+
+// NewCtxFor is NewCtx for a Ctx bound to app, so its error mappings, JSON
+// codec, multipart limits, trusted proxies, keyring and problem details mode
+// apply as in production.
+//
+//	c, rec := martentest.NewCtxFor(app, httptest.NewRequest("GET", "/users/1", nil), "id", "1")
+func NewCtxFor(app *marten.App, req *http.Request, params ...string) (*marten.Ctx, *httptest.ResponseRecorder) {
+	rec := httptest.NewRecorder()
+	c := app.NewCtx(rec, req)
+	setParams(c, params)
+	return c, rec
+}
+
+func setParams(c *marten.Ctx, params []string) {
+	for i := 0; i+1 < len(params); i += 2 {
+		c.SetParam(params[i], params[i+1])
+	}
+}
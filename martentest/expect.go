@@ -0,0 +1,210 @@
+package martentest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Response holds a recorded response and chains assertions on it.
+// Failed assertions are reported with t.Errorf; the chain continues.
+type Response struct {
+	t        testing.TB
+	Recorder *httptest.ResponseRecorder
+}
+
+// Status asserts the response status code.
+func (r *Response) Status(code int) *Response {
+	r.t.Helper()
+	if r.Recorder.Code != code {
+		r.t.Errorf("martentest: expected status %d, got %d (body: %s)", code, r.Recorder.Code, r.Recorder.Body.String())
+	}
+	return r
+}
+
+// Header asserts a response header value.
+func (r *Response) Header(key, value string) *Response {
+	r.t.Helper()
+	if got := r.Recorder.Header().Get(key); got != value {
+		r.t.Errorf("martentest: expected header %s=%q, got %q", key, value, got)
+	}
+	return r
+}
+
+// HeaderContains asserts that a response header contains substr.
+func (r *Response) HeaderContains(key, substr string) *Response {
+	r.t.Helper()
+	if got := r.Recorder.Header().Get(key); !strings.Contains(got, substr) {
+		r.t.Errorf("martentest: expected header %s to contain %q, got %q", key, substr, got)
+	}
+	return r
+}
+
+// Body asserts the exact response body.
+func (r *Response) Body(body string) *Response {
+	r.t.Helper()
+	if got := r.Recorder.Body.String(); got != body {
+		r.t.Errorf("martentest: expected body %q, got %q", body, got)
+	}
+	return r
+}
+
+// BodyContains asserts that the response body contains substr.
+func (r *Response) BodyContains(substr string) *Response {
+	r.t.Helper()
+	if got := r.Recorder.Body.String(); !strings.Contains(got, substr) {
+		r.t.Errorf("martentest: expected body to contain %q, got %q", substr, got)
+	}
+	return r
+}
+
+// Cookie asserts that the response sets a cookie with the given value.
+func (r *Response) Cookie(name, value string) *Response {
+	r.t.Helper()
+	for _, c := range r.Recorder.Result().Cookies() {
+		if c.Name == name {
+			if c.Value != value {
+				r.t.Errorf("martentest: expected cookie %s=%q, got %q", name, value, c.Value)
+			}
+			return r
+		}
+	}
+	r.t.Errorf("martentest: expected cookie %s to be set", name)
+	return r
+}
+
+// JSON asserts that the body is JSON equal to want (compared after encoding want).
+func (r *Response) JSON(want any) *Response {
+	r.t.Helper()
+	got, err := r.decode()
+	if err != nil {
+		r.t.Errorf("martentest: invalid JSON body %q: %v", r.Recorder.Body.String(), err)
+		return r
+	}
+	if w := normalize(want); !reflect.DeepEqual(got, w) {
+		r.t.Errorf("martentest: expected JSON %v, got %v", w, got)
+	}
+	return r
+}
+
+// JSONPath asserts the value at a JSONPath-style expression such as
+// "$.users[0].name". Supported syntax: $, .key, ['key'] and [index].
+func (r *Response) JSONPath(path string, want any) *Response {
+	r.t.Helper()
+	doc, err := r.decode()
+	if err != nil {
+		r.t.Errorf("martentest: invalid JSON body %q: %v", r.Recorder.Body.String(), err)
+		return r
+	}
+	got, err := lookup(doc, path)
+	if err != nil {
+		r.t.Errorf("martentest: %s: %v (body: %s)", path, err, r.Recorder.Body.String())
+		return r
+	}
+	if w := normalize(want); !reflect.DeepEqual(got, w) {
+		r.t.Errorf("martentest: expected %s = %v, got %v", path, w, got)
+	}
+	return r
+}
+
+// Decode decodes the JSON body into v.
+func (r *Response) Decode(v any) *Response {
+	r.t.Helper()
+	if err := json.Unmarshal(r.Recorder.Body.Bytes(), v); err != nil {
+		r.t.Errorf("martentest: decoding body %q: %v", r.Recorder.Body.String(), err)
+	}
+	return r
+}
+
+// Result returns the recorded *http.Response.
+func (r *Response) Result() *http.Response {
+	return r.Recorder.Result()
+}
+
+func (r *Response) decode() (any, error) {
+	var v any
+	err := json.Unmarshal(r.Recorder.Body.Bytes(), &v)
+	return v, err
+}
+
+// normalize converts v to the shape produced by decoding JSON into any.
+func normalize(v any) any {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out any
+	if err := json.Unmarshal(b, &out); err != nil {
+		return v
+	}
+	return out
+}
+
+// lookup evaluates a simple JSONPath expression against doc.
+func lookup(doc any, path string) (any, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("path must start with $")
+	}
+	rest := path[1:]
+	cur := doc
+	for rest != "" {
+		switch {
+		case rest[0] == '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			key := rest[:end]
+			rest = rest[end:]
+			obj, ok := cur.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("cannot select %q from %T", key, cur)
+			}
+			if cur, ok = obj[key]; !ok {
+				return nil, fmt.Errorf("key %q not found", key)
+			}
+		case rest[0] == '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated [")
+			}
+			sel := rest[1:end]
+			rest = rest[end+1:]
+			if len(sel) >= 2 && (sel[0] == '\'' || sel[0] == '"') {
+				key := sel[1 : len(sel)-1]
+				obj, ok := cur.(map[string]any)
+				if !ok {
+					return nil, fmt.Errorf("cannot select %q from %T", key, cur)
+				}
+				if cur, ok = obj[key]; !ok {
+					return nil, fmt.Errorf("key %q not found", key)
+				}
+				continue
+			}
+			i, err := strconv.Atoi(sel)
+			if err != nil {
+				return nil, fmt.Errorf("invalid index %q", sel)
+			}
+			arr, ok := cur.([]any)
+			if !ok {
+				return nil, fmt.Errorf("cannot index %T", cur)
+			}
+			if i < 0 {
+				i += len(arr)
+			}
+			if i < 0 || i >= len(arr) {
+				return nil, fmt.Errorf("index %d out of range (len %d)", i, len(arr))
+			}
+			cur = arr[i]
+		default:
+			return nil, fmt.Errorf("unexpected %q", rest)
+		}
+	}
+	return cur, nil
+}
@@ -0,0 +1,43 @@
+package martentest
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// UpdateEnv is the environment variable that makes Golden rewrite snapshot
+// files instead of comparing against them (e.g. MARTENTEST_UPDATE=1 go test).
+const UpdateEnv = "MARTENTEST_UPDATE"
+
+// Golden asserts that the response body matches the snapshot stored at path.
+// JSON bodies are indented before comparison so snapshots diff cleanly.
+func (r *Response) Golden(path string) *Response {
+	r.t.Helper()
+	got := r.Recorder.Body.Bytes()
+	var indented bytes.Buffer
+	if json.Valid(got) && json.Indent(&indented, got, "", "  ") == nil {
+		got = append(bytes.TrimSpace(indented.Bytes()), '\n')
+	}
+
+	if os.Getenv(UpdateEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			r.t.Fatalf("martentest: creating golden dir: %v", err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			r.t.Fatalf("martentest: writing golden file: %v", err)
+		}
+		return r
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		r.t.Errorf("martentest: reading golden file (run with %s=1 to create it): %v", UpdateEnv, err)
+		return r
+	}
+	if !bytes.Equal(got, want) {
+		r.t.Errorf("martentest: body does not match %s\n--- want\n%s\n--- got\n%s", path, want, got)
+	}
+	return r
+}
@@ -0,0 +1,221 @@
+// Package martentest provides helpers for testing Marten apps, handlers and middleware.
+//
+//	martentest.New(app).GET("/users/1").
+//		Header("Authorization", "Bearer token").
+//		Expect(t).
+//		Status(200).
+//		JSONPath("$.name", "bob")
+package martentest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// Tester sends requests to an http.Handler (usually a *marten.App) in memory.
+// Cookies set by responses are kept in a jar and sent with later requests.
+type Tester struct {
+	handler http.Handler
+	header  http.Header
+	jar     http.CookieJar
+}
+
+// New creates a Tester for h.
+func New(h http.Handler) *Tester {
+	jar, _ := cookiejar.New(nil)
+	return &Tester{
+		handler: h,
+		header:  make(http.Header),
+		jar:     jar,
+	}
+}
+
+// Header sets a header sent with every request.
+func (t *Tester) Header(key, value string) *Tester {
+	t.header.Set(key, value)
+	return t
+}
+
+// Jar returns the cookie jar shared by the Tester and its Client.
+func (t *Tester) Jar() http.CookieJar {
+	return t.jar
+}
+
+// Request starts building a request with the given method and path.
+func (t *Tester) Request(method, path string) *Request {
+	return &Request{
+		tester: t,
+		method: method,
+		path:   path,
+		header: t.header.Clone(),
+		query:  make(url.Values),
+	}
+}
+
+// GET starts building a GET request.
+func (t *Tester) GET(path string) *Request {
+	return t.Request(http.MethodGet, path)
+}
+
+// POST starts building a POST request.
+func (t *Tester) POST(path string) *Request {
+	return t.Request(http.MethodPost, path)
+}
+
+// PUT starts building a PUT request.
+func (t *Tester) PUT(path string) *Request {
+	return t.Request(http.MethodPut, path)
+}
+
+// PATCH starts building a PATCH request.
+func (t *Tester) PATCH(path string) *Request {
+	return t.Request(http.MethodPatch, path)
+}
+
+// DELETE starts building a DELETE request.
+func (t *Tester) DELETE(path string) *Request {
+	return t.Request(http.MethodDelete, path)
+}
+
+// HEAD starts building a HEAD request.
+func (t *Tester) HEAD(path string) *Request {
+	return t.Request(http.MethodHead, path)
+}
+
+// OPTIONS starts building an OPTIONS request.
+func (t *Tester) OPTIONS(path string) *Request {
+	return t.Request(http.MethodOptions, path)
+}
+
+// Request is a request under construction.
+type Request struct {
+	tester  *Tester
+	method  string
+	path    string
+	header  http.Header
+	query   url.Values
+	cookies []*http.Cookie
+	body    io.Reader
+	err     error
+}
+
+// Header sets a request header.
+func (r *Request) Header(key, value string) *Request {
+	r.header.Set(key, value)
+	return r
+}
+
+// Query adds a query parameter.
+func (r *Request) Query(key, value string) *Request {
+	r.query.Add(key, value)
+	return r
+}
+
+// Cookie adds a cookie to the request.
+func (r *Request) Cookie(c *http.Cookie) *Request {
+	r.cookies = append(r.cookies, c)
+	return r
+}
+
+// Bearer sets an Authorization: Bearer header.
+func (r *Request) Bearer(token string) *Request {
+	return r.Header("Authorization", "Bearer "+token)
+}
+
+// JSON sets a JSON-encoded request body.
+func (r *Request) JSON(v any) *Request {
+	b, err := json.Marshal(v)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	r.header.Set("Content-Type", "application/json")
+	r.body = bytes.NewReader(b)
+	return r
+}
+
+// Form sets a URL-encoded form body.
+func (r *Request) Form(values url.Values) *Request {
+	r.header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.body = strings.NewReader(values.Encode())
+	return r
+}
+
+// Body sets a raw request body with the given content type.
+func (r *Request) Body(contentType string, body io.Reader) *Request {
+	if contentType != "" {
+		r.header.Set("Content-Type", contentType)
+	}
+	r.body = body
+	return r
+}
+
+// Build returns the *http.Request that would be sent.
+func (r *Request) Build() (*http.Request, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	req := httptest.NewRequest(r.method, r.path, r.body)
+	if len(r.query) > 0 {
+		q := req.URL.Query()
+		for k, vs := range r.query {
+			for _, v := range vs {
+				q.Add(k, v)
+			}
+		}
+		req.URL.RawQuery = q.Encode()
+	}
+	for k, vs := range r.header {
+		req.Header[k] = vs
+	}
+	for _, c := range r.tester.jar.Cookies(jarURL(req)) {
+		req.AddCookie(c)
+	}
+	for _, c := range r.cookies {
+		req.AddCookie(c)
+	}
+	return req, nil
+}
+
+// Do sends the request and returns the recorded response.
+func (r *Request) Do() (*httptest.ResponseRecorder, error) {
+	req, err := r.Build()
+	if err != nil {
+		return nil, err
+	}
+	rec := httptest.NewRecorder()
+	r.tester.handler.ServeHTTP(rec, req)
+	if cookies := rec.Result().Cookies(); len(cookies) > 0 {
+		r.tester.jar.SetCookies(jarURL(req), cookies)
+	}
+	return rec, nil
+}
+
+// Expect sends the request and returns the response for assertions.
+// Failing to build the request fails the test immediately.
+func (r *Request) Expect(t testing.TB) *Response {
+	t.Helper()
+	rec, err := r.Do()
+	if err != nil {
+		t.Fatalf("martentest: building %s %s: %v", r.method, r.path, err)
+	}
+	return &Response{t: t, Recorder: rec}
+}
+
+// jarURL returns the absolute URL used for cookie jar lookups.
+func jarURL(req *http.Request) *url.URL {
+	u := *req.URL
+	u.Scheme = "http"
+	if req.TLS != nil {
+		u.Scheme = "https"
+	}
+	u.Host = req.Host
+	return &u
+}
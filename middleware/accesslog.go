@@ -0,0 +1,349 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gomarten/marten"
+)
+
+// LogFields selects the optional fields of an access log record.
+type LogFields uint
+
+const (
+	// LogRoute logs the matched route pattern as "route"
+	LogRoute LogFields = 1 << iota
+	// LogBytesIn logs the request body bytes read as "bytes_in"
+	LogBytesIn
+	// LogBytesOut logs the response body bytes as "bytes_out"
+	LogBytesOut
+	// LogUserAgent logs the User-Agent header as "user_agent"
+	LogUserAgent
+	// LogReferer logs the Referer header as "referer"
+	LogReferer
+	// LogError logs the error returned by the handler as "error"
+	LogError
+	// LogQuery logs the raw query string as "query"
+	LogQuery
+	// LogProto logs the protocol, e.g. "HTTP/1.1", as "proto"
+	LogProto
+	// LogUser logs the user set with UserKey (e.g. by BasicAuth) as "user"
+	LogUser
+
+	// DefaultLogFields are logged when AccessLogConfig.Fields is zero.
+	DefaultLogFields = LogRoute | LogBytesOut | LogError
+	// AllLogFields logs every optional field.
+	AllLogFields = LogUser<<1 - 1
+)
+
+// AccessLogConfig configures the access log middleware.
+type AccessLogConfig struct {
+	// Handler receives the records (default: the handler of the app's
+	// logger, see App.SetLogger)
+	Handler slog.Handler
+	// Message is the record message (default: "request")
+	Message string
+	// Fields selects the optional fields (default: DefaultLogFields). The
+	// method, path, status, duration, client IP and request ID are always
+	// logged.
+	Fields LogFields
+	// Level returns the record level for a status (default: Error for 5xx,
+	// Warn for 4xx, Info otherwise)
+	Level func(status int) slog.Level
+	// SampleRate is the fraction of 2xx responses logged, up to 1. Zero
+	// means the default of 1 (all of them); a negative rate logs none. Other
+	// responses are always logged.
+	SampleRate float64
+	// Skip is a function to skip logging for certain requests
+	Skip func(*marten.Ctx) bool
+}
+
+// DefaultAccessLogConfig returns sensible defaults.
+func DefaultAccessLogConfig() AccessLogConfig {
+	return AccessLogConfig{
+		Message:    "request",
+		Fields:     DefaultLogFields,
+		Level:      statusLevel,
+		SampleRate: 1,
+	}
+}
+
+// AccessLog logs each request with log/slog once the handler returns.
+//
+//	app.Use(middleware.AccessLog(middleware.AccessLogConfig{
+//		Handler: slog.NewJSONHandler(os.Stdout, nil),
+//		Fields:  middleware.AllLogFields,
+//	}))
+//
+// If the handler returns an error before writing a response, the status
+// logged is the one App.ResolveError gives it. See CommonLogFormat and
+// CombinedLogFormat for Apache/NGINX style lines.
+func AccessLog(cfg AccessLogConfig) marten.Middleware {
+	def := DefaultAccessLogConfig()
+	if cfg.Message == "" {
+		cfg.Message = def.Message
+	}
+	if cfg.Fields == 0 {
+		cfg.Fields = def.Fields
+	}
+	if cfg.Level == nil {
+		cfg.Level = def.Level
+	}
+	if cfg.SampleRate == 0 {
+		cfg.SampleRate = def.SampleRate
+	}
+	var logger *slog.Logger
+	if cfg.Handler != nil {
+		logger = slog.New(cfg.Handler)
+	}
+
+	return func(next marten.Handler) marten.Handler {
+		return func(c *marten.Ctx) error {
+			if cfg.Skip != nil && cfg.Skip(c) {
+				return next(c)
+			}
+
+			var body *countingBody
+			if cfg.Fields&LogBytesIn != 0 && c.Request.Body != nil {
+				body = &countingBody{ReadCloser: c.Request.Body}
+				c.Request.Body = body
+			}
+
+			start := time.Now()
+			err := next(c)
+			duration := time.Since(start)
+
+			status := responseStatus(c, err)
+			if status >= 200 && status < 300 && cfg.SampleRate < 1 && rand.Float64() >= cfg.SampleRate {
+				return err
+			}
+
+			l := logger
+			if l == nil {
+				l = slog.Default()
+				if app := c.App(); app != nil {
+					l = app.Logger()
+				}
+			}
+			level := cfg.Level(status)
+			ctx := c.Context()
+			if !l.Enabled(ctx, level) {
+				return err
+			}
+
+			r := c.Request
+			attrs := make([]slog.Attr, 0, 15)
+			attrs = append(attrs,
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", status),
+				slog.Duration("duration", duration),
+				slog.String("client_ip", c.ClientIP()),
+				slog.String("request_id", c.RequestID()),
+			)
+			if cfg.Fields&LogRoute != 0 && c.RoutePattern() != "" {
+				attrs = append(attrs, slog.String("route", c.RoutePattern()))
+			}
+			if cfg.Fields&LogQuery != 0 && r.URL.RawQuery != "" {
+				attrs = append(attrs, slog.String("query", r.URL.RawQuery))
+			}
+			if cfg.Fields&LogProto != 0 {
+				attrs = append(attrs, slog.String("proto", r.Proto))
+			}
+			if body != nil {
+				attrs = append(attrs, slog.Int64("bytes_in", body.n))
+			}
+			if cfg.Fields&LogBytesOut != 0 {
+				attrs = append(attrs, slog.Int64("bytes_out", c.Response().Size()))
+			}
+			if cfg.Fields&LogUserAgent != 0 {
+				attrs = append(attrs, slog.String("user_agent", r.UserAgent()))
+			}
+			if cfg.Fields&LogReferer != 0 {
+				attrs = append(attrs, slog.String("referer", r.Referer()))
+			}
+			if cfg.Fields&LogUser != 0 {
+				if user, ok := UserKey.Get(c); ok {
+					attrs = append(attrs, slog.String("user", user))
+				}
+			}
+			if cfg.Fields&LogError != 0 && err != nil {
+				attrs = append(attrs, slog.String("error", err.Error()))
+			}
+			l.LogAttrs(ctx, level, cfg.Message, attrs...)
+			return err
+		}
+	}
+}
+
+// responseStatus returns the status sent, or the status err will be sent
+// with.
+func responseStatus(c *marten.Ctx, err error) int {
+	resp := c.Response()
+	switch {
+	case resp.Hijacked():
+		return http.StatusSwitchingProtocols
+	case resp.Written():
+		return resp.Status()
+	case err != nil:
+		if app := c.App(); app != nil {
+			return app.ResolveError(err).Code
+		}
+		return http.StatusInternalServerError
+	case c.StatusCode() != 0:
+		return c.StatusCode()
+	}
+	return http.StatusOK
+}
+
+func statusLevel(status int) slog.Level {
+	switch {
+	case status >= 500:
+		return slog.LevelError
+	case status >= 400:
+		return slog.LevelWarn
+	}
+	return slog.LevelInfo
+}
+
+// countingBody counts the bytes read from a request body.
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+// CommonLogFormat returns an access log configuration that writes lines in
+// the Common Log Format used by Apache and NGINX:
+//
+//	127.0.0.1 - alice [10/Oct/2000:13:55:36 -0700] "GET /a.gif HTTP/1.1" 200 2326
+func CommonLogFormat(w io.Writer) AccessLogConfig {
+	return AccessLogConfig{
+		Handler: &clfHandler{w: w, mu: new(sync.Mutex)},
+		Fields:  LogQuery | LogProto | LogBytesOut | LogUser,
+	}
+}
+
+// CombinedLogFormat returns an access log configuration that writes lines in
+// the Combined Log Format, the Common Log Format followed by the quoted
+// Referer and User-Agent headers. It is NGINX's default.
+func CombinedLogFormat(w io.Writer) AccessLogConfig {
+	return AccessLogConfig{
+		Handler: &clfHandler{w: w, mu: new(sync.Mutex), combined: true},
+		Fields:  LogQuery | LogProto | LogBytesOut | LogUser | LogReferer | LogUserAgent,
+	}
+}
+
+// clfHandler is a slog.Handler that formats access log records as Common
+// or Combined Log Format lines. Groups are ignored.
+type clfHandler struct {
+	w        io.Writer
+	mu       *sync.Mutex
+	combined bool
+	attrs    []slog.Attr
+}
+
+func (h *clfHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *clfHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.attrs = append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...)
+	return &h2
+}
+
+func (h *clfHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+func (h *clfHandler) Handle(_ context.Context, r slog.Record) error {
+	f := make(map[string]string, 12)
+	for _, a := range h.attrs {
+		f[a.Key] = a.Value.String()
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		f[a.Key] = a.Value.String()
+		return true
+	})
+
+	uri := f["path"]
+	if f["query"] != "" {
+		uri += "?" + f["query"]
+	}
+	size := f["bytes_out"]
+	if size == "" || size == "0" {
+		size = "-"
+	}
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	b := make([]byte, 0, 256)
+	b = append(b, orDash(f["client_ip"])...)
+	b = append(b, " - "...)
+	b = append(b, orDash(clfEscape(f["user"]))...)
+	b = append(b, " ["...)
+	b = t.AppendFormat(b, "02/Jan/2006:15:04:05 -0700")
+	b = append(b, "] \""...)
+	b = append(b, clfEscape(f["method"]+" "+uri+" "+f["proto"])...)
+	b = append(b, "\" "...)
+	b = append(b, orDash(f["status"])...)
+	b = append(b, ' ')
+	b = append(b, size...)
+	if h.combined {
+		b = append(b, " \""...)
+		b = append(b, orDash(clfEscape(f["referer"]))...)
+		b = append(b, "\" \""...)
+		b = append(b, orDash(clfEscape(f["user_agent"]))...)
+		b = append(b, '"')
+	}
+	b = append(b, '\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.w.Write(b)
+	return err
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// clfEscape escapes quotes, backslashes and non-printable bytes as \xHH, as
+// Apache does, so values cannot break or forge log lines.
+func clfEscape(s string) string {
+	const hex = "0123456789abcdef"
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 0x20 && c < 0x7f && c != '"' && c != '\\' {
+			if b != nil {
+				b = append(b, c)
+			}
+			continue
+		}
+		if b == nil {
+			b = append(make([]byte, 0, len(s)+16), s[:i]...)
+		}
+		b = append(b, '\\', 'x', hex[c>>4], hex[c&15])
+	}
+	if b == nil {
+		return s
+	}
+	return string(b)
+}
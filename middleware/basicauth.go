@@ -9,13 +9,21 @@ import (
 	"github.com/gomarten/marten"
 )
 
+// UserKey holds the user name authenticated by BasicAuth.
+var UserKey = marten.NewKey[string]("user")
+
+// BasicAuthScheme is the OpenAPI security scheme of BasicAuth. Declare it
+// with RouteInfo.Security or Group.Security on routes that use BasicAuth.
+var BasicAuthScheme = marten.SecurityScheme{Name: "basicAuth", Type: "http", Scheme: "basic"}
+
 // BasicAuthConfig configures basic authentication.
 type BasicAuthConfig struct {
 	Realm    string
 	Validate func(user, pass string) bool
 }
 
-// BasicAuth returns a basic authentication middleware.
+// BasicAuth returns a basic authentication middleware that stores the user
+// name with UserKey, and under "user" for c.Get.
 func BasicAuth(cfg BasicAuthConfig) marten.Middleware {
 	if cfg.Realm == "" {
 		cfg.Realm = "Restricted"
@@ -38,6 +46,7 @@ func BasicAuth(cfg BasicAuthConfig) marten.Middleware {
 				return unauthorized(c, cfg.Realm)
 			}
 
+			UserKey.Set(c, pair[0])
 			c.Set("user", pair[0])
 			return next(c)
 		}
@@ -56,5 +65,5 @@ func BasicAuthSimple(user, pass string) marten.Middleware {
 
 func unauthorized(c *marten.Ctx, realm string) error {
 	c.Header("WWW-Authenticate", `Basic realm="`+realm+`"`)
-	return c.JSON(http.StatusUnauthorized, marten.E("unauthorized"))
+	return c.Error(http.StatusUnauthorized, "unauthorized")
 }
@@ -20,7 +20,7 @@ func BodyLimit(maxSize int64) marten.Middleware {
 		return func(c *marten.Ctx) error {
 			// Check Content-Length if available (skip for chunked encoding where ContentLength = -1)
 			if c.Request.ContentLength > maxSize {
-				return c.JSON(http.StatusRequestEntityTooLarge, marten.E("request body too large"))
+				return c.Error(http.StatusRequestEntityTooLarge, "request body too large")
 			}
 
 			// Always wrap body to enforce limit during read (handles chunked encoding)
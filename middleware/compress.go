@@ -1,8 +1,10 @@
 package middleware
 
 import (
+	"bufio"
 	"compress/gzip"
 	"io"
+	"net"
 	"net/http"
 	"strings"
 	"sync"
@@ -52,7 +54,8 @@ func Compress(cfg CompressConfig) marten.Middleware {
 
 	return func(next marten.Handler) marten.Handler {
 		return func(c *marten.Ctx) error {
-			if !strings.Contains(c.Request.Header.Get("Accept-Encoding"), "gzip") {
+			// Event streams must reach the client unbuffered; WebSockets take over the connection
+			if !strings.Contains(c.Request.Header.Get("Accept-Encoding"), "gzip") || c.AcceptsEventStream() || c.IsWebSocket() {
 				return next(c)
 			}
 
@@ -120,6 +123,15 @@ func (w *gzipResponseWriter) Write(b []byte) (int, error) {
 		w.WriteHeader(http.StatusOK)
 	}
 
+	// Types that are never compressed (including event streams) pass through
+	if w.gw == nil && !w.shouldCompress() {
+		if len(w.buf) > 0 {
+			_, _ = w.ResponseWriter.Write(w.buf)
+			w.buf = nil
+		}
+		return w.ResponseWriter.Write(b)
+	}
+
 	if w.gw == nil && len(w.buf)+len(b) < w.cfg.MinSize {
 		w.buf = append(w.buf, b...)
 		return len(b), nil
@@ -157,9 +169,35 @@ func (w *gzipResponseWriter) Flush() {
 	if w.gw != nil {
 		w.gw.Flush()
 	}
-	if f, ok := w.ResponseWriter.(http.Flusher); ok {
-		f.Flush()
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack forwards to the underlying writer.
+func (w *gzipResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// ReadFrom copies r to the response. Responses that are not compressed use
+// the underlying writer's ReadFrom, if any.
+func (w *gzipResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.gw == nil && !w.shouldCompress() {
+		if len(w.buf) > 0 {
+			if _, err := w.ResponseWriter.Write(w.buf); err != nil {
+				return 0, err
+			}
+			w.buf = nil
+		}
+		return io.Copy(w.ResponseWriter, r)
 	}
+	return io.Copy(struct{ io.Writer }{w}, r)
+}
+
+// Unwrap returns the underlying writer for http.ResponseController.
+func (w *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
 }
 
 // Close flushes any remaining buffered data.
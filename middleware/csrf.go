@@ -0,0 +1,277 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gomarten/marten"
+)
+
+// CSRF errors passed to CSRFConfig.ErrorHandler.
+var (
+	ErrCSRFMissing = errors.New("missing CSRF token")
+	ErrCSRFInvalid = errors.New("invalid CSRF token")
+	ErrCSRFOrigin  = errors.New("cross-origin request rejected")
+)
+
+// CSRFStorage selects where the CSRF middleware keeps the expected token.
+type CSRFStorage int
+
+const (
+	// CSRFCookie uses the double-submit cookie pattern: the token is kept in
+	// a cookie readable by JavaScript, which sends it back in a header.
+	CSRFCookie CSRFStorage = iota
+	// CSRFSignedCookie keeps the token in an HttpOnly cookie signed with the
+	// app's keyring (see App.SetKeyring), so it cannot be planted by a
+	// sibling subdomain.
+	CSRFSignedCookie
+	// CSRFSession keeps the token in the session (see Session), which must
+	// run before CSRF.
+	CSRFSession
+)
+
+// CSRFConfig configures CSRF protection.
+type CSRFConfig struct {
+	// Storage selects where the token is kept (default: CSRFCookie)
+	Storage CSRFStorage
+	// CookieName names the token cookie (default: "_csrf")
+	CookieName     string
+	CookiePath     string // default: "/"
+	CookieDomain   string
+	CookieSecure   bool
+	CookieSameSite http.SameSite // default: http.SameSiteLaxMode
+	// HeaderName is checked first for the submitted token
+	// (default: "X-CSRF-Token")
+	HeaderName string
+	// FormField is checked next, in urlencoded and multipart bodies
+	// (default: "csrf_token"). Multipart bodies are parsed with
+	// Ctx.MultipartForm, so the app's MultipartConfig limits apply; stream
+	// uploads with EachPart must send the token in the header instead.
+	FormField string
+	// QueryParam is checked last (default: "csrf_token")
+	QueryParam string
+	// TrustedOrigins are other origins allowed to send unsafe requests,
+	// e.g. "https://admin.example.com".
+	TrustedOrigins []string
+	// ExemptPaths skips protection for matching paths, e.g. webhooks. A
+	// pattern ending in "/*" matches everything below it.
+	ExemptPaths []string
+	// Skip skips protection for certain requests
+	Skip func(*marten.Ctx) bool
+	// ErrorHandler writes the response for a rejected request
+	// (default: 403 with the error message)
+	ErrorHandler func(c *marten.Ctx, err error) error
+}
+
+// DefaultCSRFConfig returns sensible defaults.
+func DefaultCSRFConfig() CSRFConfig {
+	return CSRFConfig{
+		Storage:        CSRFCookie,
+		CookieName:     "_csrf",
+		CookiePath:     "/",
+		CookieSameSite: http.SameSiteLaxMode,
+		HeaderName:     "X-CSRF-Token",
+		FormField:      "csrf_token",
+		QueryParam:     "csrf_token",
+	}
+}
+
+// CSRF returns a middleware that protects against cross-site request
+// forgery. Safe methods (GET, HEAD, OPTIONS, TRACE) pass through and get a
+// token, available as c.CSRFToken() and {{csrfToken}} in templates. Other
+// methods are rejected if Sec-Fetch-Site, Origin or Referer show a
+// cross-origin request, or if the submitted token does not match.
+//
+//	app.Use(middleware.CSRF(middleware.CSRFConfig{ExemptPaths: []string{"/webhooks/*"}}))
+func CSRF(cfg CSRFConfig) marten.Middleware {
+	def := DefaultCSRFConfig()
+	if cfg.CookieName == "" {
+		cfg.CookieName = def.CookieName
+	}
+	if cfg.CookiePath == "" {
+		cfg.CookiePath = def.CookiePath
+	}
+	if cfg.CookieSameSite == 0 {
+		cfg.CookieSameSite = def.CookieSameSite
+	}
+	if cfg.HeaderName == "" {
+		cfg.HeaderName = def.HeaderName
+	}
+	if cfg.FormField == "" {
+		cfg.FormField = def.FormField
+	}
+	if cfg.QueryParam == "" {
+		cfg.QueryParam = def.QueryParam
+	}
+	if cfg.ErrorHandler == nil {
+		cfg.ErrorHandler = func(c *marten.Ctx, err error) error {
+			return c.Error(http.StatusForbidden, err.Error())
+		}
+	}
+	trusted := make(map[string]bool, len(cfg.TrustedOrigins))
+	for _, o := range cfg.TrustedOrigins {
+		trusted[strings.ToLower(strings.TrimSuffix(o, "/"))] = true
+	}
+
+	return func(next marten.Handler) marten.Handler {
+		return func(c *marten.Ctx) error {
+			if (cfg.Skip != nil && cfg.Skip(c)) || csrfExempt(cfg.ExemptPaths, c.Path()) {
+				return next(c)
+			}
+
+			token, err := csrfLoad(c, &cfg)
+			if err != nil {
+				return err
+			}
+
+			if !csrfSafeMethod(c.Method()) {
+				if !csrfSameOrigin(c, trusted) {
+					return cfg.ErrorHandler(c, ErrCSRFOrigin)
+				}
+				submitted, err := csrfSubmitted(c, &cfg)
+				if err != nil {
+					return err
+				}
+				if submitted == "" {
+					return cfg.ErrorHandler(c, ErrCSRFMissing)
+				}
+				if token == "" || subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) != 1 {
+					return cfg.ErrorHandler(c, ErrCSRFInvalid)
+				}
+			}
+
+			if token == "" {
+				token = randomToken()
+				if err := csrfStore(c, &cfg, token); err != nil {
+					return err
+				}
+			}
+			marten.CSRFKey.Set(c, token)
+			c.Set(marten.CSRFTokenKey, token)
+			return next(c)
+		}
+	}
+}
+
+// csrfLoad returns the expected token, or "" if the client has none yet.
+func csrfLoad(c *marten.Ctx, cfg *CSRFConfig) (string, error) {
+	switch cfg.Storage {
+	case CSRFSession:
+		s := c.Session()
+		if s == nil {
+			return "", errors.New("csrf: CSRFSession requires the Session middleware")
+		}
+		token, _ := s.Get(marten.CSRFTokenKey).(string)
+		return token, nil
+	case CSRFSignedCookie:
+		token, err := c.SignedCookie(cfg.CookieName)
+		if errors.Is(err, marten.ErrNoKeyring) {
+			return "", err
+		}
+		return token, nil
+	default:
+		return c.Cookie(cfg.CookieName), nil
+	}
+}
+
+func csrfStore(c *marten.Ctx, cfg *CSRFConfig, token string) error {
+	if cfg.Storage == CSRFSession {
+		c.Session().Set(marten.CSRFTokenKey, token)
+		return nil
+	}
+	ck := &http.Cookie{
+		Name:     cfg.CookieName,
+		Value:    token,
+		Path:     cfg.CookiePath,
+		Domain:   cfg.CookieDomain,
+		Secure:   cfg.CookieSecure,
+		HttpOnly: cfg.Storage == CSRFSignedCookie,
+		SameSite: cfg.CookieSameSite,
+	}
+	if cfg.Storage == CSRFSignedCookie {
+		return c.SetSignedCookie(ck)
+	}
+	c.SetCookie(ck)
+	return nil
+}
+
+// csrfSubmitted returns the token sent with the request. Bodies are read
+// the way Bind reads them, so their limits apply and the handler can still
+// use the parsed form.
+func csrfSubmitted(c *marten.Ctx, cfg *CSRFConfig) (string, error) {
+	if v := c.Request.Header.Get(cfg.HeaderName); v != "" {
+		return v, nil
+	}
+	ct := c.Request.Header.Get("Content-Type")
+	switch {
+	case strings.HasPrefix(ct, "application/x-www-form-urlencoded"):
+		if err := c.Request.ParseForm(); err != nil {
+			return "", &marten.BindError{Message: "invalid form data: " + err.Error()}
+		}
+		if v := c.Request.PostForm.Get(cfg.FormField); v != "" {
+			return v, nil
+		}
+	case strings.HasPrefix(ct, "multipart/form-data"):
+		form, err := c.MultipartForm()
+		if err != nil {
+			return "", err
+		}
+		if v := form.Value[cfg.FormField]; len(v) > 0 && v[0] != "" {
+			return v[0], nil
+		}
+	}
+	return c.Query(cfg.QueryParam), nil
+}
+
+// csrfSameOrigin checks the browser's Sec-Fetch-Site, then Origin, then
+// Referer header against c.Host(). Requests without any of them
+// (non-browser clients) rely on the token alone.
+func csrfSameOrigin(c *marten.Ctx, trusted map[string]bool) bool {
+	h := c.Request.Header
+	origin := h.Get("Origin")
+	if origin == "" {
+		if ref, err := url.Parse(h.Get("Referer")); err == nil && ref.Host != "" {
+			origin = ref.Scheme + "://" + ref.Host
+		}
+	}
+	if origin != "" && trusted[strings.ToLower(origin)] {
+		return true
+	}
+
+	switch h.Get("Sec-Fetch-Site") {
+	case "same-origin", "none":
+		return true
+	case "same-site", "cross-site":
+		return false
+	}
+
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host != "" && strings.EqualFold(u.Host, c.Host())
+}
+
+func csrfExempt(patterns []string, path string) bool {
+	for _, p := range patterns {
+		if prefix, ok := strings.CutSuffix(p, "/*"); ok {
+			if path == prefix || strings.HasPrefix(path, prefix+"/") {
+				return true
+			}
+		} else if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+func csrfSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
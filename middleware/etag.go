@@ -1,10 +1,14 @@
 package middleware
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/sha1"
 	"encoding/hex"
+	"io"
+	"net"
 	"net/http"
+	"strings"
 
 	"github.com/gomarten/marten"
 )
@@ -12,7 +16,7 @@ import (
 // ETag returns a middleware that adds ETag headers for caching.
 func ETag(next marten.Handler) marten.Handler {
 	return func(c *marten.Ctx) error {
-		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead || c.AcceptsEventStream() || c.IsWebSocket() {
 			return next(c)
 		}
 
@@ -26,6 +30,10 @@ func ETag(next marten.Handler) marten.Handler {
 		err := next(c)
 		c.Writer = origWriter
 
+		if ew.passthrough {
+			return err
+		}
+
 		if ew.status >= 200 && ew.status < 300 && ew.buf.Len() > 0 {
 			hash := sha1.Sum(ew.buf.Bytes())
 			etag := `"` + hex.EncodeToString(hash[:8]) + `"`
@@ -62,15 +70,60 @@ type etagWriter struct {
 	http.ResponseWriter
 	buf    *bytes.Buffer
 	status int
+	// passthrough is set for event streams, which are written directly
+	passthrough bool
 }
 
 func (w *etagWriter) WriteHeader(code int) {
+	if w.passthrough {
+		return
+	}
 	w.status = code
+	if strings.HasPrefix(w.Header().Get("Content-Type"), marten.MIMEEventStream) {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(code)
+	}
 }
 
 func (w *etagWriter) Write(b []byte) (int, error) {
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
 	if w.status == 0 {
-		w.status = http.StatusOK
+		w.WriteHeader(http.StatusOK)
+		if w.passthrough {
+			return w.ResponseWriter.Write(b)
+		}
 	}
 	return w.buf.Write(b)
 }
+
+// Flush forwards to the client only for event streams; other responses are
+// buffered until the handler returns.
+func (w *etagWriter) Flush() {
+	if !w.passthrough {
+		return
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack forwards to the underlying writer.
+func (w *etagWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// ReadFrom buffers r, or copies it to the underlying writer for event streams.
+func (w *etagWriter) ReadFrom(r io.Reader) (int64, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.passthrough {
+		return io.Copy(w.ResponseWriter, r)
+	}
+	return w.buf.ReadFrom(r)
+}
+
+// Unwrap returns the underlying writer for http.ResponseController.
+func (w *etagWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
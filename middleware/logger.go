@@ -32,7 +32,8 @@ func DefaultLoggerConfig() LoggerConfig {
 	}
 }
 
-// Logger logs request method, path, status code, and duration.
+// Logger logs request method, path, status code, and duration. See
+// AccessLog for structured logging with log/slog.
 func Logger(next marten.Handler) marten.Handler {
 	return LoggerWithConfig(DefaultLoggerConfig())(next)
 }
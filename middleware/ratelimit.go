@@ -122,7 +122,7 @@ func (rl *RateLimiter) Middleware() marten.Middleware {
 				if rl.cfg.OnLimitReached != nil {
 					return rl.cfg.OnLimitReached(c)
 				}
-				return c.JSON(http.StatusTooManyRequests, marten.E("rate limit exceeded"))
+				return c.Error(http.StatusTooManyRequests, "rate limit exceeded")
 			}
 
 			b.remaining--
@@ -41,6 +41,8 @@ func RecoverWithConfig(cfg RecoverConfig) marten.Middleware {
 
 					if cfg.OnPanic != nil {
 						err = cfg.OnPanic(c, r)
+					} else if c.ProblemDetails() {
+						err = c.Problem(marten.NewProblem(http.StatusInternalServerError, ""))
 					} else {
 						err = c.Text(http.StatusInternalServerError, "Internal Server Error")
 					}
@@ -60,10 +62,14 @@ func RecoverWithHandler(handler func(c *marten.Ctx, err any) error) marten.Middl
 }
 
 // RecoverJSON returns a recover middleware that returns JSON errors.
+// With problem details enabled, the panic value is reported as the detail.
 func RecoverJSON(next marten.Handler) marten.Handler {
 	return RecoverWithConfig(RecoverConfig{
 		LogPanics: true,
 		OnPanic: func(c *marten.Ctx, err any) error {
+			if c.ProblemDetails() {
+				return c.Problem(marten.NewProblem(http.StatusInternalServerError, fmt.Sprintf("%v", err)))
+			}
 			return c.JSON(http.StatusInternalServerError, marten.M{
 				"error":   "internal server error",
 				"message": fmt.Sprintf("%v", err),
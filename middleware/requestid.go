@@ -1,12 +1,184 @@
 package middleware
 
-import "github.com/gomarten/marten"
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"net/http"
+	"time"
 
-// RequestID adds a unique request ID to each request.
+	"github.com/gomarten/marten"
+)
+
+// RequestIDConfig configures the request ID middleware.
+type RequestIDConfig struct {
+	// Header is read for incoming IDs and set on the response
+	// (default: "X-Request-ID")
+	Header string
+	// Generator creates new IDs (default: 16 random hex characters). See
+	// UUIDv4, UUIDv7 and ULID.
+	Generator func() string
+	// Validator reports whether an incoming ID may be used
+	// (default: marten.ValidRequestID)
+	Validator func(id string) bool
+	// TrustIncoming uses a valid incoming ID instead of generating one.
+	// DefaultRequestIDConfig enables it; turn it off for public endpoints
+	// whose clients should not choose IDs.
+	TrustIncoming bool
+}
+
+// DefaultRequestIDConfig returns sensible defaults.
+func DefaultRequestIDConfig() RequestIDConfig {
+	return RequestIDConfig{
+		Header:        "X-Request-ID",
+		Validator:     marten.ValidRequestID,
+		TrustIncoming: true,
+	}
+}
+
+// RequestID adds a unique request ID to each request, reusing a valid
+// incoming X-Request-ID.
 func RequestID(next marten.Handler) marten.Handler {
-	return func(c *marten.Ctx) error {
-		id := c.RequestID()
-		c.Header("X-Request-ID", id)
-		return next(c)
+	return requestIDDefault(next)
+}
+
+var requestIDDefault = RequestIDWithConfig(DefaultRequestIDConfig())
+
+// RequestIDWithConfig returns a request ID middleware with configuration.
+// The ID is returned by c.RequestID(), set on the response header, and
+// stored with marten.RequestIDKey, so c.Context() carries it for
+// marten.RequestIDFromContext and RequestIDTransport.
+func RequestIDWithConfig(cfg RequestIDConfig) marten.Middleware {
+	def := DefaultRequestIDConfig()
+	if cfg.Header == "" {
+		cfg.Header = def.Header
+	}
+	if cfg.Generator == nil {
+		cfg.Generator = randomRequestID
+	}
+	if cfg.Validator == nil {
+		cfg.Validator = def.Validator
+	}
+
+	return func(next marten.Handler) marten.Handler {
+		return func(c *marten.Ctx) error {
+			id := ""
+			if cfg.TrustIncoming {
+				if in := c.Request.Header.Get(cfg.Header); cfg.Validator(in) {
+					id = in
+				}
+			}
+			if id == "" {
+				id = cfg.Generator()
+			}
+			c.SetRequestID(id)
+			marten.RequestIDKey.Set(c, id)
+			c.Header(cfg.Header, id)
+			return next(c)
+		}
+	}
+}
+
+// RequestIDTransport is an http.RoundTripper that forwards the request ID
+// of the outgoing request's context, so calls to other services can be
+// correlated.
+//
+//	client := &http.Client{Transport: &middleware.RequestIDTransport{}}
+//	req, _ := http.NewRequestWithContext(c.Context(), "GET", url, nil)
+//	resp, err := client.Do(req)
+type RequestIDTransport struct {
+	// Base sends the request (default: http.DefaultTransport)
+	Base http.RoundTripper
+	// Header carries the ID (default: "X-Request-ID")
+	Header string
+}
+
+// RoundTrip implements http.RoundTripper. An ID already set on the request
+// is kept.
+func (t *RequestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	header := t.Header
+	if header == "" {
+		header = "X-Request-ID"
+	}
+	if id := marten.RequestIDFromContext(req.Context()); id != "" && req.Header.Get(header) == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set(header, id)
+	}
+	return base.RoundTrip(req)
+}
+
+func randomRequestID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// UUIDv4 returns a random UUID (RFC 9562 version 4).
+func UUIDv4() string {
+	var u [16]byte
+	_, _ = rand.Read(u[:])
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return formatUUID(u)
+}
+
+// UUIDv7 returns a time-ordered UUID (RFC 9562 version 7): a millisecond
+// timestamp followed by random bits, so IDs sort by creation time.
+func UUIDv7() string {
+	var u [16]byte
+	_, _ = rand.Read(u[6:])
+	ms := uint64(time.Now().UnixMilli())
+	u[0], u[1], u[2] = byte(ms>>40), byte(ms>>32), byte(ms>>24)
+	u[3], u[4], u[5] = byte(ms>>16), byte(ms>>8), byte(ms)
+	u[6] = u[6]&0x0f | 0x70
+	u[8] = u[8]&0x3f | 0x80
+	return formatUUID(u)
+}
+
+func formatUUID(u [16]byte) string {
+	var b [36]byte
+	hex.Encode(b[0:8], u[0:4])
+	b[8] = '-'
+	hex.Encode(b[9:13], u[4:6])
+	b[13] = '-'
+	hex.Encode(b[14:18], u[6:8])
+	b[18] = '-'
+	hex.Encode(b[19:23], u[8:10])
+	b[23] = '-'
+	hex.Encode(b[24:], u[10:])
+	return string(b[:])
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID returns a ULID: a millisecond timestamp and 80 random bits in 26
+// characters of Crockford base32, sortable by creation time.
+func ULID() string {
+	var u [16]byte
+	_, _ = rand.Read(u[6:])
+	ms := uint64(time.Now().UnixMilli())
+	u[0], u[1], u[2] = byte(ms>>40), byte(ms>>32), byte(ms>>24)
+	u[3], u[4], u[5] = byte(ms>>16), byte(ms>>8), byte(ms)
+
+	hi, lo := binary.BigEndian.Uint64(u[:8]), binary.BigEndian.Uint64(u[8:])
+	var b [26]byte
+	for i := range b {
+		// 128 bits are encoded as 130, 5 bits per character
+		shift := uint(125 - 5*i)
+		var v uint64
+		switch {
+		case shift >= 64:
+			v = hi >> (shift - 64)
+		case shift+5 <= 64:
+			v = lo >> shift
+		default:
+			v = lo>>shift | hi<<(64-shift)
+		}
+		b[i] = crockford[v&31]
 	}
+	return string(b[:])
 }
@@ -0,0 +1,307 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/gob"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gomarten/marten"
+)
+
+// SessionConfig configures the session middleware.
+type SessionConfig struct {
+	// Store keeps session data (default: one MemoryStore shared by every
+	// Session middleware without a Store)
+	Store SessionStore
+	// CookieName is the name of the session cookie (default: "session")
+	CookieName   string
+	CookiePath   string // default: "/"
+	CookieDomain string
+	CookieSecure bool
+	// CookieSameSite defaults to http.SameSiteLaxMode
+	CookieSameSite http.SameSite
+	// IdleTimeout ends sessions that are not used for this long
+	// (default: 30 minutes)
+	IdleTimeout time.Duration
+	// AbsoluteTimeout ends sessions this long after they were created,
+	// whatever their use (default: 24 hours)
+	AbsoluteTimeout time.Duration
+}
+
+// DefaultSessionConfig returns sensible defaults.
+func DefaultSessionConfig() SessionConfig {
+	return SessionConfig{
+		CookieName:      "session",
+		CookiePath:      "/",
+		CookieSameSite:  http.SameSiteLaxMode,
+		IdleTimeout:     30 * time.Minute,
+		AbsoluteTimeout: 24 * time.Hour,
+	}
+}
+
+// Session returns a middleware that loads the client's session and makes it
+// available as c.Session(). The session is saved just before the response
+// is sent, and only if it was modified; changes made after that are lost.
+// To keep active sessions alive without a write per request, an unmodified
+// session is saved again once a quarter of IdleTimeout has passed since its
+// last save. If the handler writes nothing, a failed save is returned as the
+// request's error; a save that fails as the response starts can no longer
+// change it and is logged with c.Logger().
+//
+// The session cookie is HttpOnly. Values are encoded with encoding/gob;
+// register custom types with gob.Register.
+//
+//	app.Use(middleware.Session(middleware.SessionConfig{Store: middleware.NewCookieStore(keys)}))
+func Session(cfg SessionConfig) marten.Middleware {
+	def := DefaultSessionConfig()
+	if cfg.Store == nil {
+		cfg.Store = defaultSessionStore()
+	}
+	if cfg.CookieName == "" {
+		cfg.CookieName = def.CookieName
+	}
+	if cfg.CookiePath == "" {
+		cfg.CookiePath = def.CookiePath
+	}
+	if cfg.CookieSameSite == 0 {
+		cfg.CookieSameSite = def.CookieSameSite
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = def.IdleTimeout
+	}
+	if cfg.AbsoluteTimeout <= 0 {
+		cfg.AbsoluteTimeout = def.AbsoluteTimeout
+	}
+
+	return func(next marten.Handler) marten.Handler {
+		return func(c *marten.Ctx) error {
+			s, err := loadSession(c, &cfg)
+			if err != nil {
+				return err
+			}
+			marten.SessionKey.Set(c, s)
+
+			c.Response().BeforeWrite(func() {
+				if err := s.save(c); err != nil {
+					c.Logger().Error("session save failed", "error", err)
+				}
+			})
+			err = next(c)
+			if !c.Written() {
+				if saveErr := s.save(c); err == nil {
+					err = saveErr
+				}
+			}
+			return err
+		}
+	}
+}
+
+var (
+	defaultStoreOnce sync.Once
+	defaultStore     *MemoryStore
+)
+
+// defaultSessionStore returns the MemoryStore used when SessionConfig.Store
+// is nil. It is created once, so its cleanup goroutine is not started per
+// middleware.
+func defaultSessionStore() SessionStore {
+	defaultStoreOnce.Do(func() {
+		defaultStore = NewMemoryStore(0)
+	})
+	return defaultStore
+}
+
+// sessionData is the encoded form of a session.
+type sessionData struct {
+	Values  map[string]any
+	Flashes map[string][]string
+	Created time.Time
+	Saved   time.Time
+}
+
+type session struct {
+	cfg        *SessionConfig
+	id         string
+	data       sessionData
+	hadCookie  bool
+	modified   bool
+	regenerate bool
+	destroyed  bool
+	saved      bool
+}
+
+func newSessionData() sessionData {
+	return sessionData{
+		Values:  make(map[string]any),
+		Flashes: make(map[string][]string),
+		Created: time.Now(),
+	}
+}
+
+func loadSession(c *marten.Ctx, cfg *SessionConfig) (*session, error) {
+	s := &session{cfg: cfg, data: newSessionData()}
+	ck, err := c.Request.Cookie(cfg.CookieName)
+	if err != nil || ck.Value == "" {
+		return s, nil
+	}
+	s.hadCookie = true
+
+	id, data, err := cfg.Store.Load(c.Context(), ck.Value)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return s, nil
+	}
+	var d sessionData
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&d); err != nil {
+		return s, nil
+	}
+	now := time.Now()
+	if now.Sub(d.Saved) > cfg.IdleTimeout || now.Sub(d.Created) > cfg.AbsoluteTimeout {
+		return s, cfg.Store.Delete(c.Context(), id)
+	}
+	if d.Values == nil {
+		d.Values = make(map[string]any)
+	}
+	if d.Flashes == nil {
+		d.Flashes = make(map[string][]string)
+	}
+	s.id = id
+	s.data = d
+	return s, nil
+}
+
+func (s *session) ID() string {
+	return s.id
+}
+
+func (s *session) Get(key string) any {
+	return s.data.Values[key]
+}
+
+func (s *session) Set(key string, value any) {
+	s.touch()
+	s.data.Values[key] = value
+}
+
+func (s *session) Delete(key string) {
+	if _, ok := s.data.Values[key]; ok {
+		s.touch()
+		delete(s.data.Values, key)
+	}
+}
+
+func (s *session) Clear() {
+	s.touch()
+	clear(s.data.Values)
+	clear(s.data.Flashes)
+}
+
+func (s *session) AddFlash(key, msg string) {
+	s.touch()
+	s.data.Flashes[key] = append(s.data.Flashes[key], msg)
+}
+
+func (s *session) Flashes(key string) []string {
+	msgs, ok := s.data.Flashes[key]
+	if ok {
+		s.touch()
+		delete(s.data.Flashes, key)
+	}
+	return msgs
+}
+
+func (s *session) Regenerate() {
+	s.touch()
+	s.regenerate = true
+}
+
+// Destroy empties the session. Setting a value afterwards starts a new one
+// under a new ID.
+func (s *session) Destroy() {
+	s.destroyed = true
+	s.data = newSessionData()
+}
+
+func (s *session) touch() {
+	s.modified = true
+	if s.destroyed {
+		s.destroyed = false
+		s.regenerate = true
+	}
+}
+
+// save writes the session to the store and sets or removes the cookie. It
+// runs at most once per request.
+func (s *session) save(c *marten.Ctx) error {
+	if s.saved {
+		return nil
+	}
+	s.saved = true
+	ctx := c.Context()
+	now := time.Now()
+
+	if s.destroyed || (!s.modified && s.id == "") {
+		// Remove a destroyed or expired session from the client
+		if s.hadCookie {
+			c.SetCookie(s.cookie("", -1))
+		}
+		if s.id == "" {
+			return nil
+		}
+		return s.cfg.Store.Delete(ctx, s.id)
+	}
+	if !s.modified && now.Sub(s.data.Saved) < s.cfg.IdleTimeout/4 {
+		return nil
+	}
+
+	if s.regenerate && s.id != "" {
+		if err := s.cfg.Store.Delete(ctx, s.id); err != nil {
+			return err
+		}
+		s.id = ""
+	}
+	if s.id == "" {
+		s.id = randomToken()
+	}
+
+	s.data.Saved = now
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&s.data); err != nil {
+		return err
+	}
+	ttl := min(s.cfg.IdleTimeout, s.cfg.AbsoluteTimeout-now.Sub(s.data.Created))
+	ttl = max(ttl, time.Second)
+	value, err := s.cfg.Store.Save(ctx, s.id, buf.Bytes(), ttl)
+	if err != nil {
+		return err
+	}
+	c.SetCookie(s.cookie(value, int((ttl+time.Second-1)/time.Second)))
+	return nil
+}
+
+func (s *session) cookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     s.cfg.CookieName,
+		Value:    value,
+		Path:     s.cfg.CookiePath,
+		Domain:   s.cfg.CookieDomain,
+		MaxAge:   maxAge,
+		Secure:   s.cfg.CookieSecure,
+		HttpOnly: true,
+		SameSite: s.cfg.CookieSameSite,
+	}
+}
+
+// randomToken returns 32 random bytes, URL-safe base64 encoded.
+func randomToken() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,296 @@
+package middleware
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomarten/marten"
+)
+
+// ErrSessionTooLarge is returned by CookieStore when a session does not fit
+// in a cookie.
+var ErrSessionTooLarge = errors.New("session: data too large for a cookie")
+
+// SessionStore loads and saves encoded sessions for the Session middleware.
+// The cookie value it deals in is the session ID for server-side stores;
+// CookieStore keeps the data itself in the cookie.
+type SessionStore interface {
+	// Load returns the session ID and data for a cookie value, or nil data
+	// if the session is unknown or has expired.
+	Load(ctx context.Context, value string) (id string, data []byte, err error)
+	// Save stores data under id for ttl and returns the cookie value.
+	Save(ctx context.Context, id string, data []byte, ttl time.Duration) (value string, err error)
+	// Delete removes the session with id.
+	Delete(ctx context.Context, id string) error
+}
+
+// MemoryStore keeps sessions in memory. Expired sessions are evicted by a
+// cleanup goroutine.
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]memorySession
+	cancel   context.CancelFunc
+}
+
+type memorySession struct {
+	data    []byte
+	expires time.Time
+}
+
+// NewMemoryStore creates a memory store that evicts expired sessions every
+// cleanupInterval (default: 1 minute). Call Stop when done.
+func NewMemoryStore(cleanupInterval time.Duration) *MemoryStore {
+	if cleanupInterval <= 0 {
+		cleanupInterval = time.Minute
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &MemoryStore{
+		sessions: make(map[string]memorySession),
+		cancel:   cancel,
+	}
+
+	go func() {
+		ticker := time.NewTicker(cleanupInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.cleanup()
+			}
+		}
+	}()
+
+	return s
+}
+
+// Stop stops the cleanup goroutine.
+func (s *MemoryStore) Stop() {
+	s.cancel()
+}
+
+// Len returns the number of stored sessions, including expired ones not yet
+// evicted.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+func (s *MemoryStore) cleanup() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for id, sess := range s.sessions {
+		if now.After(sess.expires) {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+// Load implements SessionStore.
+func (s *MemoryStore) Load(_ context.Context, value string) (string, []byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[value]
+	if !ok {
+		return "", nil, nil
+	}
+	if time.Now().After(sess.expires) {
+		delete(s.sessions, value)
+		return "", nil, nil
+	}
+	return value, sess.data, nil
+}
+
+// Save implements SessionStore.
+func (s *MemoryStore) Save(_ context.Context, id string, data []byte, ttl time.Duration) (string, error) {
+	s.mu.Lock()
+	s.sessions[id] = memorySession{data: data, expires: time.Now().Add(ttl)}
+	s.mu.Unlock()
+	return id, nil
+}
+
+// Delete implements SessionStore.
+func (s *MemoryStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	delete(s.sessions, id)
+	s.mu.Unlock()
+	return nil
+}
+
+// FileStore keeps each session in a file in a directory. Expired files are
+// removed when read or by Cleanup.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates a file store in dir, creating it if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Load implements SessionStore.
+func (s *FileStore) Load(_ context.Context, value string) (string, []byte, error) {
+	if !validSessionID(value) {
+		return "", nil, nil
+	}
+	data, err := s.read(value)
+	if data == nil || err != nil {
+		return "", nil, err
+	}
+	return value, data, nil
+}
+
+// Save implements SessionStore. Files are replaced atomically.
+func (s *FileStore) Save(_ context.Context, id string, data []byte, ttl time.Duration) (string, error) {
+	if !validSessionID(id) {
+		return "", errors.New("session: invalid session id")
+	}
+	f, err := os.CreateTemp(s.dir, ".tmp-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+
+	var header [8]byte
+	binary.BigEndian.PutUint64(header[:], uint64(time.Now().Add(ttl).UnixNano()))
+	if _, err := f.Write(header[:]); err != nil {
+		f.Close()
+		return "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(f.Name(), filepath.Join(s.dir, id)); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Delete implements SessionStore.
+func (s *FileStore) Delete(_ context.Context, id string) error {
+	if !validSessionID(id) {
+		return nil
+	}
+	if err := os.Remove(filepath.Join(s.dir, id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Cleanup removes the files of expired sessions. Run it periodically.
+func (s *FileStore) Cleanup() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() || !validSessionID(e.Name()) {
+			continue
+		}
+		if _, err := s.read(e.Name()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// read returns the session data in the file for id, removing it if it has
+// expired.
+func (s *FileStore) read(id string) ([]byte, error) {
+	path := filepath.Join(s.dir, id)
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 8 || time.Now().UnixNano() > int64(binary.BigEndian.Uint64(b)) {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		return nil, nil
+	}
+	return b[8:], nil
+}
+
+// validSessionID reports whether id looks like a generated session ID, so
+// that cookie values cannot name other files.
+func validSessionID(id string) bool {
+	if len(id) != 43 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// CookieStore keeps the whole session in the session cookie, encrypted and
+// authenticated with a keyring, so nothing is stored on the server. Sessions
+// must stay under about 4 KB once encoded. Deleting is a no-op: a copy of an
+// old cookie stays valid until it expires.
+type CookieStore struct {
+	keys *marten.Keyring
+}
+
+// cookieSessionName binds encrypted values to their use.
+const cookieSessionName = "marten.session"
+
+// maxCookieValue leaves room for the cookie name and attributes within the
+// 4096 bytes browsers accept.
+const maxCookieValue = 3900
+
+// NewCookieStore creates a cookie store using keys, usually app.Keyring().
+func NewCookieStore(keys *marten.Keyring) *CookieStore {
+	return &CookieStore{keys: keys}
+}
+
+// Load implements SessionStore.
+func (s *CookieStore) Load(_ context.Context, value string) (string, []byte, error) {
+	b, err := s.keys.Decrypt(cookieSessionName, value)
+	if err != nil {
+		return "", nil, nil
+	}
+	id, data, ok := strings.Cut(string(b), ".")
+	if !ok {
+		return "", nil, nil
+	}
+	return id, []byte(data), nil
+}
+
+// Save implements SessionStore.
+func (s *CookieStore) Save(_ context.Context, id string, data []byte, ttl time.Duration) (string, error) {
+	value, err := s.keys.Encrypt(cookieSessionName, append([]byte(id+"."), data...), ttl)
+	if err != nil {
+		return "", err
+	}
+	if len(value) > maxCookieValue {
+		return "", ErrSessionTooLarge
+	}
+	return value, nil
+}
+
+// Delete implements SessionStore.
+func (s *CookieStore) Delete(context.Context, string) error {
+	return nil
+}
@@ -3,7 +3,6 @@ package middleware
 import (
 	"fmt"
 	"html"
-	"mime"
 	"net/http"
 	"os"
 	"path"
@@ -125,61 +124,15 @@ func StaticWithConfig(cfg StaticConfig) marten.Middleware {
 	}
 }
 
-// serveFile serves a single file with proper headers.
+// serveFile serves a single file with cache headers. Range and conditional
+// requests are handled by Ctx.SendFile.
 func serveFile(c *marten.Ctx, filePath string, cfg StaticConfig) error {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return c.ServerError("failed to open file")
-	}
-	defer file.Close()
-
-	stat, err := file.Stat()
-	if err != nil {
-		return c.ServerError("failed to stat file")
-	}
-
-	// Set content type based on extension
-	ext := filepath.Ext(filePath)
-	contentType := mime.TypeByExtension(ext)
-	if contentType == "" {
-		contentType = "application/octet-stream"
-	}
-	c.Header("Content-Type", contentType)
-
-	// Set Content-Length for download progress
-	c.Header("Content-Length", fmt.Sprintf("%d", stat.Size()))
-
-	// Set cache headers
 	if cfg.MaxAge > 0 {
 		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", cfg.MaxAge))
 	} else {
 		c.Header("Cache-Control", "no-cache")
 	}
-
-	// Set Last-Modified header
-	c.Header("Last-Modified", stat.ModTime().UTC().Format(http.TimeFormat))
-
-	// Check If-Modified-Since header
-	if modifiedSince := c.GetHeader("If-Modified-Since"); modifiedSince != "" {
-		if t, err := http.ParseTime(modifiedSince); err == nil {
-			// Truncate both times to seconds for comparison
-			modTime := stat.ModTime().Truncate(1000000000) // 1 second in nanoseconds
-			reqTime := t.Truncate(1000000000)
-			if modTime.Equal(reqTime) || modTime.Before(reqTime) {
-				c.Status(http.StatusNotModified)
-				return nil
-			}
-		}
-	}
-
-	// For HEAD requests, don't send body
-	if c.Method() == http.MethodHead {
-		c.Status(http.StatusOK)
-		return nil
-	}
-
-	// Serve file
-	return c.Stream(http.StatusOK, contentType, file)
+	return c.SendFile(filePath)
 }
 
 // serveDirListing serves a directory listing.
@@ -1,7 +1,10 @@
 package middleware
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"net"
 	"net/http"
 	"sync"
 	"time"
@@ -9,84 +12,212 @@ import (
 	"github.com/gomarten/marten"
 )
 
+// TimeoutConfig configures the timeout middleware.
+type TimeoutConfig struct {
+	Timeout time.Duration
+	// OnTimeout writes the response sent when the timeout is reached
+	// (default: 504 "request timeout").
+	OnTimeout func(c *marten.Ctx) error
+	// Streaming reports whether a request should bypass response buffering.
+	// Such requests run on the calling goroutine and write directly to the
+	// client; only the context deadline is applied (optional).
+	Streaming func(c *marten.Ctx) bool
+	// Skip runs matching requests without a timeout, e.g. event stream and
+	// WebSocket routes (optional). Match on the route rather than request
+	// headers, which the client controls.
+	Skip func(c *marten.Ctx) bool
+}
+
 // Timeout returns a middleware that times out requests.
-// The handler will be cancelled when the timeout is reached.
+// The handler runs on its own goroutine against a buffered response; either
+// the handler's response or the timeout response is sent, never both.
+// Writes after the timeout return http.ErrHandlerTimeout. A panic in the
+// handler is re-raised on the request's goroutine, so put Recover before
+// Timeout to handle it.
+//
+// A handler that flushes or hijacks the connection, as c.SSE and c.Upgrade
+// do, streams directly to the client from then on and is never replaced by
+// the timeout response, but its context still ends at the deadline. Use
+// TimeoutConfig.Skip, or apply Timeout per route, to leave long-lived event
+// streams and WebSockets without a timeout.
 func Timeout(d time.Duration) marten.Middleware {
+	return TimeoutWithConfig(TimeoutConfig{Timeout: d})
+}
+
+// TimeoutWithConfig returns a timeout middleware with configuration.
+func TimeoutWithConfig(cfg TimeoutConfig) marten.Middleware {
+	if cfg.OnTimeout == nil {
+		cfg.OnTimeout = func(c *marten.Ctx) error {
+			return c.Error(http.StatusGatewayTimeout, "request timeout")
+		}
+	}
+
 	return func(next marten.Handler) marten.Handler {
 		return func(c *marten.Ctx) error {
-			ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+			if cfg.Skip != nil && cfg.Skip(c) {
+				return next(c)
+			}
+
+			ctx, cancel := context.WithTimeout(c.Request.Context(), cfg.Timeout)
 			defer cancel()
 
-			c.Request = c.Request.WithContext(ctx)
+			if cfg.Streaming != nil && cfg.Streaming(c) {
+				c.Request = c.Request.WithContext(ctx)
+				return next(c)
+			}
 
-			done := make(chan error, 1)
+			tw := &timeoutWriter{c: c, header: make(http.Header)}
+			fork := c.Fork(tw, c.Request.WithContext(ctx))
 
+			done := make(chan error, 1)
+			panicked := make(chan any, 1)
 			go func() {
 				defer func() {
 					if r := recover(); r != nil {
-						done <- c.JSON(http.StatusInternalServerError, marten.E("internal error"))
+						panicked <- r
 					}
 				}()
-				done <- next(c)
+				done <- next(fork)
 			}()
 
 			select {
 			case err := <-done:
-				return err
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				c.Join(fork)
+				return tw.commit(c, err)
+			case r := <-panicked:
+				tw.mu.Lock()
+				tw.timedOut = true
+				tw.mu.Unlock()
+				// Re-panic on the request's goroutine so Recover sees it.
+				panic(r)
 			case <-ctx.Done():
-				return c.JSON(http.StatusGatewayTimeout, marten.E("request timeout"))
+				tw.mu.Lock()
+				if tw.streaming {
+					// The response is already on the wire; let the handler
+					// see the cancelled context and finish.
+					tw.mu.Unlock()
+					select {
+					case err := <-done:
+						c.Join(fork)
+						return err
+					case r := <-panicked:
+						panic(r)
+					}
+				}
+				tw.timedOut = true
+				tw.mu.Unlock()
+				return cfg.OnTimeout(c)
 			}
 		}
 	}
 }
 
-// TimeoutWithConfig returns a timeout middleware with custom error handler.
-type TimeoutConfig struct {
-	Timeout time.Duration
-	OnTimeout func(c *marten.Ctx) error
+// timeoutWriter buffers a handler's response until it is committed or
+// discarded. Flush and Hijack commit it early and switch to streaming, after
+// which writes go straight to c.Writer.
+type timeoutWriter struct {
+	mu          sync.Mutex
+	c           *marten.Ctx
+	header      http.Header
+	buf         bytes.Buffer
+	code        int
+	wroteHeader bool
+	timedOut    bool
+	streaming   bool
 }
 
-// TimeoutWithConfig returns a timeout middleware with configuration.
-func TimeoutWithConfig(cfg TimeoutConfig) marten.Middleware {
-	if cfg.OnTimeout == nil {
-		cfg.OnTimeout = func(c *marten.Ctx) error {
-			return c.JSON(http.StatusGatewayTimeout, marten.E("request timeout"))
-		}
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if w.streaming {
+		return w.c.Writer.Write(b)
 	}
+	if !w.wroteHeader {
+		w.writeHeaderLocked(http.StatusOK)
+	}
+	return w.buf.Write(b)
+}
 
-	return func(next marten.Handler) marten.Handler {
-		return func(c *marten.Ctx) error {
-			ctx, cancel := context.WithTimeout(c.Request.Context(), cfg.Timeout)
-			defer cancel()
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut || w.wroteHeader || w.streaming {
+		return
+	}
+	w.writeHeaderLocked(code)
+}
 
-			c.Request = c.Request.WithContext(ctx)
+// Flush commits the buffered response and flushes it to the client.
+func (w *timeoutWriter) Flush() {
+	_ = w.FlushError()
+}
 
-			var (
-				handlerErr error
-				once       sync.Once
-				done       = make(chan struct{})
-			)
+// FlushError is Flush with an error, as used by http.ResponseController.
+func (w *timeoutWriter) FlushError() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return http.ErrHandlerTimeout
+	}
+	if !w.streaming {
+		if !w.wroteHeader {
+			w.writeHeaderLocked(http.StatusOK)
+		}
+		if err := w.commit(w.c, nil); err != nil {
+			return err
+		}
+		w.streaming = true
+	}
+	return http.NewResponseController(w.c.Writer).Flush()
+}
 
-			go func() {
-				defer close(done)
-				defer func() {
-					if r := recover(); r != nil {
-						once.Do(func() {
-							handlerErr = c.JSON(http.StatusInternalServerError, marten.E("internal error"))
-						})
-					}
-				}()
-				once.Do(func() {
-					handlerErr = next(c)
-				})
-			}()
+// Hijack hands the connection to the handler, discarding anything buffered.
+func (w *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return nil, nil, http.ErrHandlerTimeout
+	}
+	conn, brw, err := http.NewResponseController(w.c.Writer).Hijack()
+	if err == nil {
+		w.streaming = true
+	}
+	return conn, brw, err
+}
 
-			select {
-			case <-done:
-				return handlerErr
-			case <-ctx.Done():
-				return cfg.OnTimeout(c)
-			}
+func (w *timeoutWriter) writeHeaderLocked(code int) {
+	w.wroteHeader = true
+	w.code = code
+}
+
+// commit copies the buffered response to c. The caller must hold w.mu.
+// If the handler wrote nothing, err is returned for the app's error handler.
+func (w *timeoutWriter) commit(c *marten.Ctx, err error) error {
+	if w.streaming {
+		return err
+	}
+	if !w.wroteHeader {
+		for k, v := range w.header {
+			c.Writer.Header()[k] = v
 		}
+		return err
+	}
+	dst := c.Writer.Header()
+	for k, v := range w.header {
+		dst[k] = v
+	}
+	c.Status(w.code)
+	if _, werr := c.Writer.Write(w.buf.Bytes()); werr != nil && err == nil {
+		err = werr
 	}
+	return err
 }
@@ -0,0 +1,415 @@
+package marten
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Upload errors. They are wrapped in an HTTPError with status 413 (or 415
+// for ErrFileType), so handlers can return them as-is.
+var (
+	ErrFileTooLarge   = errors.New("marten: file too large")
+	ErrTooManyFiles   = errors.New("marten: too many files")
+	ErrUploadTooLarge = errors.New("marten: upload too large")
+	ErrFileType       = errors.New("marten: file type not allowed")
+)
+
+// defaultMaxMemory is the multipart memory limit used when none is configured.
+const defaultMaxMemory = 32 << 20 // 32MB
+
+// sniffLen is the number of bytes used to detect a file's content type.
+const sniffLen = 512
+
+// MultipartConfig limits multipart uploads. Zero values mean no limit,
+// except MaxMemory.
+type MultipartConfig struct {
+	// MaxMemory is the number of bytes of a parsed form kept in memory; larger
+	// files are stored in temporary files (default: 32MB).
+	MaxMemory int64
+	// MaxFileSize is the maximum size of a single file. EachPart stops
+	// reading a file at the limit; MultipartForm checks it after the form is
+	// parsed, so bound what reaches memory and disk with MaxTotalSize.
+	MaxFileSize int64
+	// MaxFiles is the maximum number of files in a request.
+	MaxFiles int
+	// MaxTotalSize is the maximum size of the whole request body.
+	MaxTotalSize int64
+	// AllowedTypes lists content types allowed for files, detected by
+	// sniffing the file's first bytes, e.g. "image/png" or "image/*".
+	AllowedTypes []string
+}
+
+// SetMultipartConfig sets the limits used by Bind, MultipartForm,
+// MultipartReader and EachPart.
+func (a *App) SetMultipartConfig(cfg MultipartConfig) {
+	a.multipartConfig = cfg
+}
+
+func (c *Ctx) multipartConfig() MultipartConfig {
+	var cfg MultipartConfig
+	if c.app != nil {
+		cfg = c.app.multipartConfig
+	}
+	if cfg.MaxMemory <= 0 {
+		cfg.MaxMemory = defaultMaxMemory
+	}
+	return cfg
+}
+
+// MultipartForm parses a multipart/form-data body and enforces the app's
+// MultipartConfig. The whole form is parsed before MaxFileSize, MaxFiles and
+// AllowedTypes are checked; use EachPart to stream large uploads instead.
+// Temporary files are removed when the request ends. A form that fails a
+// limit is discarded, and later calls return the same error.
+func (c *Ctx) MultipartForm() (*multipart.Form, error) {
+	c.checkLive()
+	if c.formErr != nil {
+		return nil, c.formErr
+	}
+	if c.Request.MultipartForm != nil {
+		return c.Request.MultipartForm, nil
+	}
+	cfg := c.multipartConfig()
+	c.limitBody(cfg)
+	if err := c.Request.ParseMultipartForm(cfg.MaxMemory); err != nil {
+		if uerr := uploadError(err); uerr != err {
+			return nil, uerr
+		}
+		return nil, &BindError{Message: "invalid multipart data: " + err.Error()}
+	}
+
+	form := c.Request.MultipartForm
+	c.requestCleanup().addForm(form)
+	files := 0
+	for _, fhs := range form.File {
+		for _, fh := range fhs {
+			files++
+			if err := checkFile(cfg, files, fh); err != nil {
+				_ = form.RemoveAll()
+				c.Request.MultipartForm = nil
+				c.formErr = err
+				return nil, err
+			}
+		}
+	}
+	return form, nil
+}
+
+// cleanup holds the temporary files of a request's parsed forms. Forks share
+// their parent's cleanup, so forms parsed on another goroutine or through a
+// replaced c.Request are still removed when the request ends.
+type cleanup struct {
+	mu    sync.Mutex
+	forms []*multipart.Form
+	done  bool
+}
+
+func (c *Ctx) requestCleanup() *cleanup {
+	if c.cleanup == nil {
+		c.cleanup = &cleanup{}
+	}
+	return c.cleanup
+}
+
+func (cl *cleanup) addForm(form *multipart.Form) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if cl.done {
+		// The request already ended, e.g. a timed-out fork parsed late
+		_ = form.RemoveAll()
+		return
+	}
+	cl.forms = append(cl.forms, form)
+}
+
+// run removes the temporary files of all parsed forms.
+func (cl *cleanup) run() {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	cl.done = true
+	for _, form := range cl.forms {
+		_ = form.RemoveAll()
+	}
+	cl.forms = nil
+}
+
+// checkFile validates a parsed file against the limits.
+func checkFile(cfg MultipartConfig, n int, fh *multipart.FileHeader) error {
+	if cfg.MaxFiles > 0 && n > cfg.MaxFiles {
+		return tooManyFiles(cfg.MaxFiles)
+	}
+	if cfg.MaxFileSize > 0 && fh.Size > cfg.MaxFileSize {
+		return fileTooLarge(fh.Filename, cfg.MaxFileSize)
+	}
+	if len(cfg.AllowedTypes) == 0 {
+		return nil
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	buf := make([]byte, sniffLen)
+	size, _ := io.ReadFull(f, buf)
+	return checkType(cfg, fh.Filename, http.DetectContentType(buf[:size]))
+}
+
+// MultipartReader returns a reader for streaming a multipart body part by
+// part. MaxTotalSize is enforced; see EachPart for the other limits.
+func (c *Ctx) MultipartReader() (*multipart.Reader, error) {
+	c.checkLive()
+	c.limitBody(c.multipartConfig())
+	mr, err := c.Request.MultipartReader()
+	if err != nil {
+		return nil, &BindError{Message: "invalid multipart data: " + err.Error()}
+	}
+	return mr, nil
+}
+
+// Part is a streamed multipart part. Reads from file parts are limited by
+// MaxFileSize.
+type Part struct {
+	*multipart.Part
+	// ContentType is the sniffed content type of a file, or the declared
+	// Content-Type of a form field.
+	ContentType string
+	r           io.Reader
+}
+
+// Read reads the part's body.
+func (p *Part) Read(b []byte) (int, error) {
+	return p.r.Read(b)
+}
+
+// IsFile reports whether the part is a file upload.
+func (p *Part) IsFile() bool {
+	return p.FileName() != ""
+}
+
+// Value reads a form field's value, up to the configured MaxMemory.
+func (p *Part) Value() (string, error) {
+	b, err := io.ReadAll(p)
+	return string(b), err
+}
+
+// SaveTo streams the part to dst, which may be a directory (then the file is
+// named SafeFilename(FileName())). A partially written file is removed on error.
+func (p *Part) SaveTo(dst string) (int64, error) {
+	return saveFile(p, dst, p.FileName())
+}
+
+// EachPart streams a multipart body, calling fn for each part in order.
+// File parts are checked against MaxFiles and AllowedTypes before fn is
+// called; reading a part beyond MaxFileSize or the body beyond MaxTotalSize
+// returns an error that responds with 413.
+//
+//	err := c.EachPart(func(p *marten.Part) error {
+//		if !p.IsFile() {
+//			return nil
+//		}
+//		_, err := p.SaveTo("uploads/")
+//		return err
+//	})
+func (c *Ctx) EachPart(fn func(p *Part) error) error {
+	mr, err := c.MultipartReader()
+	if err != nil {
+		return err
+	}
+	cfg := c.multipartConfig()
+	files := 0
+	for {
+		mp, err := mr.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return uploadError(err)
+		}
+
+		p := &Part{Part: mp}
+		if p.IsFile() {
+			files++
+			if cfg.MaxFiles > 0 && files > cfg.MaxFiles {
+				return tooManyFiles(cfg.MaxFiles)
+			}
+			buf := make([]byte, sniffLen)
+			n, err := io.ReadFull(mp, buf)
+			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+				return uploadError(err)
+			}
+			p.ContentType = http.DetectContentType(buf[:n])
+			if err := checkType(cfg, p.FileName(), p.ContentType); err != nil {
+				return err
+			}
+			p.r = io.MultiReader(bytes.NewReader(buf[:n]), mp)
+			if cfg.MaxFileSize > 0 {
+				p.r = &sizeLimitReader{r: p.r, n: cfg.MaxFileSize, err: fileTooLarge(p.FileName(), cfg.MaxFileSize)}
+			}
+		} else {
+			p.ContentType = mp.Header.Get("Content-Type")
+			p.r = &sizeLimitReader{r: mp, n: cfg.MaxMemory, err: NewHTTPError(http.StatusRequestEntityTooLarge, "form value too large")}
+		}
+
+		err = fn(p)
+		mp.Close()
+		if err != nil {
+			return uploadError(err)
+		}
+	}
+}
+
+// SaveUploadedFile copies an uploaded file to dst. If dst is a directory (or
+// ends with a separator), the file is saved in it as SafeFilename(fh.Filename).
+func (c *Ctx) SaveUploadedFile(fh *multipart.FileHeader, dst string) error {
+	f, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = saveFile(f, dst, fh.Filename)
+	return err
+}
+
+// saveFile writes r to dst, or to dst/SafeFilename(name) for directories.
+func saveFile(r io.Reader, dst, name string) (int64, error) {
+	if strings.HasSuffix(dst, "/") || strings.HasSuffix(dst, string(filepath.Separator)) {
+		if err := os.MkdirAll(dst, 0o755); err != nil {
+			return 0, err
+		}
+	}
+	if info, err := os.Stat(dst); err == nil && info.IsDir() {
+		dst = filepath.Join(dst, SafeFilename(name))
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return n, err
+	}
+	return n, nil
+}
+
+// SafeFilename reduces a client-supplied file name to a safe base name: path
+// components, control characters and characters reserved on common file
+// systems are removed, leading dots are stripped (no hidden files or ".."),
+// and the result is at most 255 bytes. An empty result becomes "file".
+func SafeFilename(name string) string {
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r == utf8.RuneError, unicode.IsControl(r):
+			return -1
+		case strings.ContainsRune(`<>:"|?*`, r):
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimLeft(strings.TrimSpace(name), ".")
+	name = strings.TrimRight(name, ". ")
+
+	if len(name) > 255 {
+		ext := filepath.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		base := name[:255-len(ext)]
+		for !utf8.ValidString(base) {
+			base = base[:len(base)-1]
+		}
+		name = base + ext
+	}
+	if name == "" {
+		return "file"
+	}
+	return name
+}
+
+// limitBody applies MaxTotalSize to the request body.
+func (c *Ctx) limitBody(cfg MultipartConfig) {
+	if cfg.MaxTotalSize > 0 && c.Request.Body != nil {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, cfg.MaxTotalSize)
+	}
+}
+
+// checkType reports ErrFileType unless contentType matches AllowedTypes.
+func checkType(cfg MultipartConfig, filename, contentType string) error {
+	if len(cfg.AllowedTypes) == 0 {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = contentType
+	}
+	for _, allowed := range cfg.AllowedTypes {
+		if strings.EqualFold(allowed, mediaType) {
+			return nil
+		}
+		if prefix, ok := strings.CutSuffix(allowed, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return nil
+		}
+	}
+	return NewHTTPError(http.StatusUnsupportedMediaType, "file type "+mediaType+" is not allowed").
+		WithInternal(fmt.Errorf("%s: %w", filename, ErrFileType))
+}
+
+func fileTooLarge(filename string, max int64) error {
+	return NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("file exceeds %d bytes", max)).
+		WithInternal(fmt.Errorf("%s: %w", filename, ErrFileTooLarge))
+}
+
+func tooManyFiles(max int) error {
+	return NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("at most %d files are allowed", max)).
+		WithInternal(ErrTooManyFiles)
+}
+
+// uploadError converts body size errors into a 413 HTTPError.
+func uploadError(err error) error {
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		return NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("upload exceeds %d bytes", mbe.Limit)).
+			WithInternal(fmt.Errorf("%w: %w", ErrUploadTooLarge, err))
+	}
+	return err
+}
+
+// sizeLimitReader returns err once more than n bytes are read.
+type sizeLimitReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (l *sizeLimitReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, l.err
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n + int(l.n), l.err
+	}
+	return n, err
+}
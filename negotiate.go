@@ -0,0 +1,227 @@
+package marten
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Common media types.
+const (
+	MIMEJSON = "application/json"
+	MIMEXML  = "application/xml"
+	MIMEText = "text/plain"
+	MIMEHTML = "text/html"
+)
+
+// FormatFunc writes v to w in a particular media type.
+type FormatFunc func(w io.Writer, v any) error
+
+// format is a registered media type and its encoder.
+type format struct {
+	mediaType string
+	fn        FormatFunc
+}
+
+// defaultFormats returns the built-in formats in preference order.
+// The JSON format has a nil fn: it is encoded with the app's JSON codec.
+func defaultFormats() []format {
+	return []format{
+		{MIMEJSON, nil},
+		{MIMEXML, func(w io.Writer, v any) error {
+			if _, err := io.WriteString(w, xml.Header); err != nil {
+				return err
+			}
+			return xml.NewEncoder(w).Encode(v)
+		}},
+		{MIMEText, func(w io.Writer, v any) error {
+			_, err := fmt.Fprint(w, v)
+			return err
+		}},
+		{MIMEHTML, func(w io.Writer, v any) error {
+			s, ok := v.(template.HTML)
+			if !ok {
+				s = template.HTML(html.EscapeString(fmt.Sprint(v)))
+			}
+			_, err := io.WriteString(w, string(s))
+			return err
+		}},
+	}
+}
+
+// RegisterFormat registers (or replaces) the encoder used by Ctx.Negotiate
+// for a media type. New formats are offered after the existing ones.
+//
+//	app.RegisterFormat("text/csv", func(w io.Writer, v any) error { ... })
+func (a *App) RegisterFormat(mediaType string, fn FormatFunc) {
+	for i, f := range a.formats {
+		if strings.EqualFold(f.mediaType, mediaType) {
+			a.formats[i].fn = fn
+			return
+		}
+	}
+	a.formats = append(a.formats, format{mediaType, fn})
+}
+
+// Negotiate writes data in the format that best matches the request's Accept
+// header (with q-values and wildcards). offers restricts the candidate media
+// types; by default all registered formats are offered (JSON, XML, plain
+// text, HTML, then custom formats). It sets Vary: Accept and responds with
+// 406 Not Acceptable when no offer is acceptable.
+func (c *Ctx) Negotiate(code int, data any, offers ...string) error {
+	c.checkLive()
+	formats := c.formats()
+	if len(offers) == 0 {
+		offers = make([]string, len(formats))
+		for i, f := range formats {
+			offers[i] = f.mediaType
+		}
+	}
+
+	c.addVary("Accept")
+	mediaType := negotiate(c.Request.Header.Get("Accept"), offers...)
+	if mediaType == "" {
+		return c.Error(http.StatusNotAcceptable, "not acceptable; available: "+strings.Join(offers, ", "))
+	}
+
+	var (
+		fn    FormatFunc
+		found bool
+	)
+	for _, f := range formats {
+		if strings.EqualFold(f.mediaType, mediaType) {
+			fn, found = f.fn, true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("marten: no format registered for %s", mediaType)
+	}
+
+	// Encode first so an encoding error does not leave a half-written response
+	var buf bytes.Buffer
+	encode := fn
+	if encode == nil {
+		encode = func(_ io.Writer, v any) error { return c.encodeJSON(&buf, v) }
+	}
+	if err := encode(&buf, data); err != nil {
+		return err
+	}
+	contentType := mediaType
+	if strings.HasPrefix(mediaType, "text/") || mediaType == MIMEJSON || mediaType == MIMEXML {
+		contentType += "; charset=utf-8"
+	}
+	return c.Blob(code, contentType, buf.Bytes())
+}
+
+// formats returns the app's registered formats, or the built-ins.
+func (c *Ctx) formats() []format {
+	if c.app != nil {
+		return c.app.formats
+	}
+	return defaultFormats()
+}
+
+// addVary adds a token to the Vary response header unless already present.
+func (c *Ctx) addVary(token string) {
+	h := c.Writer.Header()
+	for _, v := range h.Values("Vary") {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", token)
+}
+
+// acceptRange is a single media range from an Accept header.
+type acceptRange struct {
+	typ     string
+	subtype string
+	q       float64
+}
+
+// parseAccept parses an Accept header into media ranges.
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		r := acceptRange{q: 1}
+		mediaType := part
+		if i := strings.Index(part, ";"); i >= 0 {
+			mediaType = part[:i]
+			for _, param := range strings.Split(part[i+1:], ";") {
+				k, v, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if strings.EqualFold(strings.TrimSpace(k), "q") {
+					if q, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && q >= 0 && q <= 1 {
+						r.q = q
+					}
+				}
+			}
+		}
+		typ, subtype, ok := strings.Cut(strings.ToLower(strings.TrimSpace(mediaType)), "/")
+		if !ok {
+			if typ != "*" {
+				continue
+			}
+			subtype = "*"
+		}
+		r.typ, r.subtype = typ, subtype
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+// match returns how specifically r matches mediaType (-1 if it does not).
+func (r acceptRange) match(mediaType string) int {
+	typ, subtype, _ := strings.Cut(strings.ToLower(mediaType), "/")
+	switch {
+	case r.typ == typ && r.subtype == subtype:
+		return 2
+	case r.typ == typ && r.subtype == "*":
+		return 1
+	case r.typ == "*" && r.subtype == "*":
+		return 0
+	}
+	return -1
+}
+
+// negotiate picks the offer best matching the Accept header.
+// An empty header accepts the first offer; "" is returned if nothing is acceptable.
+func negotiate(accept string, offers ...string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+	ranges := parseAccept(accept)
+
+	best, bestQ, bestSpec := "", 0.0, -1
+	for _, offer := range offers {
+		// The most specific matching range decides the offer's quality.
+		q, spec := 0.0, -1
+		for _, r := range ranges {
+			if s := r.match(offer); s > spec {
+				q, spec = r.q, s
+			}
+		}
+		if spec < 0 || q <= 0 {
+			continue
+		}
+		if q > bestQ || (q == bestQ && spec > bestSpec) {
+			best, bestQ, bestSpec = offer, q, spec
+		}
+	}
+	return best
+}
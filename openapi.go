@@ -0,0 +1,882 @@
+package marten
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// OpenAPIConfig configures the OpenAPI document generated by App.OpenAPI and
+// served by App.ServeOpenAPI.
+type OpenAPIConfig struct {
+	Title       string   // default "API"
+	Version     string   // default "1.0.0"
+	Description string   // info.description
+	Servers     []string // server URLs
+
+	// Path is where ServeOpenAPI serves the document (default "/openapi.json").
+	Path string
+	// DocsPath, when set, serves an HTML page that renders the document.
+	DocsPath string
+
+	// Deterministic produces byte-stable output for committing and diffing:
+	// indented JSON with a trailing newline, and no server derived from the
+	// request when Servers is empty. Paths, operations, parameters and
+	// schemas are always sorted.
+	Deterministic bool
+
+	// Security lists schemes that apply to every route, e.g. those of auth
+	// middleware added with App.Use.
+	Security []SecurityScheme
+}
+
+// Operation documents a route in the OpenAPI document. Attach it with
+// RouteInfo.Doc.
+type Operation struct {
+	Summary     string
+	Description string
+	OperationID string // default derived from method and path, e.g. "getUsersById"
+	Tags        []string
+	Deprecated  bool
+	Hidden      bool // leave the route out of the document
+
+	// Status is the success status (default 200, or 204 for empty results).
+	Status int
+	// Request and Response are sample values whose types document the body
+	// of handlers that are not Typed.
+	Request  any
+	Response any
+	// Responses adds further responses as status code -> description.
+	Responses map[int]string
+}
+
+// RouteInfo is returned by route registration and documents the route in
+// the OpenAPI document.
+//
+//	app.GET("/users/:id", getUser).Doc(marten.Operation{
+//		Summary: "Get a user",
+//		Tags:    []string{"users"},
+//	})
+type RouteInfo struct {
+	Route
+	op       Operation
+	security []SecurityScheme
+	typed    *typedInfo
+}
+
+// Doc attaches an Operation to the route. Several Docs are merged.
+func (ri *RouteInfo) Doc(op Operation) *RouteInfo {
+	ri.op = mergeOperation(ri.op, op)
+	return ri
+}
+
+// Security declares the security schemes of the route's auth middleware.
+//
+//	app.GET("/me", me, jwtAuth).Security(marten.SecurityScheme{
+//		Name: "bearerAuth", Type: "http", Scheme: "bearer", BearerFormat: "JWT",
+//	})
+func (ri *RouteInfo) Security(schemes ...SecurityScheme) *RouteInfo {
+	ri.security = append(ri.security, schemes...)
+	return ri
+}
+
+// SecurityScheme describes how a route authenticates requests. Auth
+// middleware's scheme is declared with RouteInfo.Security, Group.Security
+// or OpenAPIConfig.Security.
+type SecurityScheme struct {
+	Name         string // key in components.securitySchemes, e.g. "basicAuth"
+	Type         string // "http", "apiKey", "oauth2" or "openIdConnect"
+	Scheme       string // for "http": "basic" or "bearer"
+	BearerFormat string // for "bearer", e.g. "JWT"
+	In           string // for "apiKey": "header", "query" or "cookie"
+	ParamName    string // for "apiKey": header, query or cookie name
+	Description  string
+}
+
+// OpenAPI generates an OpenAPI 3.1 document from the registered routes.
+// Path parameters come from :name and *name segments; Typed handlers
+// document their parameters, request body and response through reflection
+// on param, query, header, cookie, json, default and validate tags; RouteInfo.Doc
+// and Security add route metadata and security schemes.
+func (a *App) OpenAPI(cfg OpenAPIConfig) ([]byte, error) {
+	return a.openAPIDoc(cfg).marshal(cfg.Deterministic)
+}
+
+// ServeOpenAPI registers GET routes serving the OpenAPI document at
+// cfg.Path and, when cfg.DocsPath is set, an HTML documentation page.
+// The document is generated on the first request, so routes registered
+// after ServeOpenAPI are included.
+func (a *App) ServeOpenAPI(cfg OpenAPIConfig) {
+	if cfg.Path == "" {
+		cfg.Path = "/openapi.json"
+	}
+	var (
+		once sync.Once
+		doc  *oaDoc
+	)
+
+	a.GET(cfg.Path, func(c *Ctx) error {
+		once.Do(func() { doc = a.openAPIDoc(cfg) })
+		d := *doc
+		if host := c.Host(); !cfg.Deterministic && len(d.Servers) == 0 && host != "" {
+			d.Servers = []oaServer{{URL: c.Scheme() + "://" + host}}
+		}
+		data, err := d.marshal(cfg.Deterministic)
+		if err != nil {
+			return err
+		}
+		return c.Blob(http.StatusOK, "application/json; charset=utf-8", data)
+	}).Doc(Operation{Hidden: true})
+
+	if cfg.DocsPath != "" {
+		var page bytes.Buffer
+		title := cfg.Title
+		if title == "" {
+			title = "API"
+		}
+		if err := docsPage.Execute(&page, map[string]string{"Title": title, "Spec": cfg.Path}); err != nil {
+			panic("marten: " + err.Error())
+		}
+		a.GET(cfg.DocsPath, func(c *Ctx) error {
+			return c.Blob(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+		}).Doc(Operation{Hidden: true})
+	}
+}
+
+// OpenAPI document model. Maps are used where keys are names so that
+// encoding/json writes them in sorted order.
+type oaDoc struct {
+	OpenAPI    string                             `json:"openapi"`
+	Info       oaInfo                             `json:"info"`
+	Servers    []oaServer                         `json:"servers,omitempty"`
+	Paths      map[string]map[string]*oaOperation `json:"paths"`
+	Components *oaComponents                      `json:"components,omitempty"`
+}
+
+type oaInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type oaServer struct {
+	URL string `json:"url"`
+}
+
+type oaComponents struct {
+	Schemas         map[string]*oaSchema   `json:"schemas,omitempty"`
+	SecuritySchemes map[string]oaSecScheme `json:"securitySchemes,omitempty"`
+}
+
+type oaSecScheme struct {
+	Type         string `json:"type"`
+	Description  string `json:"description,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Name         string `json:"name,omitempty"`
+	In           string `json:"in,omitempty"`
+}
+
+type oaOperation struct {
+	OperationID string                `json:"operationId,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	Parameters  []oaParameter         `json:"parameters,omitempty"`
+	RequestBody *oaRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]oaResponse `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type oaParameter struct {
+	Name     string    `json:"name"`
+	In       string    `json:"in"`
+	Required bool      `json:"required,omitempty"`
+	Schema   *oaSchema `json:"schema"`
+}
+
+type oaRequestBody struct {
+	Required bool                   `json:"required,omitempty"`
+	Content  map[string]oaMediaType `json:"content"`
+}
+
+type oaResponse struct {
+	Description string                 `json:"description"`
+	Content     map[string]oaMediaType `json:"content,omitempty"`
+}
+
+type oaMediaType struct {
+	Schema *oaSchema `json:"schema"`
+}
+
+type oaSchema struct {
+	Ref                  string               `json:"$ref,omitempty"`
+	Type                 string               `json:"type,omitempty"`
+	Format               string               `json:"format,omitempty"`
+	Items                *oaSchema            `json:"items,omitempty"`
+	Properties           map[string]*oaSchema `json:"properties,omitempty"`
+	AdditionalProperties *oaSchema            `json:"additionalProperties,omitempty"`
+	Required             []string             `json:"required,omitempty"`
+	Enum                 []any                `json:"enum,omitempty"`
+	Default              any                  `json:"default,omitempty"`
+	Pattern              string               `json:"pattern,omitempty"`
+	Minimum              *float64             `json:"minimum,omitempty"`
+	Maximum              *float64             `json:"maximum,omitempty"`
+	ExclusiveMinimum     *float64             `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     *float64             `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int                 `json:"minLength,omitempty"`
+	MaxLength            *int                 `json:"maxLength,omitempty"`
+	MinItems             *int                 `json:"minItems,omitempty"`
+	MaxItems             *int                 `json:"maxItems,omitempty"`
+	MinProperties        *int                 `json:"minProperties,omitempty"`
+	MaxProperties        *int                 `json:"maxProperties,omitempty"`
+}
+
+func (d *oaDoc) marshal(indent bool) ([]byte, error) {
+	if !indent {
+		return json.Marshal(d)
+	}
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// openAPIDoc builds the document from the app's routes.
+func (a *App) openAPIDoc(cfg OpenAPIConfig) *oaDoc {
+	doc := &oaDoc{
+		OpenAPI: "3.1.0",
+		Info:    oaInfo{Title: cfg.Title, Version: cfg.Version, Description: cfg.Description},
+		Paths:   map[string]map[string]*oaOperation{},
+	}
+	if doc.Info.Title == "" {
+		doc.Info.Title = "API"
+	}
+	if doc.Info.Version == "" {
+		doc.Info.Version = "1.0.0"
+	}
+	for _, s := range cfg.Servers {
+		doc.Servers = append(doc.Servers, oaServer{URL: s})
+	}
+
+	g := &schemaGen{schemas: map[string]*oaSchema{}, names: map[reflect.Type]string{}}
+	schemes := map[string]oaSecScheme{}
+	for _, e := range a.routeEntries() {
+		op, security := e.info.op, append(append([]SecurityScheme(nil), cfg.Security...), e.info.security...)
+		if op.Hidden {
+			continue
+		}
+		for _, s := range security {
+			schemes[s.Name] = oaSecScheme{
+				Type: s.Type, Description: s.Description, Scheme: s.Scheme,
+				BearerFormat: s.BearerFormat, Name: s.ParamName, In: s.In,
+			}
+		}
+		path, o := a.buildOperation(g, e, op, security)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*oaOperation{}
+		}
+		doc.Paths[path][strings.ToLower(e.Method)] = o
+	}
+
+	if len(g.schemas) > 0 || len(schemes) > 0 {
+		doc.Components = &oaComponents{SecuritySchemes: schemes}
+		if len(g.schemas) > 0 {
+			doc.Components.Schemas = g.schemas
+		}
+		if len(schemes) == 0 {
+			doc.Components.SecuritySchemes = nil
+		}
+	}
+	return doc
+}
+
+func mergeOperation(op, o Operation) Operation {
+	if o.Summary != "" {
+		op.Summary = o.Summary
+	}
+	if o.Description != "" {
+		op.Description = o.Description
+	}
+	if o.OperationID != "" {
+		op.OperationID = o.OperationID
+	}
+	op.Tags = append(op.Tags, o.Tags...)
+	op.Deprecated = op.Deprecated || o.Deprecated
+	op.Hidden = op.Hidden || o.Hidden
+	if o.Status != 0 {
+		op.Status = o.Status
+	}
+	if o.Request != nil {
+		op.Request = o.Request
+	}
+	if o.Response != nil {
+		op.Response = o.Response
+	}
+	for code, desc := range o.Responses {
+		if op.Responses == nil {
+			op.Responses = map[int]string{}
+		}
+		op.Responses[code] = desc
+	}
+	return op
+}
+
+// buildOperation documents a single route and returns its OpenAPI path.
+func (a *App) buildOperation(g *schemaGen, e routeEntry, op Operation, security []SecurityScheme) (string, *oaOperation) {
+	o := &oaOperation{
+		OperationID: op.OperationID,
+		Summary:     op.Summary,
+		Description: op.Description,
+		Tags:        op.Tags,
+		Deprecated:  op.Deprecated,
+		Responses:   map[string]oaResponse{},
+	}
+	if o.OperationID == "" {
+		o.OperationID = operationID(e.Method, e.Path)
+	}
+
+	var reqType, respType reflect.Type
+	isTyped := e.info.typed != nil
+	if isTyped {
+		reqType, respType = e.info.typed.request, e.info.typed.response
+	}
+	if op.Request != nil {
+		reqType = reflect.TypeOf(op.Request)
+	}
+	if op.Response != nil {
+		respType = reflect.TypeOf(op.Response)
+	}
+
+	// Parameters: path segments first, then tagged fields of the request
+	reqStruct := derefType(reqType)
+	if reqStruct != nil && (reqStruct.Kind() != reflect.Struct || isLeafType(reqStruct)) {
+		reqStruct = nil
+	}
+	var fields []fieldInfo
+	if reqStruct != nil {
+		fields = collectFields(reqStruct, nil, "")
+	}
+	segments := splitPath(e.Path)
+	for i, seg := range segments {
+		if !strings.HasPrefix(seg, ":") && !strings.HasPrefix(seg, "*") {
+			continue
+		}
+		name := seg[1:]
+		segments[i] = "{" + name + "}"
+		schema := &oaSchema{Type: "string"}
+		for _, f := range fields {
+			if f.keys[sourceParam] == name {
+				schema = g.paramSchema(reqStruct.FieldByIndex(f.index), f)
+			}
+		}
+		o.Parameters = append(o.Parameters, oaParameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+	for _, src := range []string{sourceQuery, sourceHeader, sourceCookie} {
+		for _, f := range fields {
+			key := f.keys[src]
+			if key == "" {
+				continue
+			}
+			sf := reqStruct.FieldByIndex(f.index)
+			o.Parameters = append(o.Parameters, oaParameter{
+				Name:     key,
+				In:       src,
+				Required: hasRule(parseRules(sf.Tag.Get("validate")), "required"),
+				Schema:   g.paramSchema(sf, f),
+			})
+		}
+	}
+
+	// Request body
+	hasTagged := false
+	for _, f := range fields {
+		hasTagged = hasTagged || f.tagged()
+	}
+	if reqType != nil && !isEmptyStruct(reqType) && methodHasBody(e.Method) && (reqStruct == nil || g.hasBodyFields(reqStruct)) {
+		o.RequestBody = &oaRequestBody{
+			Required: !hasTagged,
+			Content:  map[string]oaMediaType{MIMEJSON: {Schema: g.schema(reqType)}},
+		}
+	}
+
+	// Responses
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+		if isTyped && isEmptyStruct(respType) {
+			status = http.StatusNoContent
+		}
+	}
+	success := oaResponse{Description: http.StatusText(status)}
+	if respType != nil && !isEmptyStruct(respType) && status != http.StatusNoContent {
+		success.Content = map[string]oaMediaType{MIMEJSON: {Schema: g.schema(respType)}}
+	}
+	o.Responses[strconv.Itoa(status)] = success
+
+	errorCodes := map[int]string{}
+	if isTyped && reqType != nil && !isEmptyStruct(reqType) {
+		errorCodes[http.StatusBadRequest] = ""
+		if hasValidation(reqType, map[reflect.Type]bool{}) {
+			errorCodes[http.StatusUnprocessableEntity] = ""
+		}
+	}
+	if len(security) > 0 {
+		errorCodes[http.StatusUnauthorized] = ""
+	}
+	for code, desc := range op.Responses {
+		errorCodes[code] = desc
+	}
+	for code, desc := range errorCodes {
+		if desc == "" {
+			desc = http.StatusText(code)
+		}
+		r := oaResponse{Description: desc}
+		if code >= 400 {
+			r.Content = a.errorContent(g)
+		}
+		o.Responses[strconv.Itoa(code)] = r
+	}
+
+	for _, s := range security {
+		o.Security = append(o.Security, map[string][]string{s.Name: {}})
+	}
+	return "/" + strings.Join(segments, "/"), o
+}
+
+// errorContent describes the body written by the default error handler.
+func (a *App) errorContent(g *schemaGen) map[string]oaMediaType {
+	if a.problemDetails {
+		if _, ok := g.schemas["Problem"]; !ok {
+			g.schemas["Problem"] = &oaSchema{
+				Type: "object",
+				Properties: map[string]*oaSchema{
+					"type":     {Type: "string", Format: "uri-reference"},
+					"title":    {Type: "string"},
+					"status":   {Type: "integer"},
+					"detail":   {Type: "string"},
+					"instance": {Type: "string", Format: "uri-reference"},
+					"invalid-params": {Type: "array", Items: &oaSchema{
+						Type: "object",
+						Properties: map[string]*oaSchema{
+							"name":   {Type: "string"},
+							"reason": {Type: "string"},
+						},
+					}},
+				},
+			}
+		}
+		return map[string]oaMediaType{"application/problem+json": {Schema: &oaSchema{Ref: "#/components/schemas/Problem"}}}
+	}
+	if _, ok := g.schemas["Error"]; !ok {
+		g.schemas["Error"] = &oaSchema{
+			Type:       "object",
+			Properties: map[string]*oaSchema{"error": {Type: "string"}},
+			Required:   []string{"error"},
+		}
+	}
+	return map[string]oaMediaType{MIMEJSON: {Schema: &oaSchema{Ref: "#/components/schemas/Error"}}}
+}
+
+// operationID derives an ID such as "getUsersById" from a route.
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, seg := range splitPath(path) {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			b.WriteString("By")
+			seg = seg[1:]
+		}
+		for _, word := range strings.FieldsFunc(seg, func(r rune) bool {
+			return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9')
+		}) {
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return b.String()
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+func hasRule(rules []rule, name string) bool {
+	for _, r := range rules {
+		if r.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// hasValidation reports whether t or a nested struct has validate tags.
+func hasValidation(t reflect.Type, seen map[reflect.Type]bool) bool {
+	t = derefType(t)
+	if t == nil || t.Kind() != reflect.Struct || seen[t] {
+		return false
+	}
+	seen[t] = true
+	fields, _ := validateFields(t)
+	for _, f := range fields {
+		if len(f.rules) > 0 || hasValidation(t.Field(f.index).Type, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	schemaNameRegex   = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// schemaGen converts Go types to JSON schemas. Named struct types become
+// components referenced with $ref.
+type schemaGen struct {
+	schemas map[string]*oaSchema
+	names   map[reflect.Type]string
+}
+
+func (g *schemaGen) schema(t reflect.Type) *oaSchema {
+	t = derefType(t)
+	if t == nil {
+		return &oaSchema{}
+	}
+	switch {
+	case t == timeType:
+		return &oaSchema{Type: "string", Format: "date-time"}
+	case t == fileHeaderType.Elem():
+		return &oaSchema{Type: "string", Format: "binary"}
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		return &oaSchema{}
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return &oaSchema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &oaSchema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &oaSchema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &oaSchema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &oaSchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &oaSchema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &oaSchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &oaSchema{Type: "string", Format: "byte"}
+		}
+		return &oaSchema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &oaSchema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return &oaSchema{Ref: "#/components/schemas/" + g.component(t)}
+	}
+	return &oaSchema{}
+}
+
+// component registers the schema of named struct type t and returns its name.
+func (g *schemaGen) component(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+	name := schemaNameRegex.ReplaceAllString(t.Name(), "_")
+	if _, taken := g.schemas[name]; taken {
+		pkg := t.PkgPath()
+		name = schemaNameRegex.ReplaceAllString(pkg[strings.LastIndex(pkg, "/")+1:]+"."+t.Name(), "_")
+		for i := 2; g.schemas[name] != nil; i++ {
+			name = strings.TrimRight(name, "0123456789") + strconv.Itoa(i)
+		}
+	}
+	g.names[t] = name
+	g.schemas[name] = &oaSchema{} // placeholder for recursive types
+	*g.schemas[name] = *g.structSchema(t)
+	return name
+}
+
+// structSchema describes the JSON body fields of struct type t.
+func (g *schemaGen) structSchema(t reflect.Type) *oaSchema {
+	s := &oaSchema{Type: "object"}
+	g.addFields(s, t)
+	sort.Strings(s.Required)
+	return s
+}
+
+func (g *schemaGen) addFields(s *oaSchema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, ok := jsonFieldName(sf)
+		if !ok {
+			continue
+		}
+		ft := derefType(sf.Type)
+		if name == "" && sf.Anonymous && ft.Kind() == reflect.Struct {
+			g.addFields(s, ft)
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		rules := parseRules(sf.Tag.Get("validate"))
+		fs := g.schema(sf.Type)
+		applyRules(fs, derefType(sf.Type), rules)
+		if s.Properties == nil {
+			s.Properties = map[string]*oaSchema{}
+		}
+		s.Properties[name] = fs
+		if hasRule(rules, "required") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// hasBodyFields reports whether struct type t has fields read from the body.
+func (g *schemaGen) hasBodyFields(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if name, ok := jsonFieldName(t.Field(i)); ok {
+			ft := derefType(t.Field(i).Type)
+			if name == "" && t.Field(i).Anonymous && ft.Kind() == reflect.Struct {
+				if g.hasBodyFields(ft) {
+					return true
+				}
+				continue
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// jsonFieldName returns the JSON name of a body field ("" for the Go name
+// or an embedded struct) and false for fields not read from the body.
+func jsonFieldName(sf reflect.StructField) (string, bool) {
+	if !sf.IsExported() && (!sf.Anonymous || derefType(sf.Type).Kind() != reflect.Struct) {
+		return "", false
+	}
+	for _, src := range tagSources {
+		if key, ok := sf.Tag.Lookup(src); ok && key != "-" {
+			return "", false
+		}
+	}
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "-" {
+		return "", false
+	}
+	return name, true
+}
+
+// paramSchema describes a parameter bound from field sf.
+func (g *schemaGen) paramSchema(sf reflect.StructField, f fieldInfo) *oaSchema {
+	s := g.schema(sf.Type)
+	applyRules(s, derefType(sf.Type), parseRules(sf.Tag.Get("validate")))
+	if f.hasDefault {
+		v := reflect.New(sf.Type).Elem()
+		if err := setValues(v, []string{f.def}); err == nil {
+			s.Default = v.Interface()
+		} else {
+			s.Default = f.def
+		}
+	}
+	return s
+}
+
+// applyRules adds the constraints of validate rules to s, which describes t.
+func applyRules(s *oaSchema, t reflect.Type, rules []rule) {
+	if s.Ref != "" {
+		return
+	}
+	for i, r := range rules {
+		if r.name == "dive" {
+			if s.Items != nil && t != nil {
+				applyRules(s.Items, derefType(t.Elem()), rules[i+1:])
+			}
+			return
+		}
+		n, err := strconv.ParseFloat(r.param, 64)
+		hasNum := err == nil
+		switch r.name {
+		case "min", "gte", "max", "lte", "len", "gt", "lt":
+			if hasNum {
+				applyBound(s, r.name, n)
+			}
+		case "eq":
+			s.Enum = []any{enumValue(s, r.param)}
+		case "oneof":
+			s.Enum = nil
+			for _, opt := range strings.Fields(r.param) {
+				s.Enum = append(s.Enum, enumValue(s, opt))
+			}
+		case "email":
+			s.Format = "email"
+		case "url":
+			s.Format = "uri"
+		case "uuid":
+			s.Format = "uuid"
+		case "alpha":
+			s.Pattern = `^\p{L}+$`
+		case "alphanum":
+			s.Pattern = `^[\p{L}\p{N}]+$`
+		case "numeric":
+			s.Pattern = numericRegex.String()
+		}
+	}
+}
+
+// applyBound applies a size rule to the length, item count, property count
+// or value described by s.
+func applyBound(s *oaSchema, name string, n float64) {
+	lower := name == "min" || name == "gte" || name == "len" || name == "gt"
+	upper := name == "max" || name == "lte" || name == "len" || name == "lt"
+	if s.Type == "number" || s.Type == "integer" {
+		v := n
+		switch name {
+		case "gt":
+			s.ExclusiveMinimum = &v
+		case "lt":
+			s.ExclusiveMaximum = &v
+		default:
+			if lower {
+				s.Minimum = &v
+			}
+			if upper {
+				s.Maximum = &v
+			}
+		}
+		return
+	}
+	size := int(n)
+	if name == "gt" {
+		size++
+	} else if name == "lt" {
+		size--
+	}
+	var minp, maxp **int
+	switch s.Type {
+	case "string":
+		minp, maxp = &s.MinLength, &s.MaxLength
+	case "array":
+		minp, maxp = &s.MinItems, &s.MaxItems
+	case "object":
+		minp, maxp = &s.MinProperties, &s.MaxProperties
+	default:
+		return
+	}
+	if lower {
+		v := size
+		*minp = &v
+	}
+	if upper {
+		v := size
+		*maxp = &v
+	}
+}
+
+// enumValue converts an eq or oneof parameter to the schema's type.
+func enumValue(s *oaSchema, param string) any {
+	switch s.Type {
+	case "integer":
+		if n, err := strconv.ParseInt(param, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if n, err := strconv.ParseFloat(param, 64); err == nil {
+			return n
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(param); err == nil {
+			return b
+		}
+	}
+	return param
+}
+
+// docsPage renders an OpenAPI document without external assets.
+var docsPage = template.Must(template.New("docs").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body{font-family:system-ui,sans-serif;margin:0 auto;max-width:960px;padding:1rem 2rem;color:#222}
+details{border:1px solid #ddd;border-radius:6px;margin:.5rem 0}
+summary{cursor:pointer;padding:.6rem;font-family:ui-monospace,monospace}
+.m{display:inline-block;min-width:4.5em;font-weight:bold;text-transform:uppercase}
+.get{color:#1a7f37}.post{color:#0969da}.put,.patch{color:#9a6700}.delete{color:#cf222e}
+.body{padding:0 1rem 1rem}pre{background:#f6f8fa;padding:.6rem;overflow:auto}
+table{border-collapse:collapse}td,th{border:1px solid #ddd;padding:.2rem .5rem;text-align:left}
+.dep{text-decoration:line-through}
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p><a href="{{.Spec}}">{{.Spec}}</a></p>
+<div id="ops">Loading…</div>
+<script>
+const spec = {{.Spec}};
+function el(tag, attrs, ...children) {
+  const e = document.createElement(tag);
+  Object.assign(e, attrs);
+  for (const c of children) e.append(c);
+  return e;
+}
+function resolve(doc, s) {
+  if (s && s.$ref) return {title: s.$ref.split("/").pop(), ...doc.components.schemas[s.$ref.split("/").pop()]};
+  return s;
+}
+fetch(spec).then(r => r.json()).then(doc => {
+  const root = document.getElementById("ops");
+  root.textContent = "";
+  if (doc.info.description) root.append(el("p", {textContent: doc.info.description}));
+  for (const [path, ops] of Object.entries(doc.paths)) {
+    for (const [method, op] of Object.entries(ops)) {
+      const head = el("summary", {className: op.deprecated ? "dep" : ""},
+        el("span", {className: "m " + method, textContent: method}), path + (op.summary ? " — " + op.summary : ""));
+      const body = el("div", {className: "body"});
+      if (op.description) body.append(el("p", {textContent: op.description}));
+      if (op.security) body.append(el("p", {textContent: "Security: " + op.security.map(s => Object.keys(s)[0]).join(", ")}));
+      if (op.parameters) {
+        const t = el("table", {}, el("tr", {}, el("th", {textContent: "Name"}), el("th", {textContent: "In"}), el("th", {textContent: "Schema"})));
+        for (const p of op.parameters) {
+          t.append(el("tr", {}, el("td", {textContent: p.name + (p.required ? " *" : "")}), el("td", {textContent: p.in}), el("td", {textContent: JSON.stringify(p.schema)})));
+        }
+        body.append(el("h4", {textContent: "Parameters"}), t);
+      }
+      if (op.requestBody) {
+        for (const [type, m] of Object.entries(op.requestBody.content)) {
+          body.append(el("h4", {textContent: "Request body (" + type + ")"}), el("pre", {textContent: JSON.stringify(resolve(doc, m.schema), null, 2)}));
+        }
+      }
+      for (const [code, r] of Object.entries(op.responses)) {
+        body.append(el("h4", {textContent: code + " " + r.description}));
+        for (const m of Object.values(r.content || {})) body.append(el("pre", {textContent: JSON.stringify(resolve(doc, m.schema), null, 2)}));
+      }
+      root.append(el("details", {}, head, body));
+    }
+  }
+}).catch(err => { document.getElementById("ops").textContent = "Failed to load " + spec + ": " + err; });
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,81 @@
+package marten
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrMissingParam is the cause of a ParamError for an absent parameter.
+var ErrMissingParam = errors.New("is required")
+
+// ParamError reports a path or query parameter that is missing or cannot be
+// converted. The default error handler responds to it with 400.
+type ParamError struct {
+	Source string // "param" or "query"
+	Name   string
+	Value  string
+	Err    error
+}
+
+func (e *ParamError) Error() string {
+	if errors.Is(e.Err, ErrMissingParam) {
+		return fmt.Sprintf("%s %q is required", e.Source, e.Name)
+	}
+	return fmt.Sprintf("%s %q: cannot use %q: %v", e.Source, e.Name, e.Value, e.Err)
+}
+
+func (e *ParamError) Unwrap() error {
+	return e.Err
+}
+
+// Param returns the path parameter name converted to T. T may be a string,
+// bool, number, time.Time, time.Duration, pointer or any
+// encoding.TextUnmarshaler. A missing or invalid value returns a *ParamError.
+//
+//	id, err := marten.Param[int](c, "id")
+func Param[T any](c *Ctx, name string) (T, error) {
+	c.checkLive()
+	var v T
+	raw, ok := c.params[name]
+	if !ok {
+		return v, &ParamError{Source: sourceParam, Name: name, Err: ErrMissingParam}
+	}
+	return v, convertParam(&v, sourceParam, name, []string{raw})
+}
+
+// Query returns the query parameter name converted to T (see Param).
+// Slice types receive every value of a repeated parameter.
+//
+//	since, err := marten.Query[time.Time](c, "since")
+func Query[T any](c *Ctx, name string) (T, error) {
+	var v T
+	values, ok := c.sourceValues(sourceQuery, name)
+	if !ok {
+		return v, &ParamError{Source: sourceQuery, Name: name, Err: ErrMissingParam}
+	}
+	return v, convertParam(&v, sourceQuery, name, values)
+}
+
+// QueryOr returns the query parameter name converted to T, or def when the
+// parameter is absent or empty. Invalid values still return a *ParamError.
+//
+//	limit, err := marten.QueryOr(c, "limit", 20)
+func QueryOr[T any](c *Ctx, name string, def T) (T, error) {
+	values, ok := c.sourceValues(sourceQuery, name)
+	if !ok || len(values) == 0 || len(values) == 1 && values[0] == "" {
+		return def, nil
+	}
+	var v T
+	if err := convertParam(&v, sourceQuery, name, values); err != nil {
+		return def, err
+	}
+	return v, nil
+}
+
+func convertParam(v any, source, name string, values []string) error {
+	if err := setValues(reflect.ValueOf(v).Elem(), values); err != nil {
+		return &ParamError{Source: source, Name: name, Value: values[0], Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package marten
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// Problem content types (RFC 9457).
+const (
+	MIMEProblemJSON = "application/problem+json"
+	MIMEProblemXML  = "application/problem+xml"
+)
+
+// problemXMLNamespace is the XML namespace defined in RFC 9457 Appendix B.
+const problemXMLNamespace = "urn:ietf:rfc:7807"
+
+// Problem is an RFC 9457 problem details object.
+// It implements error, so handlers can return it directly.
+type Problem struct {
+	// Type is a URI reference identifying the problem type (default: "about:blank").
+	Type string
+	// Title is a short summary of the problem type (default: status text).
+	Title string
+	// Status is the HTTP status code.
+	Status int
+	// Detail is an explanation specific to this occurrence.
+	Detail string
+	// Instance is a URI reference identifying this occurrence (default: request path).
+	Instance string
+	// InvalidParams lists invalid input fields, serialized as "invalid-params".
+	InvalidParams []InvalidParam
+	// Extensions holds additional members serialized at the top level.
+	Extensions map[string]any
+}
+
+// InvalidParam describes a single invalid input in a problem response.
+type InvalidParam struct {
+	Name   string `json:"name" xml:"name"`
+	Reason string `json:"reason" xml:"reason"`
+}
+
+// NewProblem creates a problem with the given status and detail.
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// With sets an extension member and returns the problem for chaining.
+func (p *Problem) With(key string, value any) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = make(map[string]any)
+	}
+	p.Extensions[key] = value
+	return p
+}
+
+// Error implements the error interface.
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return fmt.Sprintf("%d %s: %s", p.Status, p.Title, p.Detail)
+	}
+	return fmt.Sprintf("%d %s", p.Status, p.Title)
+}
+
+// MarshalJSON flattens extension members into the top-level object.
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	m := make(map[string]any, len(p.Extensions)+6)
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+	if p.Type != "" {
+		m["type"] = p.Type
+	}
+	if p.Title != "" {
+		m["title"] = p.Title
+	}
+	if p.Status != 0 {
+		m["status"] = p.Status
+	}
+	if p.Detail != "" {
+		m["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		m["instance"] = p.Instance
+	}
+	if len(p.InvalidParams) > 0 {
+		m["invalid-params"] = p.InvalidParams
+	}
+	return json.Marshal(m)
+}
+
+// MarshalXML encodes the problem using the RFC 9457 XML format.
+func (p *Problem) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
+	start := xml.StartElement{
+		Name: xml.Name{Local: "problem"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: problemXMLNamespace}},
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	elem := func(name string, v any) error {
+		return e.EncodeElement(v, xml.StartElement{Name: xml.Name{Local: name}})
+	}
+	if p.Type != "" {
+		if err := elem("type", p.Type); err != nil {
+			return err
+		}
+	}
+	if p.Title != "" {
+		if err := elem("title", p.Title); err != nil {
+			return err
+		}
+	}
+	if p.Status != 0 {
+		if err := elem("status", p.Status); err != nil {
+			return err
+		}
+	}
+	if p.Detail != "" {
+		if err := elem("detail", p.Detail); err != nil {
+			return err
+		}
+	}
+	if p.Instance != "" {
+		if err := elem("instance", p.Instance); err != nil {
+			return err
+		}
+	}
+	if len(p.InvalidParams) > 0 {
+		list := struct {
+			Items []InvalidParam `xml:"i"`
+		}{p.InvalidParams}
+		if err := elem("invalid-params", list); err != nil {
+			return err
+		}
+	}
+
+	// Extensions in a stable order
+	keys := make([]string, 0, len(p.Extensions))
+	for k := range p.Extensions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := p.Extensions[k]
+		switch v.(type) {
+		case string, bool, int, int64, float64:
+		default:
+			v = fmt.Sprint(v)
+		}
+		if err := elem(k, v); err != nil {
+			return err
+		}
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+// SetProblemDetails enables RFC 9457 problem details for error responses.
+// When enabled, Ctx.Error, the default error handler, 404/405 responses and
+// built-in middleware respond with application/problem+json (or +xml).
+func (a *App) SetProblemDetails(enabled bool) {
+	a.problemDetails = enabled
+}
+
+// ProblemDetails reports whether the app serving this request uses problem details.
+func (c *Ctx) ProblemDetails() bool {
+	return c.app != nil && c.app.problemDetails
+}
+
+// Problem writes a problem details response. The XML form is used when the
+// client prefers it via the Accept header. Defaults are filled in on a copy,
+// so p can be a shared package-level value.
+func (c *Ctx) Problem(p *Problem) error {
+	cp := *p
+	p = &cp
+	if p.Status == 0 {
+		p.Status = http.StatusInternalServerError
+	}
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	if p.Instance == "" && c.Request != nil && c.Request.URL != nil {
+		p.Instance = c.Request.URL.Path
+	}
+
+	accept := ""
+	if c.Request != nil {
+		accept = c.Request.Header.Get("Accept")
+	}
+	switch negotiate(accept, MIMEProblemJSON, "application/json", MIMEProblemXML, "application/xml") {
+	case MIMEProblemXML, "application/xml":
+		b, err := xml.Marshal(p)
+		if err != nil {
+			return err
+		}
+		return c.Blob(p.Status, MIMEProblemXML+"; charset=utf-8", append([]byte(xml.Header), b...))
+	}
+
+	return c.writeJSON(p.Status, MIMEProblemJSON+"; charset=utf-8", p)
+}
+
+// Error sends an error response with the given status and message.
+// It writes a problem details response when enabled, as JSON or XML by
+// content negotiation, otherwise E(message) as JSON. The response is written
+// directly; the app's error handler is not involved.
+func (c *Ctx) Error(code int, message string) error {
+	if c.ProblemDetails() {
+		return c.Problem(NewProblem(code, message))
+	}
+	return c.JSON(code, E(message))
+}
@@ -0,0 +1,220 @@
+package marten
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// TrustedProxies sets the reverse proxies whose forwarding headers are
+// believed, as IP addresses or CIDR ranges. Without trusted proxies,
+// ClientIP, Scheme and Host ignore X-Forwarded-*, X-Real-IP and Forwarded
+// (RFC 7239), since any client can send them.
+//
+//	err := app.TrustedProxies([]string{"10.0.0.0/8", "192.168.1.10"})
+func (a *App) TrustedProxies(proxies []string) error {
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+	for _, p := range proxies {
+		var (
+			prefix netip.Prefix
+			err    error
+		)
+		if strings.Contains(p, "/") {
+			prefix, err = netip.ParsePrefix(p)
+		} else {
+			var addr netip.Addr
+			addr, err = netip.ParseAddr(p)
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		if err != nil {
+			return fmt.Errorf("marten: invalid trusted proxy %q: %w", p, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	a.trustedProxies = prefixes
+	return nil
+}
+
+func (a *App) trustsProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range a.trustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientHop returns the index of the client in hops, ordered from the
+// client to the nearest proxy: the rightmost hop that is not a trusted
+// proxy, or the leftmost if all are. An unparsable hop ends the walk, and
+// len(hops) means no hop can be believed.
+func (a *App) clientHop(hops []string) int {
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseHop(hops[i])
+		if !ok {
+			return i + 1
+		}
+		if !a.trustsProxy(addr) {
+			return i
+		}
+	}
+	return 0
+}
+
+// Scheme returns "https" or "http" as seen by the client, using
+// X-Forwarded-Proto or Forwarded from a trusted proxy.
+func (c *Ctx) Scheme() string {
+	c.checkLive()
+	if fwd, ok := c.forwarded(); ok && (fwd.proto == "http" || fwd.proto == "https") {
+		return fwd.proto
+	}
+	if c.Request.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// IsTLS reports whether the client connected over HTTPS, directly or
+// through a trusted proxy.
+func (c *Ctx) IsTLS() bool {
+	return c.Scheme() == "https"
+}
+
+// Host returns the host requested by the client, using X-Forwarded-Host or
+// Forwarded from a trusted proxy.
+func (c *Ctx) Host() string {
+	c.checkLive()
+	if fwd, ok := c.forwarded(); ok && fwd.host != "" {
+		return fwd.host
+	}
+	return c.Request.Host
+}
+
+// forwardInfo is what trusted proxies report about the client.
+type forwardInfo struct {
+	ip    netip.Addr
+	proto string
+	host  string
+}
+
+// forwarded returns the client's address, scheme and host as reported by
+// trusted proxies, or false if the peer is not a trusted proxy.
+// X-Forwarded-For is preferred over Forwarded, then X-Real-IP.
+func (c *Ctx) forwarded() (forwardInfo, bool) {
+	if c.app == nil || len(c.app.trustedProxies) == 0 || c.Request == nil {
+		return forwardInfo{}, false
+	}
+	peer, ok := parseHop(c.Request.RemoteAddr)
+	if !ok || !c.app.trustsProxy(peer) {
+		return forwardInfo{}, false
+	}
+
+	h := c.Request.Header
+	fwd := forwardInfo{
+		ip:    peer,
+		proto: strings.ToLower(lastToken(h.Values("X-Forwarded-Proto"))),
+		host:  lastToken(h.Values("X-Forwarded-Host")),
+	}
+	if xff := headerTokens(h, "X-Forwarded-For"); len(xff) > 0 {
+		if i := c.app.clientHop(xff); i < len(xff) {
+			fwd.ip, _ = parseHop(xff[i])
+		}
+	} else if elems := parseForwarded(h.Values("Forwarded")); len(elems) > 0 {
+		hops := make([]string, len(elems))
+		for i, e := range elems {
+			hops[i] = e.forIP
+		}
+		if i := c.app.clientHop(hops); i < len(hops) {
+			fwd.ip, _ = parseHop(hops[i])
+			if e := elems[i]; e.proto != "" || e.host != "" {
+				fwd.proto, fwd.host = strings.ToLower(e.proto), e.host
+			}
+		}
+	} else if ip, ok := parseHop(h.Get("X-Real-IP")); ok {
+		fwd.ip = ip
+	}
+	return fwd, true
+}
+
+// parseHop parses an address from a forwarding header or RemoteAddr, with
+// an optional port and brackets.
+func parseHop(s string) (netip.Addr, bool) {
+	s = strings.Trim(strings.TrimSpace(s), `"`)
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		return ap.Addr().Unmap(), true
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// lastToken returns the last comma-separated value of a header, i.e. the one
+// added by the nearest proxy.
+func lastToken(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	v := values[len(values)-1]
+	if i := strings.LastIndexByte(v, ','); i >= 0 {
+		v = v[i+1:]
+	}
+	return strings.TrimSpace(v)
+}
+
+// forwardedElem is one proxy's entry in a Forwarded header.
+type forwardedElem struct {
+	forIP string
+	proto string
+	host  string
+}
+
+// parseForwarded parses RFC 7239 Forwarded header values, e.g.
+// `for=192.0.2.60;proto=https;host=example.com, for="[2001:db8::17]:4711"`.
+func parseForwarded(values []string) []forwardedElem {
+	var elems []forwardedElem
+	for _, v := range values {
+		for _, elem := range splitQuoted(v, ',') {
+			var e forwardedElem
+			for _, pair := range splitQuoted(elem, ';') {
+				key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok {
+					continue
+				}
+				value = strings.Trim(strings.TrimSpace(value), `"`)
+				switch strings.ToLower(key) {
+				case "for":
+					e.forIP = value
+				case "proto":
+					e.proto = value
+				case "host":
+					e.host = value
+				}
+			}
+			elems = append(elems, e)
+		}
+	}
+	return elems
+}
+
+// splitQuoted splits s at sep outside double quotes.
+func splitQuoted(s string, sep byte) []string {
+	var (
+		parts  []string
+		quoted bool
+		start  int
+	)
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
@@ -0,0 +1,108 @@
+package marten
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// CSRFTokenKey is the context store key holding the current CSRF token.
+// CSRF middleware sets it; templates read it with {{csrfToken}}.
+const CSRFTokenKey = "csrf_token"
+
+// CSRFKey is the typed key for the CSRF token.
+var CSRFKey = NewKey[string](CSRFTokenKey)
+
+// CSRFToken returns the CSRF token to embed in forms, as set by the CSRF
+// middleware (or stored under CSRFTokenKey), or "" if it is not in use.
+//
+//	<input type="hidden" name="csrf_token" value="{{csrfToken}}">
+func (c *Ctx) CSRFToken() string {
+	if token, ok := CSRFKey.Get(c); ok {
+		return token
+	}
+	return c.GetString(CSRFTokenKey)
+}
+
+// ErrNoRenderer is returned by Ctx.Render when the app has no renderer.
+var ErrNoRenderer = errors.New("marten: no renderer configured, see App.SetRenderer")
+
+// Renderer renders named templates. c is the current request, or nil when
+// rendering outside a request (e.g. an email body). A renderer may set the
+// response Content-Type through c; Ctx.Render defaults it to HTML.
+type Renderer interface {
+	Render(w io.Writer, name string, data any, c *Ctx) error
+}
+
+// SetRenderer sets the renderer used by Ctx.Render.
+//
+//	r, err := marten.NewTemplateRenderer(marten.TemplateConfig{FS: templates, Layout: "layouts/base"})
+//	app.SetRenderer(r)
+func (a *App) SetRenderer(r Renderer) {
+	a.renderer = r
+}
+
+// Render renders the named template with data and writes it with the given
+// status code. The output is buffered, so a template error leaves the
+// response untouched.
+//
+//	return c.Render(200, "users/show", marten.M{"User": user})
+func (c *Ctx) Render(code int, name string, data any) error {
+	c.checkLive()
+	if c.app == nil || c.app.renderer == nil {
+		return ErrNoRenderer
+	}
+
+	var buf bytes.Buffer
+	if err := c.app.renderer.Render(&buf, name, data, c); err != nil {
+		return err
+	}
+	contentType := c.Writer.Header().Get("Content-Type")
+	if contentType == "" {
+		contentType = "text/html; charset=utf-8"
+	}
+	return c.Blob(code, contentType, buf.Bytes())
+}
+
+// urlFor builds a path from a route pattern, filling :param and *wildcard
+// segments from args in order. Remaining args are key/value query pairs.
+//
+//	urlFor("/users/:id/posts", 42, "page", 2) // "/users/42/posts?page=2"
+func urlFor(pattern string, args ...any) (string, error) {
+	segments := strings.Split(pattern, "/")
+	for i, seg := range segments {
+		if !strings.HasPrefix(seg, ":") && !strings.HasPrefix(seg, "*") {
+			continue
+		}
+		if len(args) == 0 {
+			return "", fmt.Errorf("urlFor %s: missing value for %s", pattern, seg)
+		}
+		value := fmt.Sprint(args[0])
+		args = args[1:]
+		if seg[0] == '*' {
+			parts := strings.Split(value, "/")
+			for j, p := range parts {
+				parts[j] = url.PathEscape(p)
+			}
+			segments[i] = strings.Join(parts, "/")
+		} else {
+			segments[i] = url.PathEscape(value)
+		}
+	}
+	path := strings.Join(segments, "/")
+
+	if len(args)%2 != 0 {
+		return "", fmt.Errorf("urlFor %s: query arguments must be key/value pairs", pattern)
+	}
+	if len(args) > 0 {
+		q := url.Values{}
+		for i := 0; i < len(args); i += 2 {
+			q.Add(fmt.Sprint(args[i]), fmt.Sprint(args[i+1]))
+		}
+		path += "?" + q.Encode()
+	}
+	return path, nil
+}
@@ -0,0 +1,47 @@
+package marten
+
+import (
+	"context"
+	"strings"
+)
+
+// RequestIDKey holds the request ID set by middleware.RequestID.
+var RequestIDKey = NewKey[string]("request_id")
+
+// SetRequestID sets the ID returned by RequestID.
+func (c *Ctx) SetRequestID(id string) {
+	c.checkLive()
+	c.requestID = id
+	c.logger = nil
+}
+
+// ValidRequestID reports whether an incoming request ID is safe to use and
+// log: 1 to 128 letters, digits or "-_.:/+=".
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		switch c := id[i]; {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("-_.:/+=", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ContextWithRequestID returns a copy of ctx carrying the request ID.
+// middleware.RequestID adds it to the request's context.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return RequestIDKey.WithValue(ctx, id)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, or "". Use it
+// in code that only has a context.Context, such as database or HTTP client
+// calls.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := RequestIDKey.Value(ctx)
+	return id
+}
@@ -0,0 +1,186 @@
+package marten
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+)
+
+// ResponseWriter wraps the http.ResponseWriter of a request and records the
+// status code, the number of body bytes and whether headers were sent, no
+// matter whether the response is written through Ctx helpers, middleware or
+// an http.Handler. Ctx.Writer starts out as the Ctx's ResponseWriter.
+//
+// It implements http.Flusher, http.Hijacker, http.Pusher and io.ReaderFrom
+// by forwarding to the underlying writer, and Unwrap for
+// http.ResponseController.
+type ResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	size        int64
+	wroteHeader bool
+	hijacked    bool
+	before      []func()
+	after       []func(n int)
+}
+
+// NewResponseWriter wraps w.
+func NewResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	rw := &ResponseWriter{}
+	rw.reset(w)
+	return rw
+}
+
+func (w *ResponseWriter) reset(rw http.ResponseWriter) {
+	w.ResponseWriter = rw
+	w.status = 0
+	w.size = 0
+	w.wroteHeader = false
+	w.hijacked = false
+	clear(w.before)
+	clear(w.after)
+	w.before = w.before[:0]
+	w.after = w.after[:0]
+}
+
+// Status returns the status code sent, or 0 before headers are written.
+func (w *ResponseWriter) Status() int {
+	return w.status
+}
+
+// Size returns the number of body bytes written.
+func (w *ResponseWriter) Size() int64 {
+	return w.size
+}
+
+// Written reports whether the status line and headers have been sent.
+func (w *ResponseWriter) Written() bool {
+	return w.wroteHeader
+}
+
+// Hijacked reports whether the connection was taken over with Hijack.
+func (w *ResponseWriter) Hijacked() bool {
+	return w.hijacked
+}
+
+// BeforeWrite registers fn to run just before the headers are sent, while
+// they can still be changed. Status returns the code being sent. Hooks run
+// once, in registration order, and must not write the body.
+//
+//	c.Response().BeforeWrite(func() {
+//		c.Header("Server-Timing", "app;dur="+elapsed())
+//	})
+func (w *ResponseWriter) BeforeWrite(fn func()) {
+	w.before = append(w.before, fn)
+}
+
+// AfterWrite registers fn to run after each write to the body with the
+// number of bytes written.
+func (w *ResponseWriter) AfterWrite(fn func(n int)) {
+	w.after = append(w.after, fn)
+}
+
+// WriteHeader sends the status line and headers. Repeated calls are
+// ignored, except for 1xx informational responses, which are forwarded.
+func (w *ResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader || w.hijacked {
+		return
+	}
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+	for _, fn := range w.before {
+		fn()
+	}
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write writes the body, sending a 200 status first if needed.
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.wrote(n)
+	return n, err
+}
+
+// WriteString writes s to the body.
+func (w *ResponseWriter) WriteString(s string) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := io.WriteString(w.ResponseWriter, s)
+	w.wrote(n)
+	return n, err
+}
+
+// ReadFrom copies r to the body, using the underlying writer's ReadFrom
+// (e.g. sendfile) when available.
+func (w *ResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	var (
+		n   int64
+		err error
+	)
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(writerOnly{w.ResponseWriter}, r)
+	}
+	w.wrote(int(n))
+	return n, err
+}
+
+func (w *ResponseWriter) wrote(n int) {
+	w.size += int64(n)
+	for _, fn := range w.after {
+		fn(n)
+	}
+}
+
+// Flush sends buffered data to the client.
+func (w *ResponseWriter) Flush() {
+	_ = w.FlushError()
+}
+
+// FlushError is Flush with an error, as used by http.ResponseController.
+func (w *ResponseWriter) FlushError() error {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack takes over the connection.
+func (w *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// Push initiates an HTTP/2 server push, or returns http.ErrNotSupported.
+func (w *ResponseWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// Unwrap returns the underlying writer for http.ResponseController.
+func (w *ResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// writerOnly hides optional interfaces such as io.ReaderFrom from io.Copy.
+type writerOnly struct {
+	io.Writer
+}
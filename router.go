@@ -3,6 +3,7 @@ package marten
 import (
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 )
 
@@ -13,7 +14,9 @@ type node struct {
 	param    *node
 	wildcard *node
 	handlers map[string]Handler
-	mw       []Middleware
+	mw       map[string][]Middleware // route middleware by method
+	info     map[string]*RouteInfo   // route documentation by method
+	pattern  string                  // route pattern, e.g. "/users/:id"
 }
 
 // Router handles HTTP routing with a radix tree.
@@ -43,6 +46,9 @@ func NewRouter() *Router {
 			handlers: make(map[string]Handler),
 		},
 		notFound: func(c *Ctx) error {
+			if c.ProblemDetails() {
+				return c.Problem(NewProblem(http.StatusNotFound, ""))
+			}
 			_ = c.Text(http.StatusNotFound, "Not Found")
 			return nil
 		},
@@ -65,62 +71,80 @@ func (r *Router) NotFound(h Handler) {
 	r.notFound = h
 }
 
-// Handle registers a route with optional route-specific middleware.
+// Handle registers a route with optional route-specific middleware and
+// returns its RouteInfo for documentation.
 // Panics if a conflicting param route is detected (e.g., :id vs :name at same position).
-func (r *Router) Handle(method, path string, h Handler, mw ...Middleware) {
+func (r *Router) Handle(method, path string, h Handler, mw ...Middleware) *RouteInfo {
 	parts := splitPath(path)
 	current := r.root
+	pattern := ""
 
 	for _, part := range parts {
 		current = current.findOrCreateWithConflictCheck(part, path)
+		pattern += "/" + current.path
+	}
+	if pattern == "" {
+		pattern = "/"
 	}
+	current.pattern = pattern
 
 	if current.handlers == nil {
 		current.handlers = make(map[string]Handler)
 	}
+	if current.mw == nil {
+		current.mw = make(map[string][]Middleware)
+		current.info = make(map[string]*RouteInfo)
+	}
+	info := &RouteInfo{Route: Route{Method: method, Path: pattern}}
 	current.handlers[method] = h
-	current.mw = mw
+	current.mw[method] = mw
+	current.info[method] = info
+	return info
 }
 
 // GET registers a GET route.
-func (r *Router) GET(path string, h Handler, mw ...Middleware) {
-	r.Handle(http.MethodGet, path, h, mw...)
+func (r *Router) GET(path string, h Handler, mw ...Middleware) *RouteInfo {
+	return r.Handle(http.MethodGet, path, h, mw...)
 }
 
 // POST registers a POST route.
-func (r *Router) POST(path string, h Handler, mw ...Middleware) {
-	r.Handle(http.MethodPost, path, h, mw...)
+func (r *Router) POST(path string, h Handler, mw ...Middleware) *RouteInfo {
+	return r.Handle(http.MethodPost, path, h, mw...)
 }
 
 // PUT registers a PUT route.
-func (r *Router) PUT(path string, h Handler, mw ...Middleware) {
-	r.Handle(http.MethodPut, path, h, mw...)
+func (r *Router) PUT(path string, h Handler, mw ...Middleware) *RouteInfo {
+	return r.Handle(http.MethodPut, path, h, mw...)
 }
 
 // DELETE registers a DELETE route.
-func (r *Router) DELETE(path string, h Handler, mw ...Middleware) {
-	r.Handle(http.MethodDelete, path, h, mw...)
+func (r *Router) DELETE(path string, h Handler, mw ...Middleware) *RouteInfo {
+	return r.Handle(http.MethodDelete, path, h, mw...)
 }
 
 // PATCH registers a PATCH route.
-func (r *Router) PATCH(path string, h Handler, mw ...Middleware) {
-	r.Handle(http.MethodPatch, path, h, mw...)
+func (r *Router) PATCH(path string, h Handler, mw ...Middleware) *RouteInfo {
+	return r.Handle(http.MethodPatch, path, h, mw...)
 }
 
 // HEAD registers a HEAD route.
-func (r *Router) HEAD(path string, h Handler, mw ...Middleware) {
-	r.Handle(http.MethodHead, path, h, mw...)
+func (r *Router) HEAD(path string, h Handler, mw ...Middleware) *RouteInfo {
+	return r.Handle(http.MethodHead, path, h, mw...)
 }
 
 // OPTIONS registers an OPTIONS route.
-func (r *Router) OPTIONS(path string, h Handler, mw ...Middleware) {
-	r.Handle(http.MethodOptions, path, h, mw...)
+func (r *Router) OPTIONS(path string, h Handler, mw ...Middleware) *RouteInfo {
+	return r.Handle(http.MethodOptions, path, h, mw...)
 }
 
 // Routes returns all registered routes for debugging.
 func (r *Router) Routes() []Route {
-	var routes []Route
-	r.collectRoutes(r.root, "", &routes)
+	var entries []routeEntry
+	r.collectRoutes(r.root, "", &entries)
+	routes := make([]Route, len(entries))
+	for i, e := range entries {
+		routes[i] = e.Route
+	}
 	return routes
 }
 
@@ -130,14 +154,28 @@ type Route struct {
 	Path   string
 }
 
-func (r *Router) collectRoutes(n *node, path string, routes *[]Route) {
+// routeEntry is a registered route with its handler, route middleware and
+// documentation.
+type routeEntry struct {
+	Route
+	handler Handler
+	mw      []Middleware
+	info    *RouteInfo
+}
+
+func (r *Router) collectRoutes(n *node, path string, routes *[]routeEntry) {
 	currentPath := path
 	if n.path != "" {
 		currentPath = path + "/" + n.path
 	}
 
-	for method := range n.handlers {
-		*routes = append(*routes, Route{Method: method, Path: currentPath})
+	for method, h := range n.handlers {
+		*routes = append(*routes, routeEntry{
+			Route:   Route{Method: method, Path: currentPath},
+			handler: h,
+			mw:      n.mw[method],
+			info:    n.info[method],
+		})
 	}
 
 	for _, child := range n.children {
@@ -151,6 +189,24 @@ func (r *Router) collectRoutes(n *node, path string, routes *[]Route) {
 	}
 }
 
+// routeEntries returns all registered routes sorted by path and method.
+func (r *Router) routeEntries() []routeEntry {
+	var entries []routeEntry
+	r.collectRoutes(r.root, "", &entries)
+	for i := range entries {
+		if entries[i].Path == "" {
+			entries[i].Path = "/"
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Path != entries[j].Path {
+			return entries[i].Path < entries[j].Path
+		}
+		return entries[i].Method < entries[j].Method
+	})
+	return entries
+}
+
 func (n *node) findOrCreateWithConflictCheck(segment, fullPath string) *node {
 	if strings.HasPrefix(segment, "*") {
 		if n.wildcard == nil {
@@ -181,7 +237,10 @@ func (n *node) findOrCreateWithConflictCheck(segment, fullPath string) *node {
 	return child
 }
 
-func (r *Router) lookup(method string, path string, params map[string]string) (Handler, []Middleware, []string) {
+// lookup returns the handler and route middleware for method and path, the
+// allowed methods if only the method didn't match, and the matched route
+// pattern.
+func (r *Router) lookup(method string, path string, params map[string]string) (Handler, []Middleware, []string, string) {
 	parts := splitPath(path)
 	current := r.root
 
@@ -212,13 +271,13 @@ func (r *Router) lookup(method string, path string, params map[string]string) (H
 		}
 
 		if !found {
-			return nil, nil, nil
+			return nil, nil, nil, ""
 		}
 	}
 
 	// Check if we have a handler at current node
 	if h, ok := current.handlers[method]; ok {
-		return h, current.mw, nil
+		return h, current.mw[method], nil, current.pattern
 	}
 
 	// If no handler but we have a wildcard child, try matching with empty wildcard
@@ -226,7 +285,7 @@ func (r *Router) lookup(method string, path string, params map[string]string) (H
 		wildcardName := current.wildcard.path[1:]
 		params[wildcardName] = ""
 		if h, ok := current.wildcard.handlers[method]; ok {
-			return h, current.wildcard.mw, nil
+			return h, current.wildcard.mw[method], nil, current.wildcard.pattern
 		}
 		// Check for allowed methods on wildcard
 		if len(current.wildcard.handlers) > 0 {
@@ -234,7 +293,7 @@ func (r *Router) lookup(method string, path string, params map[string]string) (H
 			for m := range current.wildcard.handlers {
 				allowed = append(allowed, m)
 			}
-			return nil, nil, allowed
+			return nil, nil, allowed, current.wildcard.pattern
 		}
 	}
 
@@ -244,47 +303,47 @@ func (r *Router) lookup(method string, path string, params map[string]string) (H
 		for m := range current.handlers {
 			allowed = append(allowed, m)
 		}
-		return nil, nil, allowed
+		return nil, nil, allowed, current.pattern
 	}
 
-	return nil, nil, nil
+	return nil, nil, nil, ""
 }
 
 // lookupWithTrailingSlash tries to find a route, and if not found,
 // tries the alternate path (with or without trailing slash).
-// Returns: handler, middleware, allowed methods, redirect path (if should redirect)
-func (r *Router) lookupWithTrailingSlash(method string, path string, params map[string]string) (Handler, []Middleware, []string, string) {
+// Returns: handler, middleware, allowed methods, route pattern, redirect path (if should redirect)
+func (r *Router) lookupWithTrailingSlash(method string, path string, params map[string]string) (Handler, []Middleware, []string, string, string) {
 	hasTrailingSlash := len(path) > 1 && strings.HasSuffix(path, "/")
 
 	// In strict mode, trailing slash matters
 	if r.trailingSlash == TrailingSlashStrict && hasTrailingSlash {
 		// Path has trailing slash - only match if route was registered with trailing slash
 		// Since splitPath normalizes, we can't distinguish, so treat as not found
-		return nil, nil, nil, ""
+		return nil, nil, nil, "", ""
 	}
 
 	// Lookup with normalized path
-	h, mw, allowed := r.lookup(method, path, params)
+	h, mw, allowed, pattern := r.lookup(method, path, params)
 
 	if h != nil {
 		// Found handler - check if we need to redirect
 		if r.trailingSlash == TrailingSlashRedirect && hasTrailingSlash {
 			normalizedPath := strings.TrimSuffix(path, "/")
-			return nil, nil, nil, normalizedPath
+			return nil, nil, nil, "", normalizedPath
 		}
-		return h, mw, allowed, ""
+		return h, mw, allowed, pattern, ""
 	}
 
 	// If we have allowed methods, path exists
 	if len(allowed) > 0 {
 		if r.trailingSlash == TrailingSlashRedirect && hasTrailingSlash {
 			normalizedPath := strings.TrimSuffix(path, "/")
-			return nil, nil, nil, normalizedPath
+			return nil, nil, nil, "", normalizedPath
 		}
-		return nil, nil, allowed, ""
+		return nil, nil, allowed, pattern, ""
 	}
 
-	return nil, nil, nil, ""
+	return nil, nil, nil, "", ""
 }
 
 func splitPath(path string) []string {
@@ -0,0 +1,41 @@
+package marten
+
+// SessionKey holds the request's Session, set by middleware.Session.
+var SessionKey = NewKey[Session]("session")
+
+// Session is the data kept for a client across requests, provided by
+// middleware.Session. Changes are saved when the response is sent, and only
+// if the session was modified.
+type Session interface {
+	// ID returns the session ID, or "" for a new session that has not been
+	// saved yet.
+	ID() string
+
+	Get(key string) any
+	Set(key string, value any)
+	Delete(key string)
+	// Clear removes all values and flash messages.
+	Clear()
+
+	// AddFlash adds a message to be shown once, e.g. after a redirect.
+	AddFlash(key, msg string)
+	// Flashes returns and removes the flash messages for key.
+	Flashes(key string) []string
+
+	// Regenerate moves the session to a new ID when it is saved. Call it
+	// when the user logs in or changes privileges to prevent session
+	// fixation.
+	Regenerate()
+	// Destroy removes the session from the store and the client.
+	Destroy()
+}
+
+// Session returns the request's session, or nil if middleware.Session is not
+// in use.
+//
+//	c.Session().Set("user_id", user.ID)
+//	c.Session().Regenerate()
+func (c *Ctx) Session() Session {
+	s, _ := SessionKey.Get(c)
+	return s
+}
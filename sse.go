@@ -0,0 +1,219 @@
+package marten
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MIMEEventStream is the Server-Sent Events content type.
+const MIMEEventStream = "text/event-stream"
+
+// DefaultHeartbeat is the interval between heartbeat comments on an EventStream.
+const DefaultHeartbeat = 15 * time.Second
+
+// EventStream writes Server-Sent Events to the client. It is safe for
+// concurrent use.
+type EventStream struct {
+	c         *Ctx
+	ctx       context.Context
+	rc        *http.ResponseController
+	mu        sync.Mutex
+	heartbeat chan time.Duration
+}
+
+// SSE switches the response to a Server-Sent Events stream and calls fn.
+// Headers are sent immediately; a heartbeat comment keeps idle connections
+// open (see EventStream.SetHeartbeat). The stream ends when fn returns. A
+// client disconnect cancels stream.Context(); fn should return when it does,
+// and the resulting context error is not reported.
+//
+//	return c.SSE(func(s *marten.EventStream) error {
+//		for msg := range updates(s.Context(), s.LastEventID()) {
+//			if err := s.Send("update", msg.ID, msg); err != nil {
+//				return err
+//			}
+//		}
+//		return nil
+//	})
+func (c *Ctx) SSE(fn func(stream *EventStream) error) error {
+	c.checkLive()
+	h := c.Writer.Header()
+	h.Set("Content-Type", MIMEEventStream)
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no") // disable proxy buffering (nginx)
+	h.Del("Content-Length")
+	c.Status(http.StatusOK)
+
+	ctx := c.Context()
+	s := &EventStream{
+		c:         c,
+		ctx:       ctx,
+		rc:        http.NewResponseController(c.Writer),
+		heartbeat: make(chan time.Duration, 1),
+	}
+	if err := s.flush(); err != nil {
+		return err
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.runHeartbeat(ctx, stop)
+	}()
+
+	err := fn(s)
+	close(stop)
+	wg.Wait()
+
+	if ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+		return nil
+	}
+	return err
+}
+
+// AcceptsEventStream reports whether the client asked for a Server-Sent
+// Events stream. Buffering middleware uses it to pass such requests through.
+func (c *Ctx) AcceptsEventStream() bool {
+	c.checkLive()
+	return strings.Contains(c.Request.Header.Get("Accept"), MIMEEventStream)
+}
+
+// Send writes an event. event and id are optional. data is written as-is
+// for string and []byte values and JSON-encoded otherwise; multi-line data
+// is split across data lines. It returns the context error once the client
+// has gone away.
+func (s *EventStream) Send(event, id string, data any) error {
+	if strings.ContainsAny(event, "\r\n") || strings.ContainsAny(id, "\r\n\x00") {
+		return fmt.Errorf("marten: SSE event and id must not contain newlines")
+	}
+
+	var payload string
+	switch v := data.(type) {
+	case string:
+		payload = v
+	case []byte:
+		payload = string(v)
+	default:
+		var buf bytes.Buffer
+		if err := s.c.encodeJSON(&buf, v); err != nil {
+			return err
+		}
+		payload = strings.TrimSuffix(buf.String(), "\n")
+	}
+
+	var b strings.Builder
+	if event != "" {
+		b.WriteString("event: " + event + "\n")
+	}
+	if id != "" {
+		b.WriteString("id: " + id + "\n")
+	}
+	for _, line := range sseLines(payload) {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+	return s.write(b.String())
+}
+
+// Retry tells the client how long to wait before reconnecting.
+func (s *EventStream) Retry(d time.Duration) error {
+	return s.write("retry: " + strconv.FormatInt(d.Milliseconds(), 10) + "\n\n")
+}
+
+// Comment writes a comment line, which clients ignore.
+func (s *EventStream) Comment(text string) error {
+	return s.write(": " + strings.Join(sseLines(text), "\n: ") + "\n\n")
+}
+
+// sseLines splits s at every line terminator the SSE format recognises
+// (\r\n, \n and a lone \r), so data cannot start a new field.
+func sseLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	return strings.Split(s, "\n")
+}
+
+// LastEventID returns the Last-Event-ID header sent by a reconnecting
+// client, so the stream can resume after that event.
+func (s *EventStream) LastEventID() string {
+	return s.c.Request.Header.Get("Last-Event-ID")
+}
+
+// Context returns the request context, which is cancelled when the client
+// disconnects.
+func (s *EventStream) Context() context.Context {
+	return s.ctx
+}
+
+// SetHeartbeat changes the heartbeat interval (default DefaultHeartbeat).
+// Zero disables the heartbeat. It never blocks; when called concurrently,
+// the last pending value wins.
+func (s *EventStream) SetHeartbeat(d time.Duration) {
+	for {
+		select {
+		case s.heartbeat <- d:
+			return
+		default:
+		}
+		// Replace a value the heartbeat goroutine has not picked up yet
+		select {
+		case <-s.heartbeat:
+		default:
+		}
+	}
+}
+
+func (s *EventStream) write(msg string) error {
+	if err := s.Context().Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.c.Writer.Write([]byte(msg)); err != nil {
+		return err
+	}
+	return s.flush()
+}
+
+func (s *EventStream) flush() error {
+	if err := s.rc.Flush(); err != nil {
+		if errors.Is(err, http.ErrNotSupported) {
+			return fmt.Errorf("marten: SSE requires a response writer that supports flushing: %w", err)
+		}
+		return err
+	}
+	return nil
+}
+
+// runHeartbeat writes a comment every interval until stop or ctx is done.
+func (s *EventStream) runHeartbeat(ctx context.Context, stop <-chan struct{}) {
+	ticker := time.NewTicker(DefaultHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case d := <-s.heartbeat:
+			if d <= 0 {
+				ticker.Stop()
+				continue
+			}
+			ticker.Reset(d)
+		case <-ticker.C:
+			if s.Comment("heartbeat") != nil {
+				return
+			}
+		}
+	}
+}
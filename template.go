@@ -0,0 +1,297 @@
+package marten
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"io/fs"
+	"mime"
+	"path"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"text/template/parse"
+)
+
+// TemplateConfig configures NewTemplateRenderer.
+type TemplateConfig struct {
+	// FS holds the templates, e.g. an embed.FS or os.DirFS("templates").
+	FS fs.FS
+	// Layout is the default layout for pages, e.g. "layouts/base" (optional).
+	// A page can pick another one with {{define "layout"}}layouts/admin{{end}},
+	// or none with an empty "layout" template.
+	Layout string
+	// LayoutsDir holds layout templates (default: "layouts").
+	LayoutsDir string
+	// PartialsDir holds partials available to every page (default: "partials").
+	PartialsDir string
+	// Funcs adds template functions to the built-in urlFor and csrfToken.
+	Funcs map[string]any
+	// Reload re-parses the templates on every render. Enable it in
+	// development with os.DirFS to pick up changes without restarting.
+	Reload bool
+}
+
+// TemplateRenderer renders templates loaded from an fs.FS. Files ending in
+// .html, .htm or .gohtml use html/template; all others (e.g. .txt for email)
+// use text/template. Templates are named by their path without extension,
+// e.g. "users/show" for users/show.html; the full file name also works.
+//
+// Layouts wrap pages: a layout declares blocks such as
+// {{block "content" .}}{{end}} and pages fill them with
+// {{define "content"}}...{{end}}. Partials are included with
+// {{template "partials/nav" .}}.
+type TemplateRenderer struct {
+	cfg   TemplateConfig
+	mu    sync.RWMutex
+	pages map[string]*templatePage
+}
+
+// templatePage is a parsed page with its layouts and partials. The stored
+// templates are never executed; renders execute pooled clones whose
+// request-scoped functions read the current request.
+type templatePage struct {
+	name        string
+	layout      string
+	contentType string
+	html        *htmltemplate.Template
+	text        *texttemplate.Template
+	clones      sync.Pool
+}
+
+// pageClone is a clone of a page's template with csrfToken bound to c.
+type pageClone struct {
+	c    *Ctx
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+func (pc *pageClone) csrfToken() string {
+	if pc.c == nil {
+		return ""
+	}
+	return pc.c.CSRFToken()
+}
+
+// clone returns an unused clone of p's template.
+func (p *templatePage) clone() (*pageClone, error) {
+	if pc, ok := p.clones.Get().(*pageClone); ok {
+		return pc, nil
+	}
+	pc := &pageClone{}
+	funcs := map[string]any{"csrfToken": pc.csrfToken}
+	if p.html != nil {
+		t, err := p.html.Clone()
+		if err != nil {
+			return nil, err
+		}
+		pc.html = t.Funcs(funcs)
+	} else {
+		t, err := p.text.Clone()
+		if err != nil {
+			return nil, err
+		}
+		pc.text = t.Funcs(funcs)
+	}
+	return pc, nil
+}
+
+// NewTemplateRenderer parses all templates in cfg.FS.
+func NewTemplateRenderer(cfg TemplateConfig) (*TemplateRenderer, error) {
+	if cfg.FS == nil {
+		return nil, fmt.Errorf("marten: TemplateConfig.FS is required")
+	}
+	if cfg.LayoutsDir == "" {
+		cfg.LayoutsDir = "layouts"
+	}
+	if cfg.PartialsDir == "" {
+		cfg.PartialsDir = "partials"
+	}
+	r := &TemplateRenderer{cfg: cfg}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload re-parses the templates. The previous templates stay in use if
+// parsing fails.
+func (r *TemplateRenderer) Reload() error {
+	pages, err := r.load()
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.pages = pages
+	r.mu.Unlock()
+	return nil
+}
+
+// Render implements Renderer. c may be nil outside a request.
+func (r *TemplateRenderer) Render(w io.Writer, name string, data any, c *Ctx) error {
+	if r.cfg.Reload {
+		if err := r.Reload(); err != nil {
+			return err
+		}
+	}
+	r.mu.RLock()
+	p, ok := r.pages[name]
+	r.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("marten: template %q not found", name)
+	}
+
+	entry := p.name
+	if p.layout != "" {
+		entry = p.layout
+	}
+	pc, err := p.clone()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	pc.c = c
+	if pc.html != nil {
+		err = pc.html.ExecuteTemplate(&buf, entry, data)
+	} else {
+		err = pc.text.ExecuteTemplate(&buf, entry, data)
+	}
+	pc.c = nil
+	p.clones.Put(pc)
+	if err != nil {
+		return err
+	}
+
+	if c != nil && c.Writer.Header().Get("Content-Type") == "" {
+		c.Writer.Header().Set("Content-Type", p.contentType)
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// templateFile is a template source read from the FS.
+type templateFile struct {
+	name string // path without extension
+	ext  string
+	src  string
+}
+
+func (f templateFile) isHTML() bool {
+	switch f.ext {
+	case ".html", ".htm", ".gohtml":
+		return true
+	}
+	return false
+}
+
+// load parses every page together with the layouts and partials of its kind.
+func (r *TemplateRenderer) load() (map[string]*templatePage, error) {
+	var shared, pages []templateFile
+	err := fs.WalkDir(r.cfg.FS, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := fs.ReadFile(r.cfg.FS, p)
+		if err != nil {
+			return err
+		}
+		ext := path.Ext(p)
+		f := templateFile{name: strings.TrimSuffix(p, ext), ext: ext, src: string(b)}
+		if strings.HasPrefix(p, r.cfg.LayoutsDir+"/") || strings.HasPrefix(p, r.cfg.PartialsDir+"/") {
+			shared = append(shared, f)
+		} else {
+			pages = append(pages, f)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marten: loading templates: %w", err)
+	}
+
+	funcs := r.funcs()
+	htmlBase := htmltemplate.New("").Funcs(funcs)
+	textBase := texttemplate.New("").Funcs(funcs)
+	for _, f := range shared {
+		var err error
+		if f.isHTML() {
+			_, err = htmlBase.New(f.name).Parse(f.src)
+		} else {
+			_, err = textBase.New(f.name).Parse(f.src)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("marten: parsing template %s%s: %w", f.name, f.ext, err)
+		}
+	}
+
+	result := make(map[string]*templatePage, len(pages)*2)
+	for _, f := range pages {
+		p := &templatePage{name: f.name}
+		var (
+			override *parse.Tree
+			defined  func(name string) bool
+		)
+		if f.isHTML() {
+			t, err := htmlBase.Clone()
+			if err == nil {
+				_, err = t.New(f.name).Parse(f.src)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("marten: parsing template %s%s: %w", f.name, f.ext, err)
+			}
+			p.html = t
+			p.contentType = "text/html; charset=utf-8"
+			if lt := t.Lookup("layout"); lt != nil {
+				override = lt.Tree
+			}
+			defined = func(name string) bool { return t.Lookup(name) != nil }
+		} else {
+			t, err := textBase.Clone()
+			if err == nil {
+				_, err = t.New(f.name).Parse(f.src)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("marten: parsing template %s%s: %w", f.name, f.ext, err)
+			}
+			p.text = t
+			p.contentType = mime.TypeByExtension(f.ext)
+			if p.contentType == "" {
+				p.contentType = "text/plain; charset=utf-8"
+			}
+			if lt := t.Lookup("layout"); lt != nil {
+				override = lt.Tree
+			}
+			defined = func(name string) bool { return t.Lookup(name) != nil }
+		}
+
+		switch {
+		case override != nil:
+			p.layout = strings.TrimSpace(override.Root.String())
+			if p.layout != "" && !defined(p.layout) {
+				return nil, fmt.Errorf("marten: template %s%s: layout %q not found", f.name, f.ext, p.layout)
+			}
+		case r.cfg.Layout != "" && defined(r.cfg.Layout):
+			// Pages of the other kind (e.g. text emails) have no default layout
+			p.layout = r.cfg.Layout
+		}
+
+		result[f.name+f.ext] = p
+		if _, ok := result[f.name]; !ok {
+			result[f.name] = p
+		}
+	}
+	return result, nil
+}
+
+// funcs returns the template functions available at parse time.
+// csrfToken is a placeholder until a pageClone binds it.
+func (r *TemplateRenderer) funcs() map[string]any {
+	funcs := map[string]any{
+		"urlFor":    urlFor,
+		"csrfToken": func() string { return "" },
+	}
+	for k, v := range r.cfg.Funcs {
+		funcs[k] = v
+	}
+	return funcs
+}
@@ -94,6 +94,7 @@ func BenchmarkMiddlewareStack(b *testing.B) {
 
 func BenchmarkContextHelpers(b *testing.B) {
 	app := marten.New()
+	app.TrustedProxies([]string{"192.0.2.0/24"})
 	app.GET("/search", func(c *marten.Ctx) error {
 		_ = c.Query("q")
 		_ = c.QueryInt("page")
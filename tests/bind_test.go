@@ -0,0 +1,222 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gomarten/marten"
+)
+
+type bindFilter struct {
+	Sort string `query:"sort" default:"name"`
+}
+
+type bindRequest struct {
+	ID      int           `param:"id"`
+	Page    int           `query:"page" default:"1"`
+	Limit   *int          `query:"limit"`
+	Active  bool          `query:"active"`
+	Tags    []string      `query:"tag"`
+	IDs     []int64       `query:"ids"`
+	Since   time.Time     `query:"since"`
+	Wait    time.Duration `query:"wait"`
+	IP      net.IP        `query:"ip"`
+	Tenant  string        `header:"X-Tenant"`
+	Session string        `cookie:"sid"`
+	Name    string        `json:"name"`
+	Score   float64       `json:"score"`
+	bindFilter
+}
+
+func TestBindAllSources(t *testing.T) {
+	app := marten.New()
+	var got bindRequest
+	app.POST("/users/:id", func(c *marten.Ctx) error {
+		if err := c.Bind(&got); err != nil {
+			return err
+		}
+		return c.NoContent()
+	})
+
+	q := url.Values{}
+	q.Set("limit", "50")
+	q.Set("active", "true")
+	q.Add("tag", "a")
+	q.Add("tag", "b")
+	q.Add("ids", "7")
+	q.Add("ids", "9")
+	q.Set("since", "2026-01-02")
+	q.Set("wait", "1500ms")
+	q.Set("ip", "10.0.0.1")
+	req := httptest.NewRequest("POST", "/users/42?"+q.Encode(), strings.NewReader(`{"name":"bob","score":9.5}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Tenant", "acme")
+	req.AddCookie(&http.Cookie{Name: "sid", Value: "s3"})
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	if rec.Code != 204 {
+		t.Fatalf("expected 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got.ID != 42 || got.Page != 1 || got.Limit == nil || *got.Limit != 50 || !got.Active {
+		t.Errorf("unexpected scalars: %+v", got)
+	}
+	if len(got.Tags) != 2 || got.Tags[1] != "b" || len(got.IDs) != 2 || got.IDs[1] != 9 {
+		t.Errorf("unexpected slices: %v %v", got.Tags, got.IDs)
+	}
+	if got.Since.Format("2006-01-02") != "2026-01-02" || got.Wait != 1500*time.Millisecond {
+		t.Errorf("unexpected time values: %v %v", got.Since, got.Wait)
+	}
+	if !got.IP.Equal(net.ParseIP("10.0.0.1")) {
+		t.Errorf("expected TextUnmarshaler binding, got %v", got.IP)
+	}
+	if got.Tenant != "acme" || got.Session != "s3" {
+		t.Errorf("unexpected header/cookie: %q %q", got.Tenant, got.Session)
+	}
+	if got.Name != "bob" || got.Score != 9.5 {
+		t.Errorf("unexpected body fields: %q %v", got.Name, got.Score)
+	}
+	if got.Sort != "name" {
+		t.Errorf("expected embedded default, got %q", got.Sort)
+	}
+}
+
+func TestBindWithoutBodyForTaggedStruct(t *testing.T) {
+	app := marten.New()
+	app.GET("/items", func(c *marten.Ctx) error {
+		var q struct {
+			Page int `query:"page" default:"3"`
+		}
+		if err := c.Bind(&q); err != nil {
+			return err
+		}
+		return c.OK(q)
+	})
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/items", nil))
+	if rec.Code != 200 || !strings.Contains(rec.Body.String(), `"Page":3`) {
+		t.Errorf("unexpected response %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestBindConversionErrors(t *testing.T) {
+	app := marten.New()
+	var bindErr error
+	app.GET("/users/:id", func(c *marten.Ctx) error {
+		var q struct {
+			ID   int  `param:"id"`
+			Page int  `query:"page"`
+			Flag bool `query:"flag"`
+		}
+		bindErr = c.Bind(&q)
+		return bindErr
+	})
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/users/abc?page=x&flag=maybe", nil))
+
+	if rec.Code != 400 {
+		t.Errorf("expected 400, got %d", rec.Code)
+	}
+	var be *marten.BindError
+	if !errors.As(bindErr, &be) {
+		t.Fatalf("expected BindError, got %v", bindErr)
+	}
+	if len(be.Fields) != 3 {
+		t.Fatalf("expected 3 field errors, got %v", be.Fields)
+	}
+	if be.Fields[0].Source != "param" || be.Fields[0].Key != "id" || be.Fields[0].Value != "abc" {
+		t.Errorf("unexpected field error: %+v", be.Fields[0])
+	}
+	if !strings.Contains(rec.Body.String(), `query \"page\"`) {
+		t.Errorf("expected per-field message, got %s", rec.Body.String())
+	}
+}
+
+func TestBindConversionErrorsProblemDetails(t *testing.T) {
+	app := marten.New()
+	app.SetProblemDetails(true)
+	app.GET("/items", func(c *marten.Ctx) error {
+		var q struct {
+			Page int `query:"page"`
+		}
+		return c.Bind(&q)
+	})
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/items?page=x", nil))
+	if !strings.Contains(rec.Body.String(), `"invalid-params":[{"name":"page"`) {
+		t.Errorf("expected invalid-params, got %s", rec.Body.String())
+	}
+}
+
+func TestBindFormTypeConversion(t *testing.T) {
+	app := marten.New()
+	app.POST("/form", func(c *marten.Ctx) error {
+		var f struct {
+			Name  string   `form:"name"`
+			Age   int      `form:"age"`
+			Tags  []string `json:"tags"`
+			Admin bool
+		}
+		if err := c.Bind(&f); err != nil {
+			return err
+		}
+		return c.OK(f)
+	})
+
+	form := url.Values{}
+	form.Set("name", "Ann")
+	form.Set("age", "42")
+	form.Add("tags", "x")
+	form.Add("tags", "y")
+	form.Set("Admin", "true")
+	req := httptest.NewRequest("POST", "/form", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	want := `{"Name":"Ann","Age":42,"tags":["x","y"],"Admin":true}`
+	if strings.TrimSpace(rec.Body.String()) != want {
+		t.Errorf("expected %s, got %s", want, rec.Body.String())
+	}
+}
+
+func TestBindMultipartFile(t *testing.T) {
+	app := marten.New()
+	app.POST("/upload", func(c *marten.Ctx) error {
+		var f struct {
+			Title string                `form:"title"`
+			File  *multipart.FileHeader `form:"file"`
+		}
+		if err := c.Bind(&f); err != nil {
+			return err
+		}
+		return c.OK(marten.M{"title": f.Title, "file": f.File.Filename})
+	})
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	_ = w.WriteField("title", "report")
+	fw, _ := w.CreateFormFile("file", "report.txt")
+	_, _ = fw.Write([]byte("data"))
+	w.Close()
+
+	req := httptest.NewRequest("POST", "/upload", &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	if !strings.Contains(rec.Body.String(), `"file":"report.txt"`) {
+		t.Errorf("expected file binding, got %d %s", rec.Code, rec.Body.String())
+	}
+}
@@ -354,6 +354,8 @@ func TestContextRedirect(t *testing.T) {
 
 func TestContextClientIP(t *testing.T) {
 	app := marten.New()
+	// httptest requests come from 192.0.2.1
+	app.TrustedProxies([]string{"192.0.2.0/24", "70.41.3.18", "150.172.238.178"})
 	app.GET("/ip", func(c *marten.Ctx) error {
 		return c.Text(200, c.ClientIP())
 	})
@@ -0,0 +1,241 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gomarten/marten"
+)
+
+var (
+	cookieSecret    = bytes.Repeat([]byte("k"), 32)
+	oldCookieSecret = bytes.Repeat([]byte("o"), 32)
+)
+
+func cookieApp(t *testing.T, secrets ...[]byte) *marten.App {
+	t.Helper()
+	keys, err := marten.NewKeyring(secrets[0], secrets[1:]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := marten.New()
+	app.SetKeyring(keys)
+	app.GET("/set", func(c *marten.Ctx) error {
+		ck := &http.Cookie{Name: "uid", Value: c.Query("v"), MaxAge: c.QueryInt("max_age")}
+		var err error
+		if c.QueryBool("encrypt") {
+			err = c.SetEncryptedCookie(ck)
+		} else {
+			err = c.SetSignedCookie(ck)
+		}
+		if err != nil {
+			return err
+		}
+		return c.NoContent()
+	})
+	app.GET("/get", func(c *marten.Ctx) error {
+		var (
+			v   string
+			err error
+		)
+		if c.QueryBool("encrypt") {
+			v, err = c.EncryptedCookie("uid")
+		} else {
+			v, err = c.SignedCookie("uid")
+		}
+		switch {
+		case errors.Is(err, marten.ErrCookieExpired):
+			return c.Text(401, "expired")
+		case errors.Is(err, marten.ErrInvalidCookie):
+			return c.Text(401, "invalid")
+		case errors.Is(err, http.ErrNoCookie):
+			return c.Text(401, "missing")
+		case err != nil:
+			return err
+		}
+		return c.Text(200, v)
+	})
+	return app
+}
+
+func setCookie(t *testing.T, app *marten.App, query string) *http.Cookie {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/set?"+query, nil))
+	cookies := rec.Result().Cookies()
+	if rec.Code != 204 || len(cookies) != 1 {
+		t.Fatalf("set: %d %v", rec.Code, cookies)
+	}
+	return cookies[0]
+}
+
+func getCookie(app *marten.App, query string, ck *http.Cookie) (int, string) {
+	req := httptest.NewRequest("GET", "/get?"+query, nil)
+	if ck != nil {
+		req.AddCookie(ck)
+	}
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	return rec.Code, rec.Body.String()
+}
+
+func TestSignedCookie(t *testing.T) {
+	app := cookieApp(t, cookieSecret)
+	ck := setCookie(t, app, "v=user-42")
+
+	if code, body := getCookie(app, "", ck); code != 200 || body != "user-42" {
+		t.Errorf("got %d %q", code, body)
+	}
+	if code, body := getCookie(app, "", nil); code != 401 || body != "missing" {
+		t.Errorf("missing cookie: %d %q", code, body)
+	}
+}
+
+func TestSignedCookieTampered(t *testing.T) {
+	app := cookieApp(t, cookieSecret)
+	ck := setCookie(t, app, "v=user-42")
+
+	payload, sig, _ := strings.Cut(ck.Value, ".")
+	for name, value := range map[string]string{
+		"signature": payload + "." + flip(sig, 5),
+		"payload":   flip(payload, len(payload)-3) + "." + sig,
+		"format":    payload,
+		"encoding":  "!!!." + sig,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if code, body := getCookie(app, "", &http.Cookie{Name: "uid", Value: value}); code != 401 || body != "invalid" {
+				t.Errorf("got %d %q", code, body)
+			}
+		})
+	}
+
+	// A value signed for another cookie name is rejected
+	keys := app.Keyring()
+	other := keys.Sign("role", []byte("user-42"), 0)
+	if code, _ := getCookie(app, "", &http.Cookie{Name: "uid", Value: other}); code != 401 {
+		t.Errorf("cookie moved between names should be invalid, got %d", code)
+	}
+}
+
+// flip changes the base64 character at i.
+func flip(s string, i int) string {
+	c := byte('A')
+	if s[i] == 'A' {
+		c = 'B'
+	}
+	return s[:i] + string(c) + s[i+1:]
+}
+
+func TestEncryptedCookie(t *testing.T) {
+	app := cookieApp(t, cookieSecret)
+	ck := setCookie(t, app, "v=secret-value&encrypt=1")
+
+	if strings.Contains(ck.Value, "secret") {
+		t.Errorf("encrypted value should not be readable: %s", ck.Value)
+	}
+	if code, body := getCookie(app, "encrypt=1", ck); code != 200 || body != "secret-value" {
+		t.Errorf("got %d %q", code, body)
+	}
+
+	tampered := *ck
+	tampered.Value = flip(ck.Value, len(ck.Value)/2)
+	if code, body := getCookie(app, "encrypt=1", &tampered); code != 401 || body != "invalid" {
+		t.Errorf("tampered: %d %q", code, body)
+	}
+	// Signed and encrypted values are not interchangeable
+	if code, _ := getCookie(app, "", ck); code != 401 {
+		t.Errorf("encrypted cookie read as signed: %d", code)
+	}
+}
+
+func TestSecureCookieExpiry(t *testing.T) {
+	keys, _ := marten.NewKeyring(cookieSecret)
+	signed := keys.Sign("uid", []byte("42"), 20*time.Millisecond)
+	encrypted, err := keys.Encrypt("uid", []byte("42"), 20*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := keys.Verify("uid", signed); err != nil || string(v) != "42" {
+		t.Fatalf("fresh signed value: %q %v", v, err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, err := keys.Verify("uid", signed); !errors.Is(err, marten.ErrCookieExpired) {
+		t.Errorf("signed: expected ErrCookieExpired, got %v", err)
+	}
+	if _, err := keys.Decrypt("uid", encrypted); !errors.Is(err, marten.ErrCookieExpired) {
+		t.Errorf("encrypted: expected ErrCookieExpired, got %v", err)
+	}
+}
+
+func TestSecureCookieMaxAgeEmbedded(t *testing.T) {
+	app := cookieApp(t, cookieSecret)
+	ck := setCookie(t, app, "v=42&max_age=3600")
+	if ck.MaxAge != 3600 {
+		t.Errorf("cookie attributes should be kept, got MaxAge %d", ck.MaxAge)
+	}
+	if code, body := getCookie(app, "", ck); code != 200 || body != "42" {
+		t.Errorf("got %d %q", code, body)
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	oldApp := cookieApp(t, oldCookieSecret)
+	signed := setCookie(t, oldApp, "v=42")
+	encrypted := setCookie(t, oldApp, "v=42&encrypt=1")
+
+	// New secret first, old one kept for reading
+	app := cookieApp(t, cookieSecret, oldCookieSecret)
+	if code, body := getCookie(app, "", signed); code != 200 || body != "42" {
+		t.Errorf("signed with old key: %d %q", code, body)
+	}
+	if code, body := getCookie(app, "encrypt=1", encrypted); code != 200 || body != "42" {
+		t.Errorf("encrypted with old key: %d %q", code, body)
+	}
+
+	// New cookies use the new secret only
+	fresh := setCookie(t, app, "v=42")
+	if code, _ := getCookie(oldApp, "", fresh); code != 401 {
+		t.Errorf("old keyring should not verify new cookies, got %d", code)
+	}
+
+	// Once the old secret is dropped, its cookies are rejected
+	rotated := cookieApp(t, cookieSecret)
+	if code, body := getCookie(rotated, "", signed); code != 401 || body != "invalid" {
+		t.Errorf("retired key: %d %q", code, body)
+	}
+}
+
+func TestKeyringErrors(t *testing.T) {
+	if _, err := marten.NewKeyring([]byte("short")); !errors.Is(err, marten.ErrKeyTooShort) {
+		t.Errorf("expected ErrKeyTooShort, got %v", err)
+	}
+
+	app := marten.New()
+	app.GET("/", func(c *marten.Ctx) error {
+		if err := c.SetSignedCookie(&http.Cookie{Name: "a", Value: "b"}); !errors.Is(err, marten.ErrNoKeyring) {
+			t.Errorf("expected ErrNoKeyring, got %v", err)
+		}
+		if _, err := c.EncryptedCookie("a"); !errors.Is(err, marten.ErrNoKeyring) {
+			t.Errorf("expected ErrNoKeyring, got %v", err)
+		}
+		return nil
+	})
+	app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
+
+func TestSecureCookieDelete(t *testing.T) {
+	app := cookieApp(t, cookieSecret)
+	app.GET("/logout", func(c *marten.Ctx) error {
+		return c.SetSignedCookie(&http.Cookie{Name: "uid", MaxAge: -1})
+	})
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/logout", nil))
+	if got := rec.Header().Get("Set-Cookie"); !strings.Contains(got, "Max-Age=0") {
+		t.Errorf("expected a deletion cookie, got %q", got)
+	}
+}
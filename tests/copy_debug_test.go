@@ -0,0 +1,32 @@
+//go:build martendebug
+
+package tests
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gomarten/marten"
+)
+
+func TestCtxUseAfterReleasePanics(t *testing.T) {
+	app := marten.New()
+	var leaked *marten.Ctx
+	app.GET("/", func(c *marten.Ctx) error {
+		leaked = c
+		return nil
+	})
+	app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Fatal("expected panic on use after release")
+		}
+		if !strings.Contains(r.(string), "Copy()") {
+			t.Errorf("expected helpful panic message, got %v", r)
+		}
+	}()
+	_ = leaked.Param("id")
+}
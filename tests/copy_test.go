@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gomarten/marten"
+)
+
+func TestCtxCopyIsDetached(t *testing.T) {
+	app := marten.New()
+	copies := make(chan *marten.Ctx, 1)
+	app.GET("/users/:id", func(c *marten.Ctx) error {
+		c.Set("user", "alice")
+		if c.Param("id") == "42" {
+			copies <- c.Copy()
+		}
+		return c.Text(200, "ok")
+	})
+
+	req := httptest.NewRequest("GET", "/users/42?page=2", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	req.Header.Set("X-Tenant", "acme")
+	app.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Serve another request so the pooled Ctx is reused
+	other := httptest.NewRequest("GET", "/users/7", nil)
+	app.ServeHTTP(httptest.NewRecorder(), other)
+
+	cp := <-copies
+	if cp.Param("id") != "42" {
+		t.Errorf("expected param 42, got %q", cp.Param("id"))
+	}
+	if cp.GetString("user") != "alice" {
+		t.Errorf("expected stored user, got %q", cp.GetString("user"))
+	}
+	if cp.RequestID() != "req-1" {
+		t.Errorf("expected request ID req-1, got %q", cp.RequestID())
+	}
+	if cp.GetHeader("X-Tenant") != "acme" || cp.Query("page") != "2" {
+		t.Errorf("expected request data to be copied")
+	}
+	if cp.Context().Err() != nil {
+		t.Errorf("copied context should not be cancelled: %v", cp.Context().Err())
+	}
+	if !cp.IsCopy() {
+		t.Error("expected IsCopy to be true")
+	}
+}
+
+func TestCtxCopyRejectsWrites(t *testing.T) {
+	app := marten.New()
+	var cp *marten.Ctx
+	app.GET("/", func(c *marten.Ctx) error {
+		cp = c.Copy()
+		return c.Text(200, "ok")
+	})
+	app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	if err := cp.Text(200, "late"); !errors.Is(err, marten.ErrCtxCopy) {
+		t.Errorf("expected ErrCtxCopy, got %v", err)
+	}
+	if err := cp.OK(marten.M{"a": 1}); !errors.Is(err, marten.ErrCtxCopy) {
+		t.Errorf("expected ErrCtxCopy, got %v", err)
+	}
+}
@@ -0,0 +1,219 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gomarten/marten"
+	"github.com/gomarten/marten/martentest"
+	"github.com/gomarten/marten/middleware"
+)
+
+func csrfApp(cfg middleware.CSRFConfig, mw ...marten.Middleware) *marten.App {
+	app := marten.New()
+	keys, _ := marten.NewKeyring(cookieSecret)
+	app.SetKeyring(keys)
+	app.Use(mw...)
+	app.Use(middleware.CSRF(cfg))
+	app.GET("/form", func(c *marten.Ctx) error {
+		return c.Text(200, c.CSRFToken())
+	})
+	app.POST("/submit", func(c *marten.Ctx) error {
+		return c.Text(200, "ok")
+	})
+	app.POST("/webhooks/stripe", func(c *marten.Ctx) error {
+		return c.Text(200, "hook")
+	})
+	return app
+}
+
+func csrfToken(t *testing.T, tr *martentest.Tester) string {
+	t.Helper()
+	token := tr.GET("/form").Expect(t).Status(200).Recorder.Body.String()
+	if len(token) != 43 {
+		t.Fatalf("unexpected token %q", token)
+	}
+	return token
+}
+
+func TestCSRFStorages(t *testing.T) {
+	storages := map[string]struct {
+		cfg middleware.CSRFConfig
+		mw  []marten.Middleware
+	}{
+		"cookie":        {cfg: middleware.CSRFConfig{}},
+		"signed cookie": {cfg: middleware.CSRFConfig{Storage: middleware.CSRFSignedCookie}},
+		"session": {
+			cfg: middleware.CSRFConfig{Storage: middleware.CSRFSession},
+			mw:  []marten.Middleware{middleware.Session(middleware.SessionConfig{})},
+		},
+	}
+	for name, tc := range storages {
+		t.Run(name, func(t *testing.T) {
+			tr := martentest.New(csrfApp(tc.cfg, tc.mw...))
+			token := csrfToken(t, tr)
+			if again := csrfToken(t, tr); again != token {
+				t.Errorf("token should be stable, got %q then %q", token, again)
+			}
+
+			tr.POST("/submit").Header("X-CSRF-Token", token).Expect(t).Status(200)
+			tr.POST("/submit").Form(url.Values{"csrf_token": {token}}).Expect(t).Status(200)
+			tr.POST("/submit").Query("csrf_token", token).Expect(t).Status(200)
+
+			tr.POST("/submit").Expect(t).Status(403).BodyContains("missing CSRF token")
+			tr.POST("/submit").Header("X-CSRF-Token", flip(token, 10)).Expect(t).Status(403).BodyContains("invalid CSRF token")
+
+			// Another client's token is not accepted
+			martentest.New(csrfApp(tc.cfg, tc.mw...)).POST("/submit").Header("X-CSRF-Token", token).Expect(t).Status(403)
+		})
+	}
+}
+
+func TestCSRFCookieAttributes(t *testing.T) {
+	tr := martentest.New(csrfApp(middleware.CSRFConfig{}))
+	ck := tr.GET("/form").Expect(t).Result().Cookies()[0]
+	if ck.Name != "_csrf" || ck.HttpOnly || ck.SameSite != http.SameSiteLaxMode {
+		t.Errorf("double-submit cookie must be readable by scripts: %+v", ck)
+	}
+
+	tr = martentest.New(csrfApp(middleware.CSRFConfig{Storage: middleware.CSRFSignedCookie}))
+	ck = tr.GET("/form").Expect(t).Result().Cookies()[0]
+	if !ck.HttpOnly || !strings.Contains(ck.Value, ".") {
+		t.Errorf("signed cookie should be HttpOnly and signed: %+v", ck)
+	}
+}
+
+func TestCSRFSignedCookieForged(t *testing.T) {
+	// A planted unsigned cookie is ignored
+	app := csrfApp(middleware.CSRFConfig{Storage: middleware.CSRFSignedCookie})
+	martentest.New(app).POST("/submit").
+		Cookie(&http.Cookie{Name: "_csrf", Value: "forged"}).
+		Header("X-CSRF-Token", "forged").
+		Expect(t).Status(403)
+}
+
+func TestCSRFOrigin(t *testing.T) {
+	tr := martentest.New(csrfApp(middleware.CSRFConfig{TrustedOrigins: []string{"https://admin.example.com"}}))
+	token := csrfToken(t, tr)
+
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   int
+	}{
+		{"same origin", map[string]string{"Origin": "http://example.com"}, 200},
+		{"cross origin", map[string]string{"Origin": "https://evil.test"}, 403},
+		{"null origin", map[string]string{"Origin": "null"}, 403},
+		{"trusted origin", map[string]string{"Origin": "https://admin.example.com"}, 200},
+		{"same referer", map[string]string{"Referer": "http://example.com/form"}, 200},
+		{"cross referer", map[string]string{"Referer": "https://evil.test/page"}, 403},
+		{"fetch same-origin", map[string]string{"Sec-Fetch-Site": "same-origin"}, 200},
+		{"fetch cross-site", map[string]string{"Sec-Fetch-Site": "cross-site", "Origin": "https://evil.test"}, 403},
+		{"fetch same-site", map[string]string{"Sec-Fetch-Site": "same-site"}, 403},
+		{"fetch trusted", map[string]string{"Sec-Fetch-Site": "cross-site", "Origin": "https://admin.example.com"}, 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tr.POST("/submit").Header("X-CSRF-Token", token)
+			for k, v := range tt.header {
+				req.Header(k, v)
+			}
+			res := req.Expect(t).Status(tt.want)
+			if tt.want == 403 {
+				res.BodyContains("cross-origin")
+			}
+		})
+	}
+}
+
+func TestCSRFSafeMethodsAndExemptions(t *testing.T) {
+	app := csrfApp(middleware.CSRFConfig{
+		ExemptPaths: []string{"/webhooks/*"},
+		Skip:        func(c *marten.Ctx) bool { return c.GetHeader("X-Internal") == "1" },
+	})
+	app.HEAD("/form", func(c *marten.Ctx) error { return c.NoContent() })
+	app.OPTIONS("/submit", func(c *marten.Ctx) error { return c.NoContent() })
+	tr := martentest.New(app)
+
+	tr.HEAD("/form").Expect(t).Status(204)
+	tr.OPTIONS("/submit").Expect(t).Status(204)
+	tr.POST("/webhooks/stripe").Header("Origin", "https://stripe.test").Expect(t).Status(200).Body("hook")
+	tr.POST("/submit").Header("X-Internal", "1").Expect(t).Status(200)
+	tr.POST("/submit").Expect(t).Status(403)
+}
+
+func TestCSRFErrorHandler(t *testing.T) {
+	tr := martentest.New(csrfApp(middleware.CSRFConfig{
+		ErrorHandler: func(c *marten.Ctx, err error) error {
+			return c.JSON(419, marten.M{"error": err.Error()})
+		},
+	}))
+	tr.POST("/submit").Expect(t).Status(419).JSON(marten.M{"error": middleware.ErrCSRFMissing.Error()})
+}
+
+func TestCSRFTemplateToken(t *testing.T) {
+	r, err := marten.NewTemplateRenderer(marten.TemplateConfig{FS: fstest.MapFS{
+		"form.html": {Data: []byte(`<input name="csrf_token" value="{{csrfToken}}">`)},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := csrfApp(middleware.CSRFConfig{})
+	app.SetRenderer(r)
+	app.GET("/page", func(c *marten.Ctx) error {
+		return c.Render(200, "form", nil)
+	})
+	tr := martentest.New(app)
+	body := tr.GET("/page").Expect(t).Status(200).Recorder.Body.String()
+	token := strings.TrimSuffix(strings.TrimPrefix(body, `<input name="csrf_token" value="`), `">`)
+	tr.POST("/submit").Form(url.Values{"csrf_token": {token}}).Expect(t).Status(200)
+}
+
+func TestCSRFSessionRequiresMiddleware(t *testing.T) {
+	martentest.New(csrfApp(middleware.CSRFConfig{Storage: middleware.CSRFSession})).GET("/form").Expect(t).Status(500)
+}
+
+func TestCSRFMultipartForm(t *testing.T) {
+	app := csrfApp(middleware.CSRFConfig{})
+	app.SetMultipartConfig(marten.MultipartConfig{MaxTotalSize: 1 << 10})
+	app.POST("/upload", func(c *marten.Ctx) error {
+		form, err := c.MultipartForm()
+		if err != nil {
+			return err
+		}
+		return c.Text(200, form.File["f"][0].Filename)
+	})
+	var parts []string
+	app.POST("/stream", func(c *marten.Ctx) error {
+		err := c.EachPart(func(p *marten.Part) error {
+			parts = append(parts, p.FormName())
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return c.NoContent()
+	})
+	tr := martentest.New(app)
+	token := csrfToken(t, tr)
+
+	body, ct := multipartRequest(t, map[string]string{"csrf_token": token}, uploadFile{"f", "a.txt", []byte("hello")})
+	tr.POST("/upload").Body(ct, body).Expect(t).Status(200).Body("a.txt")
+
+	body, ct = multipartRequest(t, map[string]string{"csrf_token": flip(token, 10)})
+	tr.POST("/upload").Body(ct, body).Expect(t).Status(403)
+
+	// The app's limits apply while looking for the token
+	body, ct = multipartRequest(t, map[string]string{"csrf_token": token}, uploadFile{"f", "big.txt", []byte(strings.Repeat("x", 2<<10))})
+	tr.POST("/upload").Body(ct, body).Expect(t).Status(413)
+
+	// Streaming handlers get the untouched body when the token is in the header
+	body, ct = multipartRequest(t, nil, uploadFile{"f", "a.txt", []byte("hello")})
+	tr.POST("/stream").Header("X-CSRF-Token", token).Body(ct, body).Expect(t).Status(204)
+	if len(parts) != 1 || parts[0] != "f" {
+		t.Errorf("parts = %v", parts)
+	}
+}
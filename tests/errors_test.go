@@ -0,0 +1,162 @@
+package tests
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gomarten/marten"
+)
+
+type notFoundError struct {
+	Resource string
+}
+
+func (e *notFoundError) Error() string {
+	return e.Resource + " not found"
+}
+
+func TestHTTPErrorDefaultHandler(t *testing.T) {
+	app := marten.New()
+	app.GET("/users/:id", func(c *marten.Ctx) error {
+		return marten.NewHTTPError(404, "user not found").
+			WithInternal(errors.New("row 42 missing")).
+			WithHeader("X-Reason", "missing")
+	})
+
+	req := httptest.NewRequest("GET", "/users/42", nil)
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	if rec.Code != 404 {
+		t.Errorf("expected 404, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "user not found") {
+		t.Errorf("expected public message, got %q", rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "row 42") {
+		t.Errorf("internal cause leaked: %q", rec.Body.String())
+	}
+	if rec.Header().Get("X-Reason") != "missing" {
+		t.Errorf("expected X-Reason header, got %q", rec.Header().Get("X-Reason"))
+	}
+}
+
+func TestHTTPErrorDefaultMessage(t *testing.T) {
+	err := marten.NewHTTPError(409, "")
+	if err.Message != "Conflict" {
+		t.Errorf("expected status text, got %q", err.Message)
+	}
+}
+
+func TestHTTPErrorUnwrap(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", marten.ErrNotFound("gone").WithInternal(sql.ErrNoRows))
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Error("expected errors.Is to find internal cause")
+	}
+	var he *marten.HTTPError
+	if !errors.As(err, &he) || he.Code != 404 {
+		t.Errorf("expected errors.As to find HTTPError, got %v", he)
+	}
+}
+
+func TestMapErrorIs(t *testing.T) {
+	app := marten.New()
+	app.MapError(sql.ErrNoRows, 404)
+	app.GET("/item", func(c *marten.Ctx) error {
+		return fmt.Errorf("load item: %w", sql.ErrNoRows)
+	})
+
+	req := httptest.NewRequest("GET", "/item", nil)
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	if rec.Code != 404 {
+		t.Errorf("expected 404, got %d", rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "no rows") {
+		t.Errorf("internal error leaked: %q", rec.Body.String())
+	}
+}
+
+func TestMapErrorAs(t *testing.T) {
+	app := marten.New()
+	marten.MapErrorAs[*notFoundError](app, 404)
+	app.GET("/item", func(c *marten.Ctx) error {
+		return fmt.Errorf("lookup: %w", &notFoundError{Resource: "item"})
+	})
+
+	req := httptest.NewRequest("GET", "/item", nil)
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	if rec.Code != 404 {
+		t.Errorf("expected 404, got %d", rec.Code)
+	}
+}
+
+func TestBindErrorMapsTo400(t *testing.T) {
+	app := marten.New()
+	app.POST("/bind", func(c *marten.Ctx) error {
+		var v struct {
+			Name string `json:"name"`
+		}
+		return c.Bind(&v)
+	})
+
+	req := httptest.NewRequest("POST", "/bind", strings.NewReader("{bad"))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	if rec.Code != 400 {
+		t.Errorf("expected 400, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "invalid JSON") {
+		t.Errorf("expected bind message, got %q", rec.Body.String())
+	}
+}
+
+func TestUnmappedErrorStays500(t *testing.T) {
+	app := marten.New()
+	app.GET("/fail", func(c *marten.Ctx) error {
+		return errors.New("secret failure")
+	})
+
+	req := httptest.NewRequest("GET", "/fail", nil)
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	if rec.Code != 500 {
+		t.Errorf("expected 500, got %d", rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "secret") {
+		t.Errorf("error leaked: %q", rec.Body.String())
+	}
+}
+
+func TestResolveErrorInCustomHandler(t *testing.T) {
+	app := marten.New()
+	app.MapError(sql.ErrNoRows, 404)
+	app.OnError(func(c *marten.Ctx, err error) {
+		he := app.ResolveError(err)
+		_ = c.JSON(he.Code, marten.M{"code": he.Code, "msg": he.Message})
+	})
+	app.GET("/item", func(c *marten.Ctx) error {
+		return sql.ErrNoRows
+	})
+
+	req := httptest.NewRequest("GET", "/item", nil)
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	if rec.Code != 404 {
+		t.Errorf("expected 404, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "Not Found") {
+		t.Errorf("expected status text message, got %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,222 @@
+package tests
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gomarten/marten"
+	"github.com/gomarten/marten/middleware"
+)
+
+func newFileApp(t *testing.T) (*marten.App, string) {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.txt")
+	if err := os.WriteFile(path, []byte("0123456789abcdef"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	app := marten.New()
+	app.GET("/file", func(c *marten.Ctx) error { return c.SendFile(path) })
+	app.GET("/missing", func(c *marten.Ctx) error { return c.SendFile(filepath.Join(dir, "nope")) })
+	app.GET("/download", func(c *marten.Ctx) error { return c.Attachment(path, "Résumé \"final\".txt") })
+	app.GET("/inline", func(c *marten.Ctx) error { return c.Inline(path, "") })
+	return app, path
+}
+
+func TestSendFile(t *testing.T) {
+	app, _ := newFileApp(t)
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/file", nil))
+
+	if rec.Code != 200 || rec.Body.String() != "0123456789abcdef" {
+		t.Fatalf("unexpected response %d %q", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	if rec.Header().Get("ETag") == "" || rec.Header().Get("Last-Modified") == "" {
+		t.Errorf("expected validators, got %v", rec.Header())
+	}
+	if rec.Header().Get("Accept-Ranges") != "bytes" {
+		t.Errorf("expected Accept-Ranges: bytes")
+	}
+
+	rec = httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/missing", nil))
+	if rec.Code != 404 {
+		t.Errorf("expected 404 for missing file, got %d", rec.Code)
+	}
+}
+
+func TestSendFileRange(t *testing.T) {
+	app, _ := newFileApp(t)
+
+	req := httptest.NewRequest("GET", "/file", nil)
+	req.Header.Set("Range", "bytes=2-5")
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	if rec.Code != 206 || rec.Body.String() != "2345" {
+		t.Errorf("unexpected range response %d %q", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Content-Range") != "bytes 2-5/16" {
+		t.Errorf("unexpected Content-Range %q", rec.Header().Get("Content-Range"))
+	}
+
+	req = httptest.NewRequest("GET", "/file", nil)
+	req.Header.Set("Range", "bytes=0-1,-2")
+	rec = httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	if rec.Code != 206 || !strings.HasPrefix(rec.Header().Get("Content-Type"), "multipart/byteranges") {
+		t.Fatalf("expected multipart/byteranges, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "01") || !strings.Contains(body, "ef") {
+		t.Errorf("unexpected multipart body %q", body)
+	}
+
+	req = httptest.NewRequest("GET", "/file", nil)
+	req.Header.Set("Range", "bytes=100-")
+	rec = httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if rec.Code != 416 {
+		t.Errorf("expected 416, got %d", rec.Code)
+	}
+}
+
+func TestSendFileConditional(t *testing.T) {
+	app, _ := newFileApp(t)
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/file", nil))
+	etag := rec.Header().Get("ETag")
+	lastModified := rec.Header().Get("Last-Modified")
+
+	req := httptest.NewRequest("GET", "/file", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if rec.Code != 304 {
+		t.Errorf("If-None-Match: expected 304, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/file", nil)
+	req.Header.Set("If-Modified-Since", lastModified)
+	rec = httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if rec.Code != 304 {
+		t.Errorf("If-Modified-Since: expected 304, got %d", rec.Code)
+	}
+
+	// A stale If-Range validator returns the whole file
+	req = httptest.NewRequest("GET", "/file", nil)
+	req.Header.Set("Range", "bytes=0-1")
+	req.Header.Set("If-Range", `"stale"`)
+	rec = httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if rec.Code != 200 || rec.Body.Len() != 16 {
+		t.Errorf("stale If-Range: expected full response, got %d (%d bytes)", rec.Code, rec.Body.Len())
+	}
+
+	req = httptest.NewRequest("GET", "/file", nil)
+	req.Header.Set("Range", "bytes=0-1")
+	req.Header.Set("If-Range", etag)
+	rec = httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if rec.Code != 206 {
+		t.Errorf("matching If-Range: expected 206, got %d", rec.Code)
+	}
+}
+
+func TestAttachmentAndInline(t *testing.T) {
+	app, _ := newFileApp(t)
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/download", nil))
+	want := `attachment; filename="R_sum_ \"final\".txt"; filename*=UTF-8''R%C3%A9sum%C3%A9%20%22final%22.txt`
+	if got := rec.Header().Get("Content-Disposition"); got != want {
+		t.Errorf("unexpected disposition:\n got %s\nwant %s", got, want)
+	}
+
+	rec = httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/inline", nil))
+	if got := rec.Header().Get("Content-Disposition"); got != `inline; filename="report.txt"` {
+		t.Errorf("unexpected disposition %q", got)
+	}
+}
+
+func TestServeContent(t *testing.T) {
+	app := marten.New()
+	app.GET("/gen", func(c *marten.Ctx) error {
+		return c.ServeContent("", time.Time{}, bytes.NewReader([]byte("<html><body>hi</body></html>")))
+	})
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/gen", nil))
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("expected sniffed text/html, got %q", ct)
+	}
+}
+
+func TestStaticRange(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "video.bin"), []byte("abcdefgh"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	app := marten.New()
+	app.Use(middleware.Static(dir))
+
+	req := httptest.NewRequest("GET", "/video.bin", nil)
+	req.Header.Set("Range", "bytes=4-")
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	if rec.Code != 206 || rec.Body.String() != "efgh" {
+		t.Errorf("unexpected range response %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+// readFromRecorder records whether the response body went through ReadFrom,
+// as net/http's sendfile path does.
+type readFromRecorder struct {
+	*httptest.ResponseRecorder
+	readFrom bool
+}
+
+func (r *readFromRecorder) ReadFrom(src io.Reader) (int64, error) {
+	r.readFrom = true
+	return io.Copy(r.ResponseRecorder, src)
+}
+
+func TestSendFileUsesReadFrom(t *testing.T) {
+	app, _ := newFileApp(t)
+	var status int
+	app.Use(func(next marten.Handler) marten.Handler {
+		return func(c *marten.Ctx) error {
+			err := next(c)
+			status = c.StatusCode()
+			return err
+		}
+	})
+
+	rec := &readFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/file", nil))
+	if !rec.readFrom || rec.Body.String() != "0123456789abcdef" {
+		t.Errorf("expected the body through ReadFrom, got %v %q", rec.readFrom, rec.Body.String())
+	}
+
+	req := httptest.NewRequest("GET", "/file", nil)
+	req.Header.Set("Range", "bytes=2-5")
+	app.ServeHTTP(httptest.NewRecorder(), req)
+	if status != 206 {
+		t.Errorf("expected StatusCode 206, got %d", status)
+	}
+}
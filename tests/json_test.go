@@ -0,0 +1,210 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gomarten/marten"
+)
+
+func TestJSONDefaultEncoding(t *testing.T) {
+	app := marten.New()
+	app.GET("/", func(c *marten.Ctx) error {
+		return c.OK(marten.M{"html": "<b>"})
+	})
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	if got := rec.Body.String(); got != "{\"html\":\"\\u003cb\\u003e\"}\n" {
+		t.Errorf("unexpected body %q", got)
+	}
+}
+
+func TestJSONConfigEncoding(t *testing.T) {
+	app := marten.New()
+	app.SetJSONConfig(marten.JSONConfig{
+		DisableHTMLEscape: true,
+		OmitNewline:       true,
+		PrettyQuery:       "pretty",
+	})
+	app.GET("/", func(c *marten.Ctx) error {
+		return c.OK(marten.M{"html": "<b>"})
+	})
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/", `{"html":"<b>"}`},
+		{"/?pretty", "{\n  \"html\": \"<b>\"\n}"},
+		{"/?pretty=false", `{"html":"<b>"}`},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+		if got := rec.Body.String(); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.path, tt.want, got)
+		}
+	}
+}
+
+func TestJSONConfigIndent(t *testing.T) {
+	app := marten.New()
+	app.SetJSONConfig(marten.JSONConfig{Indent: "\t"})
+	app.GET("/", func(c *marten.Ctx) error {
+		return c.OK(marten.M{"a": 1})
+	})
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	if got := rec.Body.String(); got != "{\n\t\"a\": 1\n}\n" {
+		t.Errorf("unexpected body %q", got)
+	}
+}
+
+func TestJSONEncodeErrorLeavesResponseUntouched(t *testing.T) {
+	app := marten.New()
+	app.GET("/", func(c *marten.Ctx) error {
+		return c.OK(marten.M{"fn": func() {}})
+	})
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	if rec.Code != 500 {
+		t.Errorf("expected 500 from error handler, got %d", rec.Code)
+	}
+}
+
+func TestJSONDisallowUnknownFields(t *testing.T) {
+	app := marten.New()
+	app.SetJSONConfig(marten.JSONConfig{DisallowUnknownFields: true})
+	app.POST("/", func(c *marten.Ctx) error {
+		var v struct {
+			Name string `json:"name"`
+		}
+		if err := c.Bind(&v); err != nil {
+			return err
+		}
+		return c.OK(v)
+	})
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"a","admin":true}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	if rec.Code != 400 || !strings.Contains(rec.Body.String(), "unknown field") {
+		t.Errorf("expected 400 unknown field, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestJSONUseNumber(t *testing.T) {
+	app := marten.New()
+	app.SetJSONConfig(marten.JSONConfig{UseNumber: true})
+	var got any
+	app.POST("/", func(c *marten.Ctx) error {
+		var v map[string]any
+		if err := c.Bind(&v); err != nil {
+			return err
+		}
+		got = v["id"]
+		return c.NoContent()
+	})
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"id":9007199254740993}`))
+	req.Header.Set("Content-Type", "application/json")
+	app.ServeHTTP(httptest.NewRecorder(), req)
+
+	if n, ok := got.(json.Number); !ok || n.String() != "9007199254740993" {
+		t.Errorf("expected json.Number, got %T %v", got, got)
+	}
+}
+
+func TestJSONBindErrorPosition(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		line   int
+		column int
+	}{
+		{"syntax", "{\n  \"name\": \"a\",\n  \"age\": ,\n}", 3, 10},
+		{"type", "{\"name\": 1}", 1, 10},
+		{"truncated", "{\"name\": \"a\"", 1, 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := marten.New()
+			var bindErr error
+			app.POST("/", func(c *marten.Ctx) error {
+				var v struct {
+					Name string `json:"name"`
+					Age  int    `json:"age"`
+				}
+				bindErr = c.Bind(&v)
+				return bindErr
+			})
+
+			req := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			app.ServeHTTP(httptest.NewRecorder(), req)
+
+			var be *marten.BindError
+			if !errors.As(bindErr, &be) {
+				t.Fatalf("expected BindError, got %v", bindErr)
+			}
+			if be.Line != tt.line || be.Column != tt.column {
+				t.Errorf("expected %d:%d, got %d:%d (%s)", tt.line, tt.column, be.Line, be.Column, be.Message)
+			}
+		})
+	}
+}
+
+// upperCodec wraps encoding/json and upper-cases the output.
+type upperCodec struct{}
+
+func (upperCodec) NewEncoder(w io.Writer) marten.JSONEncoder {
+	return json.NewEncoder(upperWriter{w})
+}
+
+func (upperCodec) NewDecoder(r io.Reader) marten.JSONDecoder {
+	return json.NewDecoder(r)
+}
+
+type upperWriter struct{ w io.Writer }
+
+func (u upperWriter) Write(p []byte) (int, error) {
+	return u.w.Write([]byte(strings.ToUpper(string(p))))
+}
+
+func TestJSONCustomCodec(t *testing.T) {
+	app := marten.New()
+	app.SetJSONCodec(upperCodec{})
+	app.SetProblemDetails(true)
+	app.GET("/ok", func(c *marten.Ctx) error {
+		return c.OK(marten.M{"a": "b"})
+	})
+	app.GET("/negotiate", func(c *marten.Ctx) error {
+		return c.Negotiate(200, marten.M{"a": "b"})
+	})
+
+	for _, path := range []string{"/ok", "/negotiate"} {
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if got := rec.Body.String(); got != "{\"A\":\"B\"}\n" {
+			t.Errorf("%s: expected codec output, got %q", path, got)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/missing", nil))
+	if !strings.Contains(rec.Body.String(), `"TITLE":"NOT FOUND"`) {
+		t.Errorf("expected problem through codec, got %q", rec.Body.String())
+	}
+}
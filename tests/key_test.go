@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gomarten/marten"
+	"github.com/gomarten/marten/martentest"
+	"github.com/gomarten/marten/middleware"
+)
+
+type keyUser struct {
+	Name string
+}
+
+var (
+	userKey  = marten.NewKey[*keyUser]("user")
+	countKey = marten.NewKey[int]("count")
+	// otherUserKey shares userKey's name, as keys from two packages might
+	otherUserKey = marten.NewKey[*keyUser]("user")
+)
+
+// lookupUser stands in for a library that only sees a context.Context.
+func lookupUser(ctx context.Context) string {
+	if u, ok := ctx.Value(userKey).(*keyUser); ok {
+		return u.Name
+	}
+	return ""
+}
+
+func TestKeySetGet(t *testing.T) {
+	app := marten.New()
+	app.Use(func(next marten.Handler) marten.Handler {
+		return func(c *marten.Ctx) error {
+			req := c.Request
+			userKey.Set(c, &keyUser{Name: "alice"})
+			if c.Request != req {
+				t.Error("Set should not replace the request")
+			}
+			return next(c)
+		}
+	})
+	app.GET("/", func(c *marten.Ctx) error {
+		u, ok := userKey.Get(c)
+		if !ok || u.Name != "alice" {
+			t.Errorf("Get: %v %v", u, ok)
+		}
+		if _, ok := countKey.Get(c); ok {
+			t.Error("unset key should not be found")
+		}
+		if v, ok := userKey.Value(c.Context()); !ok || v != u {
+			t.Errorf("Value: %v %v", v, ok)
+		}
+		// Keys are compared by identity, not by name
+		if v, ok := otherUserKey.Get(c); ok {
+			t.Errorf("same-name key found %v", v)
+		}
+		if c.Get("user") != nil {
+			t.Errorf("c.Get: %v", c.Get("user"))
+		}
+		return c.Text(200, lookupUser(c.Context()))
+	})
+	martentest.New(app).GET("/").Expect(t).Status(200).Body("alice")
+}
+
+func TestKeyTypeMismatch(t *testing.T) {
+	app := marten.New()
+	app.GET("/", func(c *marten.Ctx) error {
+		c.Set("count", "not an int")
+		if n, ok := countKey.Get(c); ok || n != 0 {
+			t.Errorf("expected a miss, got %v %v", n, ok)
+		}
+		countKey.Set(c, 3)
+		if n, ok := countKey.Get(c); !ok || n != 3 {
+			t.Errorf("got %v %v", n, ok)
+		}
+		return nil
+	})
+	martentest.New(app).GET("/").Expect(t)
+}
+
+func TestKeyContextFollowsRequest(t *testing.T) {
+	type ctxKey struct{}
+	app := marten.New()
+	app.GET("/", func(c *marten.Ctx) error {
+		userKey.Set(c, &keyUser{Name: "alice"})
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), ctxKey{}, "v"))
+		ctx := c.Context()
+		if lookupUser(ctx) != "alice" || ctx.Value(ctxKey{}) != "v" {
+			t.Errorf("context lost values: %v", ctx)
+		}
+		countKey.Set(c, 2)
+		if n, _ := countKey.Value(c.Context()); n != 2 {
+			t.Errorf("count = %d", n)
+		}
+
+		cp := c.Copy()
+		countKey.Set(c, 3)
+		if n, _ := countKey.Get(cp); n != 2 || lookupUser(cp.Context()) != "alice" {
+			t.Errorf("copy: count %d, user %q", n, lookupUser(cp.Context()))
+		}
+		return nil
+	})
+	martentest.New(app).GET("/").Expect(t).Status(200)
+}
+
+func TestKeyFromNetHTTPMiddleware(t *testing.T) {
+	app := marten.New()
+	app.GET("/", func(c *marten.Ctx) error {
+		u, ok := userKey.Get(c)
+		if !ok {
+			return c.Text(401, "")
+		}
+		return c.Text(200, u.Name)
+	})
+	// Plain net/http middleware sets the value on the context
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.ServeHTTP(w, r.WithContext(userKey.WithValue(r.Context(), &keyUser{Name: "bob"})))
+	})
+
+	martentest.New(h).GET("/").Expect(t).Status(200).Body("bob")
+	martentest.New(app).GET("/").Expect(t).Status(401)
+}
+
+func TestKeyString(t *testing.T) {
+	if got := userKey.String(); got != `marten.Key[*tests.keyUser]("user")` {
+		t.Errorf("got %s", got)
+	}
+	if got := marten.SessionKey.String(); got != `marten.Key[marten.Session]("session")` {
+		t.Errorf("got %s", got)
+	}
+	if userKey.Name() != "user" {
+		t.Errorf("got %s", userKey.Name())
+	}
+}
+
+func TestBuiltinKeys(t *testing.T) {
+	app := marten.New()
+	app.Use(middleware.RequestID)
+	app.Use(middleware.Session(middleware.SessionConfig{}))
+	app.Use(middleware.CSRF(middleware.CSRFConfig{}))
+	app.Use(middleware.BasicAuthSimple("admin", "secret"))
+	app.GET("/", func(c *marten.Ctx) error {
+		ctx := c.Context()
+		user, _ := middleware.UserKey.Value(ctx)
+		id, _ := marten.RequestIDKey.Value(ctx)
+		token, _ := marten.CSRFKey.Value(ctx)
+		sess, _ := marten.SessionKey.Value(ctx)
+		if user != "admin" || id != c.RequestID() || token != c.CSRFToken() || sess != c.Session() || sess == nil {
+			t.Errorf("got user %q, id %q, token %q, session %v", user, id, token, sess)
+		}
+		// BasicAuth keeps setting the "user" store key
+		return c.Text(200, c.GetString("user"))
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("admin:secret")))
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if rec.Code != 200 || rec.Body.String() != "admin" {
+		t.Errorf("got %d %q", rec.Code, rec.Body.String())
+	}
+}
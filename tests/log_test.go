@@ -0,0 +1,350 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gomarten/marten"
+	"github.com/gomarten/marten/martentest"
+	"github.com/gomarten/marten/middleware"
+)
+
+// logRecords decodes the JSON lines written by a slog.JSONHandler.
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var recs []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var m map[string]any
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("bad log line %q: %v", line, err)
+		}
+		recs = append(recs, m)
+	}
+	return recs
+}
+
+func TestRoutePattern(t *testing.T) {
+	app := marten.New()
+	handler := func(c *marten.Ctx) error {
+		return c.Text(200, c.RoutePattern())
+	}
+	app.GET("/users/:id", handler)
+	app.GET("/static/*path", handler)
+	app.GET("/", handler)
+	api := app.Group("/api")
+	api.GET("/items/:id/", handler)
+	app.NotFound(handler)
+
+	client := martentest.New(app)
+	client.GET("/users/42").Expect(t).Body("/users/:id")
+	client.GET("/static/css/app.css").Expect(t).Body("/static/*path")
+	client.GET("/").Expect(t).Body("/")
+	client.GET("/api/items/7").Expect(t).Body("/api/items/:id")
+	client.GET("/missing").Expect(t).Body("")
+}
+
+func TestCtxLogger(t *testing.T) {
+	var buf bytes.Buffer
+	app := marten.New()
+	app.SetLogger(slog.New(slog.NewJSONHandler(&buf, nil)))
+	app.TrustedProxies([]string{"192.0.2.0/24"})
+	app.Use(middleware.RequestID)
+	app.POST("/users/:id", func(c *marten.Ctx) error {
+		c.Logger().Info("updated", "user_id", c.Param("id"))
+		return c.NoContent()
+	})
+
+	req := httptest.NewRequest("POST", "/users/42", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	req.Header.Set("X-Forwarded-For", "203.0.113.9")
+	app.ServeHTTP(httptest.NewRecorder(), req)
+
+	recs := logRecords(t, &buf)
+	if len(recs) != 1 {
+		t.Fatalf("got %d records", len(recs))
+	}
+	want := map[string]any{
+		"msg": "updated", "user_id": "42", "request_id": "req-1",
+		"route": "/users/:id", "method": "POST", "client_ip": "203.0.113.9",
+	}
+	for k, v := range want {
+		if recs[0][k] != v {
+			t.Errorf("%s = %v, want %v", k, recs[0][k], v)
+		}
+	}
+}
+
+func TestCtxLoggerFollowsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	app := marten.New()
+	app.SetLogger(slog.New(slog.NewJSONHandler(&buf, nil)))
+	app.GET("/", func(c *marten.Ctx) error {
+		c.Logger().Info("before")
+		c.SetRequestID("changed")
+		c.Logger().Info("after")
+		return nil
+	})
+	martentest.New(app).GET("/").Expect(t)
+
+	recs := logRecords(t, &buf)
+	if len(recs) != 2 || recs[0]["request_id"] == "changed" || recs[1]["request_id"] != "changed" {
+		t.Errorf("got %v", recs)
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	app := marten.New()
+	app.Use(middleware.AccessLog(middleware.AccessLogConfig{
+		Handler: slog.NewJSONHandler(&buf, nil),
+		Fields:  middleware.AllLogFields,
+	}))
+	app.POST("/items/:id", func(c *marten.Ctx) error {
+		var body map[string]any
+		if err := c.Bind(&body); err != nil {
+			return err
+		}
+		return c.Text(201, "created")
+	})
+
+	req := httptest.NewRequest("POST", "/items/9?dry=1", strings.NewReader(`{"name":"pen"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "test-agent")
+	req.Header.Set("Referer", "https://example.com/")
+	app.ServeHTTP(httptest.NewRecorder(), req)
+
+	recs := logRecords(t, &buf)
+	if len(recs) != 1 {
+		t.Fatalf("got %d records", len(recs))
+	}
+	r := recs[0]
+	want := map[string]any{
+		"msg": "request", "level": "INFO", "method": "POST", "path": "/items/9",
+		"route": "/items/:id", "status": float64(201), "query": "dry=1", "proto": "HTTP/1.1",
+		"bytes_in": float64(14), "bytes_out": float64(7), "user_agent": "test-agent",
+		"referer": "https://example.com/", "client_ip": "192.0.2.1",
+	}
+	for k, v := range want {
+		if r[k] != v {
+			t.Errorf("%s = %v, want %v", k, r[k], v)
+		}
+	}
+	if r["request_id"] == "" || r["duration"] == nil {
+		t.Errorf("missing request_id or duration: %v", r)
+	}
+	if _, ok := r["error"]; ok {
+		t.Errorf("unexpected error field: %v", r["error"])
+	}
+}
+
+func TestAccessLogDefaultFields(t *testing.T) {
+	var buf bytes.Buffer
+	app := marten.New()
+	app.Use(middleware.AccessLog(middleware.AccessLogConfig{Handler: slog.NewJSONHandler(&buf, nil)}))
+	app.GET("/", func(c *marten.Ctx) error { return c.Text(200, "ok") })
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("User-Agent", "test-agent")
+	app.ServeHTTP(httptest.NewRecorder(), req)
+
+	r := logRecords(t, &buf)[0]
+	if r["route"] != "/" || r["bytes_out"] != float64(2) {
+		t.Errorf("got %v", r)
+	}
+	for _, k := range []string{"user_agent", "referer", "bytes_in", "query", "proto"} {
+		if _, ok := r[k]; ok {
+			t.Errorf("%s should not be logged by default", k)
+		}
+	}
+}
+
+func TestAccessLogLevelsAndErrors(t *testing.T) {
+	var buf bytes.Buffer
+	app := marten.New()
+	app.MapError(errNotFoundLog, 404)
+	app.Use(middleware.AccessLog(middleware.AccessLogConfig{Handler: slog.NewJSONHandler(&buf, nil)}))
+	app.GET("/ok", func(c *marten.Ctx) error { return c.NoContent() })
+	app.GET("/missing", func(c *marten.Ctx) error { return errNotFoundLog })
+	app.GET("/boom", func(c *marten.Ctx) error { return errors.New("db down") })
+	app.GET("/teapot", func(c *marten.Ctx) error { return c.Text(418, "short and stout") })
+
+	client := martentest.New(app)
+	client.GET("/ok").Expect(t).Status(204)
+	client.GET("/missing").Expect(t).Status(404)
+	client.GET("/boom").Expect(t).Status(500)
+	client.GET("/teapot").Expect(t).Status(418)
+
+	recs := logRecords(t, &buf)
+	want := []struct {
+		status float64
+		level  string
+		err    any
+	}{
+		{204, "INFO", nil},
+		{404, "WARN", "no such thing"},
+		{500, "ERROR", "db down"},
+		{418, "WARN", nil},
+	}
+	if len(recs) != len(want) {
+		t.Fatalf("got %d records", len(recs))
+	}
+	for i, w := range want {
+		if recs[i]["status"] != w.status || recs[i]["level"] != w.level || recs[i]["error"] != w.err {
+			t.Errorf("record %d: %v", i, recs[i])
+		}
+	}
+}
+
+var errNotFoundLog = errors.New("no such thing")
+
+func TestAccessLogCustomLevel(t *testing.T) {
+	var buf bytes.Buffer
+	app := marten.New()
+	app.Use(middleware.AccessLog(middleware.AccessLogConfig{
+		Handler: slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}),
+		Level: func(status int) slog.Level {
+			if status < 400 {
+				return slog.LevelDebug
+			}
+			return slog.LevelError
+		},
+	}))
+	app.GET("/ok", func(c *marten.Ctx) error { return c.NoContent() })
+	app.GET("/bad", func(c *marten.Ctx) error { return c.BadRequest("no") })
+
+	client := martentest.New(app)
+	client.GET("/ok").Expect(t)
+	client.GET("/bad").Expect(t)
+
+	recs := logRecords(t, &buf)
+	if len(recs) != 1 || recs[0]["path"] != "/bad" || recs[0]["level"] != "ERROR" {
+		t.Errorf("got %v", recs)
+	}
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	tests := []struct {
+		name     string
+		rate     float64
+		min, max int
+	}{
+		{"fraction", 0.2, 50, 160},
+		{"default", 0, 500, 500},
+		{"none", -1, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			app := marten.New()
+			app.Use(middleware.AccessLog(middleware.AccessLogConfig{
+				Handler:    slog.NewJSONHandler(&buf, nil),
+				SampleRate: tt.rate,
+			}))
+			app.GET("/ok", func(c *marten.Ctx) error { return c.NoContent() })
+			app.GET("/err", func(c *marten.Ctx) error { return c.Error(503, "busy") })
+
+			client := martentest.New(app)
+			for i := 0; i < 500; i++ {
+				client.GET("/ok").Expect(t)
+			}
+			for i := 0; i < 20; i++ {
+				client.GET("/err").Expect(t)
+			}
+
+			ok, errs := 0, 0
+			for _, r := range logRecords(t, &buf) {
+				switch r["path"] {
+				case "/ok":
+					ok++
+				case "/err":
+					errs++
+				}
+			}
+			if ok < tt.min || ok > tt.max {
+				t.Errorf("logged %d of 500 sampled 2xx responses", ok)
+			}
+			if errs != 20 {
+				t.Errorf("logged %d of 20 errors", errs)
+			}
+		})
+	}
+}
+
+func TestAccessLogAppLogger(t *testing.T) {
+	var buf bytes.Buffer
+	app := marten.New()
+	app.SetLogger(slog.New(slog.NewJSONHandler(&buf, nil)).With("service", "api"))
+	app.Use(middleware.AccessLog(middleware.AccessLogConfig{
+		Skip: func(c *marten.Ctx) bool { return c.Path() == "/health" },
+	}))
+	app.GET("/health", func(c *marten.Ctx) error { return c.NoContent() })
+	app.GET("/users", func(c *marten.Ctx) error { return c.NoContent() })
+
+	client := martentest.New(app)
+	client.GET("/health").Expect(t)
+	client.GET("/users").Expect(t)
+
+	recs := logRecords(t, &buf)
+	if len(recs) != 1 || recs[0]["path"] != "/users" || recs[0]["service"] != "api" {
+		t.Errorf("got %v", recs)
+	}
+}
+
+func TestAccessLogCombinedFormat(t *testing.T) {
+	var buf bytes.Buffer
+	app := marten.New()
+	app.Use(middleware.AccessLog(middleware.CombinedLogFormat(&buf)))
+	app.Use(middleware.BasicAuthSimple("alice", "secret"))
+	app.GET("/files/:name", func(c *marten.Ctx) error { return c.Text(200, "hello") })
+
+	req := httptest.NewRequest("GET", "/files/a.txt?v=2", nil)
+	req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("alice:secret")))
+	req.Header.Set("Referer", "https://example.com/")
+	req.Header.Set("User-Agent", `evil "agent"`+"\n")
+	app.ServeHTTP(httptest.NewRecorder(), req)
+
+	re := regexp.MustCompile(`^192\.0\.2\.1 - alice \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /files/a\.txt\?v=2 HTTP/1\.1" 200 5 "https://example\.com/" "evil \\x22agent\\x22\\x0a"\n$`)
+	if !re.MatchString(buf.String()) {
+		t.Errorf("got %q", buf.String())
+	}
+}
+
+func TestAccessLogCommonFormat(t *testing.T) {
+	var buf bytes.Buffer
+	app := marten.New()
+	app.Use(middleware.AccessLog(middleware.CommonLogFormat(&buf)))
+	app.DELETE("/x", func(c *marten.Ctx) error { return c.NoContent() })
+
+	req := httptest.NewRequest("DELETE", "/x", nil)
+	req.Header.Set("User-Agent", "test-agent")
+	app.ServeHTTP(httptest.NewRecorder(), req)
+
+	re := regexp.MustCompile(`^192\.0\.2\.1 - - \[[^\]]+\] "DELETE /x HTTP/1\.1" 204 -\n$`)
+	if !re.MatchString(buf.String()) {
+		t.Errorf("got %q", buf.String())
+	}
+}
+
+func TestAccessLogRequestIDHeader(t *testing.T) {
+	var buf bytes.Buffer
+	app := marten.New()
+	app.Use(middleware.RequestID)
+	app.Use(middleware.AccessLog(middleware.AccessLogConfig{Handler: slog.NewJSONHandler(&buf, nil)}))
+	app.GET("/", func(c *marten.Ctx) error { return c.NoContent() })
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if id := rec.Header().Get("X-Request-ID"); id == "" || logRecords(t, &buf)[0]["request_id"] != id {
+		t.Errorf("log and header IDs differ: %s", buf.String())
+	}
+}
@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gomarten/marten"
+	"github.com/gomarten/marten/martentest"
+)
+
+func newUserApp() *marten.App {
+	app := marten.New()
+	app.GET("/users/:id", func(c *marten.Ctx) error {
+		return c.OK(marten.M{
+			"id":     c.Param("id"),
+			"name":   "bob",
+			"tenant": c.GetHeader("X-Tenant"),
+			"roles":  []string{"admin", "dev"},
+		})
+	})
+	app.POST("/users", func(c *marten.Ctx) error {
+		var u struct {
+			Name string `json:"name"`
+		}
+		if err := c.Bind(&u); err != nil {
+			return err
+		}
+		return c.Created(marten.M{"name": u.Name})
+	})
+	app.POST("/login", func(c *marten.Ctx) error {
+		c.SetCookie(&http.Cookie{Name: "sid", Value: "abc", Path: "/"})
+		return c.NoContent()
+	})
+	app.GET("/me", func(c *marten.Ctx) error {
+		if c.Cookie("sid") != "abc" {
+			return c.Unauthorized("not logged in")
+		}
+		return c.Text(200, "hello")
+	})
+	return app
+}
+
+func TestMartentestFluentRequest(t *testing.T) {
+	martentest.New(newUserApp()).
+		GET("/users/1").
+		Header("X-Tenant", "acme").
+		Expect(t).
+		Status(200).
+		HeaderContains("Content-Type", "application/json").
+		JSONPath("$.name", "bob").
+		JSONPath("$.tenant", "acme").
+		JSONPath("$.roles[1]", "dev").
+		JSONPath("$['id']", "1")
+}
+
+func TestMartentestJSONBody(t *testing.T) {
+	martentest.New(newUserApp()).
+		POST("/users").
+		JSON(marten.M{"name": "alice"}).
+		Expect(t).
+		Status(201).
+		JSON(marten.M{"name": "alice"})
+}
+
+func TestMartentestCookieJar(t *testing.T) {
+	tester := martentest.New(newUserApp())
+	tester.GET("/me").Expect(t).Status(401)
+	tester.POST("/login").Expect(t).Status(204).Cookie("sid", "abc")
+	tester.GET("/me").Expect(t).Status(200).Body("hello")
+}
+
+func TestMartentestClient(t *testing.T) {
+	client := martentest.New(newUserApp()).Client()
+
+	resp, err := client.Post("http://example.com/login", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	resp, err = client.Get("http://example.com/me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 || string(body) != "hello" {
+		t.Errorf("expected logged-in response, got %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestMartentestNewCtx(t *testing.T) {
+	handler := func(c *marten.Ctx) error {
+		return c.OK(marten.M{"id": c.Param("id")})
+	}
+	c, rec := martentest.NewCtx(httptest.NewRequest("GET", "/users/9", nil), "id", "9")
+	if err := handler(c); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != 200 || rec.Body.String() != "{\"id\":\"9\"}\n" {
+		t.Errorf("unexpected response %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestMartentestNewCtxMiddleware(t *testing.T) {
+	auth := func(next marten.Handler) marten.Handler {
+		return func(c *marten.Ctx) error {
+			if c.Bearer() == "" {
+				return errors.New("missing token")
+			}
+			return next(c)
+		}
+	}
+	c, _ := martentest.NewCtx(httptest.NewRequest("GET", "/", nil))
+	if err := auth(func(*marten.Ctx) error { return nil })(c); err == nil {
+		t.Error("expected middleware to reject request without token")
+	}
+}
+
+func TestMartentestNewCtxFor(t *testing.T) {
+	app := marten.New()
+	app.SetJSONConfig(marten.JSONConfig{OmitNewline: true})
+	app.SetProblemDetails(true)
+	app.TrustedProxies([]string{"192.0.2.0/24"})
+
+	req := httptest.NewRequest("GET", "/users/9", nil)
+	req.Header.Set("X-Forwarded-For", "203.0.113.9")
+	c, rec := martentest.NewCtxFor(app, req, "id", "9")
+	if ip := c.ClientIP(); ip != "203.0.113.9" {
+		t.Errorf("expected the forwarded client IP, got %q", ip)
+	}
+	if err := c.OK(marten.M{"id": c.Param("id")}); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Body.String() != `{"id":"9"}` {
+		t.Errorf("expected the app's JSON config, got %q", rec.Body.String())
+	}
+
+	c, rec = martentest.NewCtxFor(app, httptest.NewRequest("GET", "/", nil))
+	c.BadRequest("bad")
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, marten.MIMEProblemJSON) {
+		t.Errorf("expected problem details, got %q", ct)
+	}
+}
+
+func TestMartentestGolden(t *testing.T) {
+	martentest.New(newUserApp()).
+		GET("/users/1").
+		Expect(t).
+		Status(200).
+		Golden("testdata/user.golden")
+}
@@ -465,6 +465,7 @@ func TestRateLimitMiddleware(t *testing.T) {
 
 func TestRateLimitByIP(t *testing.T) {
 	app := marten.New()
+	app.TrustedProxies([]string{"192.0.2.1"})
 	app.Use(middleware.RateLimit(middleware.RateLimitConfig{
 		Requests: 2,
 		Window:   time.Minute,
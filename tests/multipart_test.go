@@ -0,0 +1,290 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gomarten/marten"
+	"github.com/gomarten/marten/martentest"
+	"github.com/gomarten/marten/middleware"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+type uploadFile struct {
+	field, name string
+	data        []byte
+}
+
+func multipartRequest(t *testing.T, fields map[string]string, files ...uploadFile) (*bytes.Buffer, string) {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for k, v := range fields {
+		w.WriteField(k, v)
+	}
+	for _, f := range files {
+		fw, err := w.CreateFormFile(f.field, f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(f.data)
+	}
+	w.Close()
+	return &body, w.FormDataContentType()
+}
+
+func TestEachPartStreamsToDisk(t *testing.T) {
+	dir := t.TempDir()
+	app := marten.New()
+	var fields []string
+	var types []string
+	app.POST("/upload", func(c *marten.Ctx) error {
+		err := c.EachPart(func(p *marten.Part) error {
+			if !p.IsFile() {
+				v, err := p.Value()
+				fields = append(fields, p.FormName()+"="+v)
+				return err
+			}
+			types = append(types, p.ContentType)
+			_, err := p.SaveTo(dir + "/")
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		return c.NoContent()
+	})
+
+	body, ct := multipartRequest(t, map[string]string{"title": "holiday"},
+		uploadFile{"photo", "../../etc/beach.png", append(pngHeader, "rest"...)})
+	req := httptest.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", ct)
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	if rec.Code != 204 {
+		t.Fatalf("expected 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(fields) != 1 || fields[0] != "title=holiday" {
+		t.Errorf("unexpected fields %v", fields)
+	}
+	if len(types) != 1 || types[0] != "image/png" {
+		t.Errorf("expected sniffed image/png, got %v", types)
+	}
+	saved, err := os.ReadFile(filepath.Join(dir, "beach.png"))
+	if err != nil || !bytes.Equal(saved, append(pngHeader, "rest"...)) {
+		t.Errorf("file not saved safely: %v", err)
+	}
+}
+
+func TestEachPartLimits(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   marten.MultipartConfig
+		files []uploadFile
+		code  int
+		err   error
+	}{
+		{
+			name:  "file too large",
+			cfg:   marten.MultipartConfig{MaxFileSize: 10},
+			files: []uploadFile{{"f", "a.txt", []byte(strings.Repeat("x", 11))}},
+			code:  413,
+			err:   marten.ErrFileTooLarge,
+		},
+		{
+			name:  "too many files",
+			cfg:   marten.MultipartConfig{MaxFiles: 1},
+			files: []uploadFile{{"f", "a.txt", []byte("a")}, {"f", "b.txt", []byte("b")}},
+			code:  413,
+			err:   marten.ErrTooManyFiles,
+		},
+		{
+			name:  "total too large",
+			cfg:   marten.MultipartConfig{MaxTotalSize: 100},
+			files: []uploadFile{{"f", "a.txt", []byte(strings.Repeat("x", 200))}},
+			code:  413,
+			err:   marten.ErrUploadTooLarge,
+		},
+		{
+			name:  "type not allowed",
+			cfg:   marten.MultipartConfig{AllowedTypes: []string{"image/*"}},
+			files: []uploadFile{{"f", "fake.png", []byte("MZ not an image")}},
+			code:  415,
+			err:   marten.ErrFileType,
+		},
+		{
+			name:  "type allowed",
+			cfg:   marten.MultipartConfig{AllowedTypes: []string{"image/*"}, MaxFileSize: 100},
+			files: []uploadFile{{"f", "a.png", pngHeader}},
+			code:  204,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := marten.New()
+			app.SetMultipartConfig(tt.cfg)
+			var got error
+			app.POST("/upload", func(c *marten.Ctx) error {
+				got = c.EachPart(func(p *marten.Part) error {
+					_, err := io.Copy(io.Discard, p)
+					return err
+				})
+				if got != nil {
+					return got
+				}
+				return c.NoContent()
+			})
+
+			body, ct := multipartRequest(t, nil, tt.files...)
+			req := httptest.NewRequest("POST", "/upload", body)
+			req.Header.Set("Content-Type", ct)
+			rec := httptest.NewRecorder()
+			app.ServeHTTP(rec, req)
+
+			if rec.Code != tt.code {
+				t.Errorf("expected %d, got %d: %s", tt.code, rec.Code, rec.Body.String())
+			}
+			if tt.err != nil && !errors.Is(got, tt.err) {
+				t.Errorf("expected %v, got %v", tt.err, got)
+			}
+		})
+	}
+}
+
+func TestBindMultipartUsesConfig(t *testing.T) {
+	app := marten.New()
+	app.SetMultipartConfig(marten.MultipartConfig{MaxFileSize: 4})
+	app.POST("/upload", func(c *marten.Ctx) error {
+		var v struct {
+			File *multipart.FileHeader `form:"file"`
+		}
+		if err := c.Bind(&v); err != nil {
+			return err
+		}
+		return c.NoContent()
+	})
+
+	body, ct := multipartRequest(t, nil, uploadFile{"file", "a.txt", []byte("too big")})
+	req := httptest.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", ct)
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	if rec.Code != 413 {
+		t.Errorf("expected 413, got %d", rec.Code)
+	}
+}
+
+func TestMultipartFormRemovesTempFiles(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	app := marten.New()
+	app.SetMultipartConfig(marten.MultipartConfig{MaxMemory: 16})
+	upload := func(c *marten.Ctx) error {
+		form, err := c.MultipartForm()
+		if err != nil {
+			return err
+		}
+		if entries, _ := os.ReadDir(tmp); len(entries) == 0 {
+			t.Error("expected the upload in a temporary file")
+		}
+		return c.Text(200, form.File["f"][0].Filename)
+	}
+	app.POST("/replaced", func(c *marten.Ctx) error {
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), struct{}{}, 1))
+		return upload(c)
+	})
+	app.POST("/timeout", upload, middleware.Timeout(time.Second))
+
+	for _, path := range []string{"/replaced", "/timeout"} {
+		body, ct := multipartRequest(t, nil, uploadFile{"f", "big.txt", bytes.Repeat([]byte("x"), 1<<10)})
+		req := httptest.NewRequest("POST", path, body)
+		req.Header.Set("Content-Type", ct)
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, req)
+		if rec.Code != 200 {
+			t.Fatalf("%s: got %d %s", path, rec.Code, rec.Body.String())
+		}
+		if entries, _ := os.ReadDir(tmp); len(entries) != 0 {
+			t.Errorf("%s: %d temporary files left", path, len(entries))
+		}
+	}
+}
+
+func TestMultipartFormLimitErrorSticks(t *testing.T) {
+	app := marten.New()
+	app.SetMultipartConfig(marten.MultipartConfig{MaxFileSize: 10})
+	app.POST("/upload", func(c *marten.Ctx) error {
+		_, first := c.MultipartForm()
+		if !errors.Is(first, marten.ErrFileTooLarge) {
+			t.Errorf("first call: %v", first)
+		}
+		form, err := c.MultipartForm()
+		if form != nil || err != first {
+			t.Errorf("second call returned %v, %v", form, err)
+		}
+		if fh, err := c.FormFile("f"); fh != nil || err != first {
+			t.Errorf("FormFile returned %v, %v", fh, err)
+		}
+		var v struct {
+			Name string `form:"name"`
+		}
+		return c.Bind(&v)
+	})
+
+	body, ct := multipartRequest(t, map[string]string{"name": "x"}, uploadFile{"f", "a.txt", []byte(strings.Repeat("x", 11))})
+	martentest.New(app).POST("/upload").Body(ct, body).Expect(t).Status(413)
+}
+
+func TestSaveUploadedFile(t *testing.T) {
+	dir := t.TempDir()
+	app := marten.New()
+	app.POST("/upload", func(c *marten.Ctx) error {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			return err
+		}
+		return c.SaveUploadedFile(fh, dir)
+	})
+
+	body, ct := multipartRequest(t, nil, uploadFile{"file", `..\..\evil:name?.txt`, []byte("data")})
+	req := httptest.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", ct)
+	app.ServeHTTP(httptest.NewRecorder(), req)
+
+	if b, err := os.ReadFile(filepath.Join(dir, "evil_name_.txt")); err != nil || string(b) != "data" {
+		entries, _ := os.ReadDir(dir)
+		t.Errorf("expected sanitized file, got %v (%v)", entries, err)
+	}
+}
+
+func TestSafeFilename(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"photo.jpg", "photo.jpg"},
+		{"../../etc/passwd", "passwd"},
+		{`C:\Users\me\doc.txt`, "doc.txt"},
+		{"..", "file"},
+		{".hidden", "hidden"},
+		{"a\x00b\nc.txt", "abc.txt"},
+		{"résumé.pdf", "résumé.pdf"},
+		{"", "file"},
+		{strings.Repeat("a", 300) + ".txt", strings.Repeat("a", 251) + ".txt"},
+	}
+	for _, tt := range tests {
+		if got := marten.SafeFilename(tt.in); got != tt.want {
+			t.Errorf("SafeFilename(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gomarten/marten"
+)
+
+type negotiateItem struct {
+	Name  string `json:"name" xml:"name"`
+	Price int    `json:"price" xml:"price"`
+}
+
+func (i negotiateItem) String() string {
+	return fmt.Sprintf("%s: %d", i.Name, i.Price)
+}
+
+func newNegotiateApp() *marten.App {
+	app := marten.New()
+	app.RegisterFormat("text/csv", func(w io.Writer, v any) error {
+		item := v.(negotiateItem)
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{item.Name, fmt.Sprint(item.Price)})
+		cw.Flush()
+		return cw.Error()
+	})
+	app.GET("/item", func(c *marten.Ctx) error {
+		return c.Negotiate(200, negotiateItem{Name: "pen", Price: 3})
+	})
+	app.GET("/json-only", func(c *marten.Ctx) error {
+		return c.Negotiate(200, negotiateItem{Name: "pen", Price: 3}, "application/json")
+	})
+	return app
+}
+
+func TestNegotiate(t *testing.T) {
+	app := newNegotiateApp()
+	tests := []struct {
+		accept      string
+		contentType string
+		body        string
+	}{
+		{"", "application/json", `{"name":"pen","price":3}`},
+		{"*/*", "application/json", `{"name":"pen","price":3}`},
+		{"application/xml", "application/xml", "<negotiateItem><name>pen</name><price>3</price></negotiateItem>"},
+		{"text/plain", "text/plain", "pen: 3"},
+		{"text/html", "text/html", "pen: 3"},
+		{"text/csv", "text/csv", "pen,3"},
+		{"text/*;q=0.5, application/xml;q=0.9", "application/xml", "<name>pen</name>"},
+		{"application/json;q=0.1, text/plain", "text/plain", "pen: 3"},
+		{"application/*, application/json;q=0", "application/xml", "<price>3</price>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/item", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			app.ServeHTTP(rec, req)
+
+			if rec.Code != 200 {
+				t.Errorf("expected 200, got %d", rec.Code)
+			}
+			if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, tt.contentType) {
+				t.Errorf("expected %s, got %s", tt.contentType, ct)
+			}
+			if !strings.Contains(rec.Body.String(), tt.body) {
+				t.Errorf("expected body to contain %q, got %q", tt.body, rec.Body.String())
+			}
+			if rec.Header().Get("Vary") != "Accept" {
+				t.Errorf("expected Vary: Accept, got %q", rec.Header().Get("Vary"))
+			}
+		})
+	}
+}
+
+func TestNegotiateNotAcceptable(t *testing.T) {
+	app := newNegotiateApp()
+	req := httptest.NewRequest("GET", "/json-only", nil)
+	req.Header.Set("Accept", "application/xml")
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	if rec.Code != 406 {
+		t.Errorf("expected 406, got %d", rec.Code)
+	}
+}
+
+func TestNegotiateHTMLEscapes(t *testing.T) {
+	app := marten.New()
+	app.GET("/", func(c *marten.Ctx) error {
+		return c.Negotiate(200, "<script>", "text/html")
+	})
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Body.String() != "&lt;script&gt;" {
+		t.Errorf("expected escaped HTML, got %q", rec.Body.String())
+	}
+}
+
+func TestBindXML(t *testing.T) {
+	app := marten.New()
+	app.POST("/item", func(c *marten.Ctx) error {
+		var item negotiateItem
+		if err := c.Bind(&item); err != nil {
+			return err
+		}
+		return c.OK(item)
+	})
+
+	req := httptest.NewRequest("POST", "/item", strings.NewReader("<item><name>cup</name><price>7</price></item>"))
+	req.Header.Set("Content-Type", "application/xml")
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	if !strings.Contains(rec.Body.String(), `{"name":"cup","price":7}`) {
+		t.Errorf("unexpected response %d %s", rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest("POST", "/item", strings.NewReader("<item><name>"))
+	req.Header.Set("Content-Type", "application/xml")
+	rec = httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if rec.Code != 400 {
+		t.Errorf("expected 400 for invalid XML, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,373 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gomarten/marten"
+	"github.com/gomarten/marten/martentest"
+	"github.com/gomarten/marten/middleware"
+)
+
+type apiAddress struct {
+	City string `json:"city" validate:"required"`
+}
+
+type apiUser struct {
+	ID        int        `json:"id"`
+	Name      string     `json:"name"`
+	Email     string     `json:"email,omitempty"`
+	Address   apiAddress `json:"address"`
+	Friends   []*apiUser `json:"friends,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type apiCreateUser struct {
+	Org     string     `param:"org" json:"-"`
+	DryRun  bool       `query:"dry_run"`
+	Tenant  string     `header:"X-Tenant" validate:"required"`
+	Name    string     `json:"name" validate:"required,min=3,max=64"`
+	Email   string     `json:"email" validate:"required,email"`
+	Role    string     `json:"role" validate:"oneof=admin member"`
+	Age     int        `json:"age" validate:"gte=0,lt=150"`
+	Tags    []string   `json:"tags" validate:"max=5,dive,min=2"`
+	Address apiAddress `json:"address"`
+}
+
+type apiListUsers struct {
+	Page  int        `query:"page" default:"1" validate:"min=1"`
+	Sort  string     `query:"sort" validate:"oneof=name created_at"`
+	Since *time.Time `query:"since"`
+}
+
+type apiGetUser struct {
+	ID int `param:"id"`
+}
+
+func openAPIApp() *marten.App {
+	app := marten.New()
+	marten.TypedRoute(app, "POST", "/orgs/:org/users", func(context.Context, apiCreateUser) (marten.Response[apiUser], error) {
+		return marten.Status(201, apiUser{}), nil
+	}).Doc(marten.Operation{Summary: "Create a user", Tags: []string{"users"}, Status: 201})
+	marten.TypedRoute(app, "GET", "/users", func(context.Context, apiListUsers) ([]apiUser, error) {
+		return nil, nil
+	}).Doc(marten.Operation{Tags: []string{"users"}})
+
+	admin := app.Group("/admin", middleware.BasicAuthSimple("admin", "secret")).Security(middleware.BasicAuthScheme)
+	marten.TypedRoute(admin, "GET", "/users/:id", func(context.Context, apiGetUser) (apiUser, error) {
+		return apiUser{}, nil
+	})
+	marten.TypedRoute(admin, "DELETE", "/users/:id", func(context.Context, apiGetUser) (struct{}, error) {
+		return struct{}{}, nil
+	}).Doc(marten.Operation{Deprecated: true, Responses: map[int]string{404: "No such user"}})
+
+	app.GET("/files/*path", func(c *marten.Ctx) error { return nil }).
+		Doc(marten.Operation{Summary: "Download a file", Response: []byte(nil)})
+	app.GET("/health", func(c *marten.Ctx) error { return c.OK(marten.M{"ok": true}) })
+	app.GET("/internal", func(c *marten.Ctx) error { return nil }).Doc(marten.Operation{Hidden: true})
+	return app
+}
+
+func openAPIDoc(t *testing.T, app *marten.App) map[string]any {
+	t.Helper()
+	data, err := app.OpenAPI(marten.OpenAPIConfig{Title: "Users", Version: "2.0.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// lookup walks a decoded JSON document by keys and indexes.
+func lookup(t *testing.T, v any, path ...any) any {
+	t.Helper()
+	for _, p := range path {
+		switch k := p.(type) {
+		case string:
+			m, ok := v.(map[string]any)
+			if !ok {
+				t.Fatalf("%v: not an object at %q", path, k)
+			}
+			v = m[k]
+		case int:
+			a, ok := v.([]any)
+			if !ok || k >= len(a) {
+				t.Fatalf("%v: no index %d", path, k)
+			}
+			v = a[k]
+		}
+	}
+	return v
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	doc := openAPIDoc(t, openAPIApp())
+
+	if doc["openapi"] != "3.1.0" || lookup(t, doc, "info", "title") != "Users" {
+		t.Errorf("unexpected header: %v %v", doc["openapi"], doc["info"])
+	}
+	paths := doc["paths"].(map[string]any)
+	for _, p := range []string{"/orgs/{org}/users", "/users", "/admin/users/{id}", "/files/{path}", "/health"} {
+		if paths[p] == nil {
+			t.Errorf("missing path %s", p)
+		}
+	}
+	if paths["/internal"] != nil || paths["/openapi.json"] != nil {
+		t.Error("hidden routes should be left out")
+	}
+}
+
+func TestOpenAPIParameters(t *testing.T) {
+	doc := openAPIDoc(t, openAPIApp())
+
+	post := lookup(t, doc, "paths", "/orgs/{org}/users", "post")
+	params := lookup(t, post, "parameters").([]any)
+	want := []string{"org:path", "dry_run:query", "X-Tenant:header"}
+	if len(params) != len(want) {
+		t.Fatalf("expected %d parameters, got %v", len(want), params)
+	}
+	for i, w := range want {
+		p := params[i].(map[string]any)
+		if p["name"].(string)+":"+p["in"].(string) != w {
+			t.Errorf("parameter %d: got %v, want %s", i, p, w)
+		}
+	}
+	if lookup(t, params[0], "required") != true || lookup(t, params[2], "required") != true {
+		t.Error("path and required header parameters should be required")
+	}
+	if lookup(t, params[1], "schema", "type") != "boolean" {
+		t.Errorf("dry_run schema: %v", lookup(t, params[1], "schema"))
+	}
+
+	list := lookup(t, doc, "paths", "/users", "get", "parameters").([]any)
+	page := lookup(t, list[0], "schema").(map[string]any)
+	if page["type"] != "integer" || page["default"] != float64(1) || page["minimum"] != float64(1) {
+		t.Errorf("page schema: %v", page)
+	}
+	if enum := lookup(t, list[1], "schema", "enum").([]any); len(enum) != 2 || enum[1] != "created_at" {
+		t.Errorf("sort enum: %v", enum)
+	}
+	if lookup(t, list[2], "schema", "format") != "date-time" {
+		t.Errorf("since schema: %v", lookup(t, list[2], "schema"))
+	}
+
+	get := lookup(t, doc, "paths", "/admin/users/{id}", "get", "parameters", 0, "schema", "type")
+	if get != "integer" {
+		t.Errorf("id should be an integer, got %v", get)
+	}
+	file := lookup(t, doc, "paths", "/files/{path}", "get", "parameters", 0, "schema", "type")
+	if file != "string" {
+		t.Errorf("untyped path params should be strings, got %v", file)
+	}
+}
+
+func TestOpenAPIRequestAndResponseSchemas(t *testing.T) {
+	doc := openAPIDoc(t, openAPIApp())
+
+	post := lookup(t, doc, "paths", "/orgs/{org}/users", "post")
+	body := lookup(t, post, "requestBody", "content", "application/json", "schema", "$ref")
+	if body != "#/components/schemas/apiCreateUser" {
+		t.Fatalf("request body ref: %v", body)
+	}
+	req := lookup(t, doc, "components", "schemas", "apiCreateUser").(map[string]any)
+	props := req["properties"].(map[string]any)
+	for _, tagged := range []string{"Org", "DryRun", "Tenant"} {
+		if props[tagged] != nil {
+			t.Errorf("%s is not part of the body", tagged)
+		}
+	}
+	name := props["name"].(map[string]any)
+	if name["minLength"] != float64(3) || name["maxLength"] != float64(64) {
+		t.Errorf("name schema: %v", name)
+	}
+	if lookup(t, props, "email", "format") != "email" {
+		t.Errorf("email schema: %v", props["email"])
+	}
+	age := props["age"].(map[string]any)
+	if age["minimum"] != float64(0) || age["exclusiveMaximum"] != float64(150) {
+		t.Errorf("age schema: %v", age)
+	}
+	tags := props["tags"].(map[string]any)
+	if tags["maxItems"] != float64(5) || lookup(t, tags, "items", "minLength") != float64(2) {
+		t.Errorf("tags schema: %v", tags)
+	}
+	required := req["required"].([]any)
+	if len(required) != 2 || required[0] != "email" || required[1] != "name" {
+		t.Errorf("required: %v", required)
+	}
+
+	created := lookup(t, post, "responses", "201", "content", "application/json", "schema", "$ref")
+	if created != "#/components/schemas/apiUser" {
+		t.Errorf("201 response: %v", lookup(t, post, "responses"))
+	}
+	for _, code := range []string{"400", "422"} {
+		if lookup(t, post, "responses", code) == nil {
+			t.Errorf("missing %s response", code)
+		}
+	}
+
+	user := lookup(t, doc, "components", "schemas", "apiUser", "properties").(map[string]any)
+	if lookup(t, user, "friends", "items", "$ref") != "#/components/schemas/apiUser" {
+		t.Errorf("recursive friends schema: %v", user["friends"])
+	}
+	if lookup(t, user, "address", "$ref") != "#/components/schemas/apiAddress" {
+		t.Errorf("address schema: %v", user["address"])
+	}
+
+	list := lookup(t, doc, "paths", "/users", "get")
+	if lookup(t, list, "requestBody") != nil {
+		t.Error("GET should not have a request body")
+	}
+	if lookup(t, list, "responses", "200", "content", "application/json", "schema", "type") != "array" {
+		t.Errorf("list response: %v", lookup(t, list, "responses"))
+	}
+
+	del := lookup(t, doc, "paths", "/admin/users/{id}", "delete")
+	if lookup(t, del, "responses", "204", "description") != "No Content" || lookup(t, del, "deprecated") != true {
+		t.Errorf("delete: %v", del)
+	}
+	if lookup(t, del, "responses", "404", "description") != "No such user" {
+		t.Errorf("404 response: %v", lookup(t, del, "responses"))
+	}
+
+	file := lookup(t, doc, "paths", "/files/{path}", "get", "responses", "200", "content", "application/json", "schema", "format")
+	if file != "byte" {
+		t.Errorf("Operation.Response should document untyped handlers, got %v", file)
+	}
+}
+
+func TestOpenAPISecurity(t *testing.T) {
+	doc := openAPIDoc(t, openAPIApp())
+
+	scheme := lookup(t, doc, "components", "securitySchemes", "basicAuth").(map[string]any)
+	if scheme["type"] != "http" || scheme["scheme"] != "basic" {
+		t.Errorf("basicAuth scheme: %v", scheme)
+	}
+	get := lookup(t, doc, "paths", "/admin/users/{id}", "get")
+	if lookup(t, get, "security", 0, "basicAuth") == nil || lookup(t, get, "responses", "401") == nil {
+		t.Errorf("secured operation: %v", get)
+	}
+	if lookup(t, doc, "paths", "/health", "get", "security") != nil {
+		t.Error("public route should not list security")
+	}
+}
+
+func TestOpenAPIOperationIDs(t *testing.T) {
+	doc := openAPIDoc(t, openAPIApp())
+	if id := lookup(t, doc, "paths", "/admin/users/{id}", "get", "operationId"); id != "getAdminUsersById" {
+		t.Errorf("operationId: %v", id)
+	}
+}
+
+func TestOpenAPIProblemDetails(t *testing.T) {
+	app := openAPIApp()
+	app.SetProblemDetails(true)
+	doc := openAPIDoc(t, app)
+	ref := lookup(t, doc, "paths", "/users", "get", "responses", "400", "content", "application/problem+json", "schema", "$ref")
+	if ref != "#/components/schemas/Problem" {
+		t.Errorf("problem response: %v", ref)
+	}
+}
+
+func TestOpenAPIDeterministic(t *testing.T) {
+	cfg := marten.OpenAPIConfig{Title: "Users", Version: "2.0.0", Deterministic: true}
+	first, err := openAPIApp().OpenAPI(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		again, _ := openAPIApp().OpenAPI(cfg)
+		if !bytes.Equal(first, again) {
+			t.Fatal("output differs between runs")
+		}
+	}
+	if !bytes.HasSuffix(first, []byte("}\n")) || !bytes.Contains(first, []byte("\n  \"info\"")) {
+		t.Error("deterministic output should be indented with a trailing newline")
+	}
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	app := openAPIApp()
+	app.ServeOpenAPI(marten.OpenAPIConfig{Title: "Users", DocsPath: "/docs", Deterministic: true})
+
+	martentest.New(app).GET("/openapi.json").Expect(t).
+		Status(200).
+		Header("Content-Type", "application/json; charset=utf-8").
+		Golden("testdata/openapi.golden")
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/docs", nil))
+	if rec.Code != 200 || !strings.Contains(rec.Body.String(), `const spec = "/openapi.json"`) {
+		t.Errorf("docs page: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestServeOpenAPIServerFromRequest(t *testing.T) {
+	app := marten.New()
+	app.ServeOpenAPI(marten.OpenAPIConfig{Path: "/spec.json"})
+	app.GET("/ping", func(c *marten.Ctx) error { return c.Text(200, "pong") })
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "http://api.example.com/spec.json", nil))
+	var doc map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &doc)
+	if lookup(t, doc, "servers", 0, "url") != "http://api.example.com" {
+		t.Errorf("servers: %v", doc["servers"])
+	}
+	if lookup(t, doc, "paths", "/ping") == nil {
+		t.Error("routes registered after ServeOpenAPI should be documented")
+	}
+}
+
+func TestOpenAPIDocsAreStoredPerRoute(t *testing.T) {
+	app := marten.New()
+	// One handler value on two routes; each route keeps its own docs
+	shared := func(c *marten.Ctx) error { return nil }
+	app.GET("/a", shared).Doc(marten.Operation{Summary: "A"})
+	app.GET("/b", shared).Doc(marten.Operation{Summary: "B", Request: apiListUsers{}})
+	app.GET("/c", shared)
+
+	doc := openAPIDoc(t, app)
+	if lookup(t, doc, "paths", "/a", "get", "summary") != "A" || lookup(t, doc, "paths", "/b", "get", "summary") != "B" {
+		t.Errorf("summaries: %v", doc["paths"])
+	}
+	if lookup(t, doc, "paths", "/c", "get", "summary") != nil {
+		t.Error("undocumented route should have no summary")
+	}
+	if params := lookup(t, doc, "paths", "/b", "get", "parameters").([]any); len(params) != 3 {
+		t.Errorf("parameters from Operation.Request: %v", params)
+	}
+}
+
+func TestOpenAPIGlobalAndGroupSecurity(t *testing.T) {
+	bearer := marten.SecurityScheme{Name: "bearerAuth", Type: "http", Scheme: "bearer"}
+	app := marten.New()
+	app.GET("/ping", func(c *marten.Ctx) error { return nil })
+	api := app.Group("/api").Security(middleware.BasicAuthScheme)
+	v1 := api.Group("/v1")
+	v1.GET("/items", func(c *marten.Ctx) error { return nil })
+	api.GET("/me", func(c *marten.Ctx) error { return nil })
+
+	data, err := app.OpenAPI(marten.OpenAPIConfig{Security: []marten.SecurityScheme{bearer}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]any
+	json.Unmarshal(data, &doc)
+	if lookup(t, doc, "paths", "/ping", "get", "security", 0, "bearerAuth") == nil {
+		t.Errorf("global scheme missing: %v", lookup(t, doc, "paths", "/ping", "get"))
+	}
+	for _, p := range []string{"/api/v1/items", "/api/me"} {
+		if lookup(t, doc, "paths", p, "get", "security", 1, "basicAuth") == nil {
+			t.Errorf("%s: group scheme missing: %v", p, lookup(t, doc, "paths", p, "get"))
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gomarten/marten"
+)
+
+func TestParamGeneric(t *testing.T) {
+	app := marten.New()
+	app.GET("/users/:id", func(c *marten.Ctx) error {
+		id, err := marten.Param[int](c, "id")
+		if err != nil {
+			return err
+		}
+		return c.OK(marten.M{"id": id})
+	})
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/users/42", nil))
+	if rec.Code != 200 || !strings.Contains(rec.Body.String(), `"id":42`) {
+		t.Fatalf("got %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/users/abc", nil))
+	if rec.Code != 400 {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `param \"id\": cannot use \"abc\"`) {
+		t.Errorf("unexpected body %s", rec.Body.String())
+	}
+}
+
+func TestParamGenericMissing(t *testing.T) {
+	app := marten.New()
+	var got error
+	app.GET("/", func(c *marten.Ctx) error {
+		_, got = marten.Param[int](c, "id")
+		return got
+	})
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	var pe *marten.ParamError
+	if !errors.As(got, &pe) || pe.Source != "param" || pe.Name != "id" {
+		t.Fatalf("expected ParamError, got %v", got)
+	}
+	if !errors.Is(got, marten.ErrMissingParam) {
+		t.Error("expected ErrMissingParam")
+	}
+	if rec.Code != 400 {
+		t.Errorf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestQueryGenericTypes(t *testing.T) {
+	app := marten.New()
+	app.GET("/", func(c *marten.Ctx) error {
+		since, err := marten.Query[time.Time](c, "since")
+		if err != nil {
+			return err
+		}
+		ip, err := marten.Query[netip.Addr](c, "ip")
+		if err != nil {
+			return err
+		}
+		tags, err := marten.Query[[]string](c, "tag")
+		if err != nil {
+			return err
+		}
+		every, err := marten.Query[time.Duration](c, "every")
+		if err != nil {
+			return err
+		}
+		verbose, err := marten.Query[*bool](c, "verbose")
+		if err != nil {
+			return err
+		}
+		return c.OK(marten.M{
+			"since":   since.Year(),
+			"ip":      ip.String(),
+			"tags":    tags,
+			"every":   every.Seconds(),
+			"verbose": *verbose,
+		})
+	})
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/?since=2024-05-01T00:00:00Z&ip=10.0.0.1&tag=a&tag=b&every=90s&verbose=true", nil))
+	if rec.Code != 200 {
+		t.Fatalf("got %d %s", rec.Code, rec.Body.String())
+	}
+	var body map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if body["since"] != float64(2024) || body["ip"] != "10.0.0.1" || body["every"] != float64(90) || body["verbose"] != true {
+		t.Errorf("unexpected body %v", body)
+	}
+	if tags, _ := body["tags"].([]any); len(tags) != 2 {
+		t.Errorf("expected two tags, got %v", body["tags"])
+	}
+
+	rec = httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/?since=2024-05-01T00:00:00Z&ip=nope", nil))
+	if rec.Code != 400 || !strings.Contains(rec.Body.String(), `query \"ip\"`) {
+		t.Errorf("got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestQueryOr(t *testing.T) {
+	app := marten.New()
+	app.GET("/", func(c *marten.Ctx) error {
+		limit, err := marten.QueryOr(c, "limit", 20)
+		if err != nil {
+			return err
+		}
+		return c.OK(marten.M{"limit": limit})
+	})
+
+	tests := []struct {
+		query string
+		code  int
+		want  string
+	}{
+		{"", 200, `"limit":20`},
+		{"?limit=", 200, `"limit":20`},
+		{"?limit=5", 200, `"limit":5`},
+		{"?limit=five", 400, `query \"limit\"`},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, httptest.NewRequest("GET", "/"+tt.query, nil))
+		if rec.Code != tt.code || !strings.Contains(rec.Body.String(), tt.want) {
+			t.Errorf("%q: got %d %s", tt.query, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestParamErrorProblemDetails(t *testing.T) {
+	app := marten.New()
+	app.SetProblemDetails(true)
+	app.GET("/users/:id", func(c *marten.Ctx) error {
+		_, err := marten.Param[uint](c, "id")
+		return err
+	})
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/users/-1", nil))
+	if rec.Code != 400 {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	var p struct {
+		InvalidParams []marten.InvalidParam `json:"invalid-params"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.InvalidParams) != 1 || p.InvalidParams[0].Name != "id" {
+		t.Errorf("unexpected invalid params %+v", p.InvalidParams)
+	}
+}
@@ -0,0 +1,239 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gomarten/marten"
+	"github.com/gomarten/marten/middleware"
+)
+
+func decodeProblem(t *testing.T, body string) map[string]any {
+	t.Helper()
+	var p map[string]any
+	if err := json.Unmarshal([]byte(body), &p); err != nil {
+		t.Fatalf("invalid problem JSON %q: %v", body, err)
+	}
+	return p
+}
+
+func TestProblemDetailsNotFound(t *testing.T) {
+	app := marten.New()
+	app.SetProblemDetails(true)
+
+	req := httptest.NewRequest("GET", "/missing", nil)
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	if rec.Code != 404 {
+		t.Errorf("expected 404, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/problem+json") {
+		t.Errorf("expected problem+json, got %q", ct)
+	}
+	p := decodeProblem(t, rec.Body.String())
+	if p["title"] != "Not Found" || p["status"] != float64(404) || p["type"] != "about:blank" {
+		t.Errorf("unexpected problem: %v", p)
+	}
+	if p["instance"] != "/missing" {
+		t.Errorf("expected instance /missing, got %v", p["instance"])
+	}
+}
+
+func TestProblemDetailsMethodNotAllowed(t *testing.T) {
+	app := marten.New()
+	app.SetProblemDetails(true)
+	app.GET("/users", func(c *marten.Ctx) error { return c.OK(nil) })
+
+	req := httptest.NewRequest("POST", "/users", nil)
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	if rec.Code != 405 {
+		t.Errorf("expected 405, got %d", rec.Code)
+	}
+	if p := decodeProblem(t, rec.Body.String()); p["status"] != float64(405) {
+		t.Errorf("unexpected problem: %v", p)
+	}
+	if rec.Header().Get("Allow") != "GET" {
+		t.Errorf("expected Allow header, got %q", rec.Header().Get("Allow"))
+	}
+}
+
+func TestProblemDetailsBindError(t *testing.T) {
+	app := marten.New()
+	app.SetProblemDetails(true)
+	app.POST("/users", func(c *marten.Ctx) error {
+		var v struct {
+			Name string `json:"name"`
+		}
+		return c.Bind(&v)
+	})
+
+	req := httptest.NewRequest("POST", "/users", strings.NewReader("{"))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	if rec.Code != 400 {
+		t.Errorf("expected 400, got %d", rec.Code)
+	}
+	p := decodeProblem(t, rec.Body.String())
+	if !strings.Contains(p["detail"].(string), "invalid JSON") {
+		t.Errorf("expected bind detail, got %v", p)
+	}
+}
+
+func TestProblemDetailsReturnedProblem(t *testing.T) {
+	app := marten.New()
+	app.SetProblemDetails(true)
+	app.POST("/transfer", func(c *marten.Ctx) error {
+		p := marten.NewProblem(403, "Your current balance is 30, but that costs 50.")
+		p.Type = "https://example.com/probs/out-of-credit"
+		p.Title = "You do not have enough credit."
+		p.InvalidParams = []marten.InvalidParam{{Name: "amount", Reason: "exceeds balance"}}
+		return p.With("balance", 30)
+	})
+
+	req := httptest.NewRequest("POST", "/transfer", nil)
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	if rec.Code != 403 {
+		t.Errorf("expected 403, got %d", rec.Code)
+	}
+	p := decodeProblem(t, rec.Body.String())
+	if p["type"] != "https://example.com/probs/out-of-credit" || p["balance"] != float64(30) {
+		t.Errorf("unexpected problem: %v", p)
+	}
+	params, ok := p["invalid-params"].([]any)
+	if !ok || len(params) != 1 {
+		t.Fatalf("expected invalid-params, got %v", p["invalid-params"])
+	}
+	if params[0].(map[string]any)["name"] != "amount" {
+		t.Errorf("unexpected invalid param: %v", params[0])
+	}
+}
+
+func TestProblemDetailsXML(t *testing.T) {
+	app := marten.New()
+	app.SetProblemDetails(true)
+	app.GET("/item", func(c *marten.Ctx) error {
+		return c.NotFound("item not found")
+	})
+
+	req := httptest.NewRequest("GET", "/item", nil)
+	req.Header.Set("Accept", "application/xml, application/json;q=0.5")
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/problem+xml") {
+		t.Errorf("expected problem+xml, got %q", ct)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, `<problem xmlns="urn:ietf:rfc:7807">`) || !strings.Contains(body, "<detail>item not found</detail>") {
+		t.Errorf("unexpected XML body: %s", body)
+	}
+}
+
+func TestProblemDetailsUnmappedErrorHidesCause(t *testing.T) {
+	app := marten.New()
+	app.SetProblemDetails(true)
+	app.GET("/fail", func(c *marten.Ctx) error {
+		return &notFoundError{Resource: "secret"}
+	})
+
+	req := httptest.NewRequest("GET", "/fail", nil)
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	if rec.Code != 500 {
+		t.Errorf("expected 500, got %d", rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "secret") {
+		t.Errorf("error leaked: %s", rec.Body.String())
+	}
+}
+
+func TestProblemDetailsMiddleware(t *testing.T) {
+	app := marten.New()
+	app.SetProblemDetails(true)
+	app.Use(middleware.Recover)
+	app.GET("/panic", func(c *marten.Ctx) error { panic("boom") })
+	app.GET("/slow", func(c *marten.Ctx) error {
+		<-c.Context().Done()
+		return nil
+	}, middleware.Timeout(10*time.Millisecond))
+	app.GET("/limited", func(c *marten.Ctx) error { return c.OK(nil) },
+		middleware.RateLimit(middleware.RateLimitConfig{Requests: 1, Window: time.Minute}))
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/panic", 500},
+		{"/slow", 504},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, req)
+		if rec.Code != tt.code {
+			t.Errorf("%s: expected %d, got %d", tt.path, tt.code, rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/problem+json") {
+			t.Errorf("%s: expected problem+json, got %q", tt.path, ct)
+		}
+	}
+
+	var rec *httptest.ResponseRecorder
+	for i := 0; i < 2; i++ {
+		rec = httptest.NewRecorder()
+		app.ServeHTTP(rec, httptest.NewRequest("GET", "/limited", nil))
+	}
+	if rec.Code != 429 {
+		t.Errorf("expected 429, got %d", rec.Code)
+	}
+	if p := decodeProblem(t, rec.Body.String()); p["detail"] != "rate limit exceeded" {
+		t.Errorf("unexpected problem: %v", p)
+	}
+}
+
+func TestProblemDetailsDisabledKeepsLegacyFormat(t *testing.T) {
+	app := marten.New()
+	app.GET("/item", func(c *marten.Ctx) error {
+		return c.NotFound("item not found")
+	})
+
+	req := httptest.NewRequest("GET", "/item", nil)
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	if !strings.Contains(rec.Body.String(), `"error":"item not found"`) {
+		t.Errorf("expected legacy error body, got %s", rec.Body.String())
+	}
+}
+
+var errOutOfStock = &marten.Problem{Status: 409, Detail: "Out of stock"}
+
+func TestProblemSharedValueNotModified(t *testing.T) {
+	app := marten.New()
+	app.SetProblemDetails(true)
+	app.GET("/items/:id", func(c *marten.Ctx) error {
+		return c.Problem(errOutOfStock)
+	})
+
+	for _, path := range []string{"/items/1", "/items/2"} {
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if p := decodeProblem(t, rec.Body.String()); p["instance"] != path || p["title"] != "Conflict" {
+			t.Errorf("%s: unexpected problem %v", path, p)
+		}
+	}
+	if errOutOfStock.Instance != "" || errOutOfStock.Title != "" || errOutOfStock.Type != "" {
+		t.Errorf("shared problem was modified: %+v", errOutOfStock)
+	}
+}
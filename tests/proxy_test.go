@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"crypto/tls"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gomarten/marten"
+)
+
+func proxyApp(t *testing.T, proxies ...string) *marten.App {
+	t.Helper()
+	app := marten.New()
+	if err := app.TrustedProxies(proxies); err != nil {
+		t.Fatal(err)
+	}
+	app.GET("/", func(c *marten.Ctx) error {
+		return c.Text(200, c.ClientIP()+" "+c.Scheme()+" "+c.Host())
+	})
+	return app
+}
+
+func proxyGet(app *marten.App, remote string, headers map[string]string) string {
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	req.RemoteAddr = remote
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	return rec.Body.String()
+}
+
+func TestClientIPUntrustedPeer(t *testing.T) {
+	spoof := map[string]string{
+		"X-Forwarded-For":   "1.1.1.1",
+		"X-Real-IP":         "2.2.2.2",
+		"Forwarded":         "for=3.3.3.3;proto=https;host=evil.test",
+		"X-Forwarded-Proto": "https",
+		"X-Forwarded-Host":  "evil.test",
+	}
+	// No trusted proxies: headers are ignored
+	if got := proxyGet(proxyApp(t), "203.0.113.7:5000", spoof); got != "203.0.113.7 http example.com" {
+		t.Errorf("got %q", got)
+	}
+	// Trusted proxies, but the peer is not one of them
+	if got := proxyGet(proxyApp(t, "10.0.0.0/8"), "203.0.113.7:5000", spoof); got != "203.0.113.7 http example.com" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestClientIPWalksFromRight(t *testing.T) {
+	app := proxyApp(t, "10.0.0.0/8", "2001:db8::/32")
+	tests := []struct {
+		name string
+		xff  string
+		want string
+	}{
+		{"single", "203.0.113.7", "203.0.113.7"},
+		{"spoofed prefix", "6.6.6.6, 203.0.113.7", "203.0.113.7"},
+		{"proxy chain", "6.6.6.6, 203.0.113.7, 10.1.1.1, 10.2.2.2", "203.0.113.7"},
+		{"all trusted", "10.3.3.3, 10.1.1.1", "10.3.3.3"},
+		{"ipv6 hop", "2001:db9::1, 2001:db8::2", "2001:db9::1"},
+		{"port", "203.0.113.7:4711", "203.0.113.7"},
+		{"garbage", "203.0.113.7, nonsense, 10.1.1.1", "10.1.1.1"},
+		{"garbage last", "203.0.113.7, nonsense", "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := proxyGet(app, "10.0.0.1:80", map[string]string{"X-Forwarded-For": tt.xff})
+			if want := tt.want + " http example.com"; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestClientIPXRealIP(t *testing.T) {
+	app := proxyApp(t, "10.0.0.1")
+	if got := proxyGet(app, "10.0.0.1:80", map[string]string{"X-Real-IP": "203.0.113.7"}); got != "203.0.113.7 http example.com" {
+		t.Errorf("got %q", got)
+	}
+	if got := proxyGet(app, "[::ffff:10.0.0.1]:80", map[string]string{"X-Real-IP": "203.0.113.7"}); got != "203.0.113.7 http example.com" {
+		t.Errorf("IPv4-mapped peer: got %q", got)
+	}
+}
+
+func TestForwardedHeader(t *testing.T) {
+	app := proxyApp(t, "10.0.0.0/8")
+	tests := []struct {
+		name      string
+		forwarded string
+		want      string
+	}{
+		{"simple", "for=203.0.113.7;proto=https;host=api.example.com", "203.0.113.7 https api.example.com"},
+		{"quoted ipv6", `for="[2001:db8:cafe::17]:4711";proto=https`, "2001:db8:cafe::17 https example.com"},
+		{"chain", "for=6.6.6.6;host=evil.test, for=203.0.113.7;proto=https;host=shop.example, for=10.1.1.1", "203.0.113.7 https shop.example"},
+		{"case", "For=203.0.113.7;Proto=HTTPS", "203.0.113.7 https example.com"},
+		{"obfuscated", "for=_hidden, for=10.1.1.1", "10.1.1.1 http example.com"},
+		{"unknown scheme", "for=203.0.113.7;proto=gopher", "203.0.113.7 http example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := proxyGet(app, "10.0.0.1:80", map[string]string{"Forwarded": tt.forwarded}); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// X-Forwarded-For wins when both are sent
+	got := proxyGet(app, "10.0.0.1:80", map[string]string{"Forwarded": "for=6.6.6.6", "X-Forwarded-For": "203.0.113.7"})
+	if got != "203.0.113.7 http example.com" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestSchemeHostFromProxy(t *testing.T) {
+	app := proxyApp(t, "10.0.0.0/8")
+	app.GET("/tls", func(c *marten.Ctx) error {
+		if c.IsTLS() {
+			return c.Text(200, "tls")
+		}
+		return c.Text(200, "plain")
+	})
+
+	got := proxyGet(app, "10.0.0.1:80", map[string]string{
+		"X-Forwarded-For":   "203.0.113.7",
+		"X-Forwarded-Proto": "HTTPS",
+		"X-Forwarded-Host":  "shop.example",
+	})
+	if got != "203.0.113.7 https shop.example" {
+		t.Errorf("got %q", got)
+	}
+	// The nearest proxy's value is used
+	got = proxyGet(app, "10.0.0.1:80", map[string]string{"X-Forwarded-Proto": "https, http"})
+	if got != "10.0.0.1 http example.com" {
+		t.Errorf("got %q", got)
+	}
+
+	req := httptest.NewRequest("GET", "/tls", nil)
+	req.RemoteAddr = "10.0.0.1:80"
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if rec.Body.String() != "tls" {
+		t.Errorf("expected IsTLS behind a TLS-terminating proxy")
+	}
+
+	// Direct TLS connections
+	req = httptest.NewRequest("GET", "/tls", nil)
+	req.TLS = &tls.ConnectionState{}
+	rec = httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if rec.Body.String() != "tls" {
+		t.Errorf("expected IsTLS for a TLS connection")
+	}
+}
+
+func TestTrustedProxiesInvalid(t *testing.T) {
+	app := marten.New()
+	for _, p := range []string{"10.0.0.0/33", "not-an-ip", ""} {
+		if err := app.TrustedProxies([]string{p}); err == nil {
+			t.Errorf("expected an error for %q", p)
+		}
+	}
+}
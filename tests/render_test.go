@@ -0,0 +1,204 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gomarten/marten"
+)
+
+func templateFS() fstest.MapFS {
+	return fstest.MapFS{
+		"layouts/base.html": {Data: []byte(`<html><title>{{block "title" .}}App{{end}}</title>{{template "partials/nav" .}}<main>{{block "content" .}}{{end}}</main></html>`)},
+		"partials/nav.html": {Data: []byte(`<nav><a href="{{urlFor "/users/:id" .ID}}">me</a></nav>`)},
+		"users/show.html":   {Data: []byte(`{{define "title"}}{{.Name}}{{end}}{{define "content"}}<h1>{{.Name}}</h1><input name="csrf" value="{{csrfToken}}">{{end}}`)},
+		"fragment.html":     {Data: []byte(`{{define "layout"}}{{end}}<p>{{.Name}}</p>`)},
+		"email/welcome.txt": {Data: []byte(`Hello {{.Name}}, see {{urlFor "/users/:id" .ID "ref" "email"}}`)},
+	}
+}
+
+func newRenderApp(t *testing.T) *marten.App {
+	t.Helper()
+	r, err := marten.NewTemplateRenderer(marten.TemplateConfig{FS: templateFS(), Layout: "layouts/base"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := marten.New()
+	app.SetRenderer(r)
+	return app
+}
+
+func TestRenderLayoutAndPartials(t *testing.T) {
+	app := newRenderApp(t)
+	app.GET("/users/:id", func(c *marten.Ctx) error {
+		c.Set(marten.CSRFTokenKey, "tok123")
+		return c.Render(200, "users/show", marten.M{"ID": 7, "Name": "<Alice>"})
+	})
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/users/7", nil))
+
+	if rec.Code != 200 {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	want := `<html><title>&lt;Alice&gt;</title><nav><a href="/users/7">me</a></nav><main><h1>&lt;Alice&gt;</h1><input name="csrf" value="tok123"></main></html>`
+	if got := rec.Body.String(); got != want {
+		t.Errorf("unexpected body:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestRenderWithoutLayout(t *testing.T) {
+	app := newRenderApp(t)
+	app.GET("/", func(c *marten.Ctx) error {
+		return c.Render(200, "fragment", marten.M{"Name": "x"})
+	})
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	if got := rec.Body.String(); got != "<p>x</p>" {
+		t.Errorf("unexpected body %q", got)
+	}
+}
+
+func TestRenderTextTemplate(t *testing.T) {
+	r, err := marten.NewTemplateRenderer(marten.TemplateConfig{FS: templateFS(), Layout: "layouts/base"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := r.Render(&buf, "email/welcome", marten.M{"ID": 7, "Name": "<Alice>"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "Hello <Alice>, see /users/7?ref=email" {
+		t.Errorf("unexpected body %q", got)
+	}
+
+	app := marten.New()
+	app.SetRenderer(r)
+	app.GET("/", func(c *marten.Ctx) error {
+		return c.Render(200, "email/welcome.txt", marten.M{"ID": 1, "Name": "Bob"})
+	})
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("expected text/plain, got %q", ct)
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	app := marten.New()
+	var err error
+	app.GET("/", func(c *marten.Ctx) error {
+		err = c.Render(200, "missing", nil)
+		return err
+	})
+	app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if !errors.Is(err, marten.ErrNoRenderer) {
+		t.Errorf("expected ErrNoRenderer, got %v", err)
+	}
+
+	app = newRenderApp(t)
+	app.GET("/", func(c *marten.Ctx) error {
+		return c.Render(200, "missing", nil)
+	})
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != 500 {
+		t.Errorf("expected 500 for missing template, got %d", rec.Code)
+	}
+
+	_, err = marten.NewTemplateRenderer(marten.TemplateConfig{FS: fstest.MapFS{
+		"bad.html": {Data: []byte(`{{.Name`)},
+	}})
+	if err == nil {
+		t.Error("expected parse error")
+	}
+}
+
+func TestRenderReload(t *testing.T) {
+	dir := t.TempDir()
+	page := filepath.Join(dir, "index.html")
+	if err := os.WriteFile(page, []byte("v1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := marten.NewTemplateRenderer(marten.TemplateConfig{FS: os.DirFS(dir), Reload: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := marten.New()
+	app.SetRenderer(r)
+	app.GET("/", func(c *marten.Ctx) error {
+		return c.Render(200, "index", nil)
+	})
+
+	if err := os.WriteFile(page, []byte("v2"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Body.String() != "v2" {
+		t.Errorf("expected reloaded template, got %q", rec.Body.String())
+	}
+}
+
+func TestRenderExecErrorKeepsHeaders(t *testing.T) {
+	r, err := marten.NewTemplateRenderer(marten.TemplateConfig{FS: fstest.MapFS{
+		"broken.html": {Data: []byte(`<p>{{.Name.Missing}}</p>`)},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := marten.New()
+	app.GET("/", func(c *marten.Ctx) error {
+		var buf bytes.Buffer
+		if err := r.Render(&buf, "broken", marten.M{"Name": "x"}, c); err == nil {
+			t.Error("expected an execution error")
+		}
+		if ct := c.Writer.Header().Get("Content-Type"); ct != "" || buf.Len() != 0 {
+			t.Errorf("failed render left content type %q and body %q", ct, buf.String())
+		}
+		return c.JSON(500, marten.M{"error": "render"})
+	})
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("expected JSON error response, got %q", ct)
+	}
+}
+
+// Run with -race: concurrent renders share pooled templates.
+func TestRenderConcurrentCSRFTokens(t *testing.T) {
+	app := newRenderApp(t)
+	app.GET("/users/:id", func(c *marten.Ctx) error {
+		c.Set(marten.CSRFTokenKey, c.Query("t"))
+		return c.Render(200, "users/show", marten.M{"ID": 7, "Name": "Alice"})
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			token := fmt.Sprintf("tok%d", i)
+			rec := httptest.NewRecorder()
+			app.ServeHTTP(rec, httptest.NewRequest("GET", "/users/7?t="+token, nil))
+			if want := `value="` + token + `"`; !strings.Contains(rec.Body.String(), want) {
+				t.Errorf("request %d: body %s", i, rec.Body.String())
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gomarten/marten"
+	"github.com/gomarten/marten/martentest"
+	"github.com/gomarten/marten/middleware"
+)
+
+func TestRequestIDRejectsInvalidIncoming(t *testing.T) {
+	app := marten.New()
+	app.Use(middleware.RequestID)
+	app.GET("/", func(c *marten.Ctx) error {
+		return c.Text(200, c.RequestID())
+	})
+
+	for _, in := range []string{
+		"abc\nlevel=error msg=forged",
+		strings.Repeat("a", 129),
+		"<script>",
+		"id with spaces",
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Request-ID", in)
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, req)
+		got := rec.Body.String()
+		if got == in || !marten.ValidRequestID(got) || rec.Header().Get("X-Request-ID") != got {
+			t.Errorf("incoming %q: got %q", in, got)
+		}
+	}
+}
+
+func TestRequestIDWithoutMiddlewareValidates(t *testing.T) {
+	app := marten.New()
+	app.GET("/", func(c *marten.Ctx) error {
+		return c.Text(200, c.RequestID())
+	})
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-ID", "bad id\r\n")
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if got := rec.Body.String(); got == "bad id\r\n" || len(got) != 16 {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestRequestIDConfig(t *testing.T) {
+	app := marten.New()
+	app.Use(middleware.RequestIDWithConfig(middleware.RequestIDConfig{
+		Header:        "X-Correlation-ID",
+		Generator:     func() string { return "generated" },
+		Validator:     func(id string) bool { return strings.HasPrefix(id, "corr-") },
+		TrustIncoming: true,
+	}))
+	app.GET("/", func(c *marten.Ctx) error {
+		return c.Text(200, c.RequestID())
+	})
+
+	tests := []struct{ in, want string }{
+		{"", "generated"},
+		{"corr-42", "corr-42"},
+		{"other-42", "generated"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Correlation-ID", tt.in)
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, req)
+		if rec.Body.String() != tt.want || rec.Header().Get("X-Correlation-ID") != tt.want {
+			t.Errorf("incoming %q: got %q", tt.in, rec.Body.String())
+		}
+	}
+}
+
+func TestRequestIDUntrustedIncoming(t *testing.T) {
+	app := marten.New()
+	app.Use(middleware.RequestIDWithConfig(middleware.RequestIDConfig{Generator: middleware.UUIDv4}))
+	app.GET("/", func(c *marten.Ctx) error {
+		return c.Text(200, c.RequestID())
+	})
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-ID", "client-chosen")
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if rec.Body.String() == "client-chosen" {
+		t.Error("incoming ID should be ignored when TrustIncoming is off")
+	}
+}
+
+func TestRequestIDGenerators(t *testing.T) {
+	tests := []struct {
+		name string
+		gen  func() string
+		re   *regexp.Regexp
+	}{
+		{"uuidv4", middleware.UUIDv4, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)},
+		{"uuidv7", middleware.UUIDv7, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)},
+		{"ulid", middleware.ULID, regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen := make(map[string]bool)
+			prev := ""
+			for i := 0; i < 100; i++ {
+				id := tt.gen()
+				if !tt.re.MatchString(id) {
+					t.Fatalf("malformed ID %q", id)
+				}
+				if !marten.ValidRequestID(id) {
+					t.Fatalf("ID %q should pass ValidRequestID", id)
+				}
+				if seen[id] {
+					t.Fatalf("duplicate ID %q", id)
+				}
+				seen[id] = true
+				// Time-ordered IDs start with the timestamp
+				if tt.name != "uuidv4" && prev != "" && id[:8] < prev[:8] {
+					t.Fatalf("IDs not time-ordered: %q after %q", id, prev)
+				}
+				prev = id
+			}
+		})
+	}
+}
+
+func TestRequestIDContextPropagation(t *testing.T) {
+	var forwarded string
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header.Get("X-Request-ID")
+	})
+	client := &http.Client{Transport: &middleware.RequestIDTransport{Base: &martentest.Transport{Handler: upstream}}}
+
+	app := marten.New()
+	app.Use(middleware.RequestID)
+	app.GET("/", func(c *marten.Ctx) error {
+		if got := marten.RequestIDFromContext(c.Context()); got != c.RequestID() {
+			t.Errorf("context carries %q, want %q", got, c.RequestID())
+		}
+		req, _ := http.NewRequestWithContext(c.Context(), "GET", "http://upstream.test/", nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return c.NoContent()
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-ID", "trace-123")
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if rec.Code != 204 || forwarded != "trace-123" {
+		t.Errorf("got %d, forwarded %q", rec.Code, forwarded)
+	}
+
+	if marten.RequestIDFromContext(context.Background()) != "" {
+		t.Error("expected no ID in a plain context")
+	}
+}
@@ -0,0 +1,231 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gomarten/marten"
+	"github.com/gomarten/marten/martentest"
+	"github.com/gomarten/marten/middleware"
+)
+
+func TestResponseWriterDirectWrites(t *testing.T) {
+	var logs bytes.Buffer
+	app := marten.New()
+	app.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{Output: &logs}))
+	app.GET("/std", func(c *marten.Ctx) error {
+		http.Error(c.Writer, "boom", http.StatusInternalServerError)
+		return nil
+	})
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/std", nil))
+	if rec.Code != 500 {
+		t.Fatalf("expected 500, got %d", rec.Code)
+	}
+	if !strings.Contains(logs.String(), "GET /std 500") {
+		t.Errorf("logger should see the status written directly, got %q", logs.String())
+	}
+}
+
+func TestResponseWriterStatusAndSize(t *testing.T) {
+	var (
+		status  int
+		size    int64
+		written bool
+	)
+	app := marten.New()
+	app.Use(func(next marten.Handler) marten.Handler {
+		return func(c *marten.Ctx) error {
+			err := next(c)
+			status, size, written = c.StatusCode(), c.Response().Size(), c.Written()
+			return err
+		}
+	})
+	app.GET("/", func(c *marten.Ctx) error {
+		c.Writer.WriteHeader(http.StatusAccepted)
+		io.WriteString(c.Writer, "hello")
+		c.Writer.Write([]byte(" world"))
+		return nil
+	})
+
+	app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if status != 202 || size != 11 || !written {
+		t.Errorf("got status=%d size=%d written=%v", status, size, written)
+	}
+}
+
+func TestResponseWriterHooks(t *testing.T) {
+	var (
+		order []string
+		sizes []int
+	)
+	app := marten.New()
+	app.GET("/", func(c *marten.Ctx) error {
+		c.Response().BeforeWrite(func() {
+			order = append(order, "before")
+			c.Header("X-Status", http.StatusText(c.Response().Status()))
+		})
+		c.Response().AfterWrite(func(n int) { sizes = append(sizes, n) })
+		return c.JSON(201, marten.M{"ok": true})
+	})
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Header().Get("X-Status") != "Created" {
+		t.Errorf("BeforeWrite should set headers before they are sent, got %v", rec.Header())
+	}
+	if len(order) != 1 || len(sizes) != 1 || sizes[0] != rec.Body.Len() {
+		t.Errorf("hooks: order=%v sizes=%v body=%d", order, sizes, rec.Body.Len())
+	}
+}
+
+func TestResponseWriterHeaderOnce(t *testing.T) {
+	app := marten.New()
+	app.GET("/", func(c *marten.Ctx) error {
+		c.Writer.WriteHeader(http.StatusCreated)
+		c.Writer.WriteHeader(http.StatusInternalServerError)
+		return errors.New("after the response")
+	})
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusCreated {
+		t.Errorf("expected the first final status to win, got %d", rec.Code)
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("error handler should not write after a direct response, got %q", rec.Body.String())
+	}
+}
+
+// readerFromRecorder records whether ReadFrom was used.
+type readerFromRecorder struct {
+	*httptest.ResponseRecorder
+	readFrom bool
+}
+
+func (w *readerFromRecorder) ReadFrom(r io.Reader) (int64, error) {
+	w.readFrom = true
+	return io.Copy(w.ResponseRecorder, r)
+}
+
+func TestResponseWriterReadFrom(t *testing.T) {
+	app := marten.New()
+	var size int64
+	app.GET("/", func(c *marten.Ctx) error {
+		// Hide strings.Reader's WriteTo so io.Copy uses the writer's ReadFrom
+		_, err := io.Copy(c.Writer, struct{ io.Reader }{strings.NewReader("streamed body")})
+		size = c.Response().Size()
+		return err
+	})
+
+	w := &readerFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+	app.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if !w.readFrom || w.Body.String() != "streamed body" || size != 13 {
+		t.Errorf("readFrom=%v body=%q size=%d", w.readFrom, w.Body.String(), size)
+	}
+}
+
+func TestResponseWriterPushNotSupported(t *testing.T) {
+	c, _ := martentest.NewCtx(httptest.NewRequest("GET", "/", nil))
+	pusher, ok := c.Writer.(http.Pusher)
+	if !ok {
+		t.Fatal("writer should implement http.Pusher")
+	}
+	if err := pusher.Push("/app.js", nil); !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported, got %v", err)
+	}
+}
+
+func TestResponseWriterFlushThroughMiddleware(t *testing.T) {
+	for name, mw := range map[string]marten.Middleware{
+		"compress": middleware.Compress(middleware.DefaultCompressConfig()),
+		"none":     func(next marten.Handler) marten.Handler { return next },
+	} {
+		t.Run(name, func(t *testing.T) {
+			app := marten.New()
+			app.Use(mw)
+			app.GET("/", func(c *marten.Ctx) error {
+				c.Text(200, "chunk")
+				return http.NewResponseController(c.Writer).Flush()
+			})
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			app.ServeHTTP(rec, req)
+			if !rec.Flushed {
+				t.Error("flush should reach the underlying writer")
+			}
+		})
+	}
+}
+
+func TestResponseWriterHijackThroughMiddleware(t *testing.T) {
+	app := marten.New()
+	app.Use(middleware.Compress(middleware.DefaultCompressConfig()))
+	app.Use(middleware.ETag)
+	status := make(chan int, 1)
+	app.Use(func(next marten.Handler) marten.Handler {
+		return func(c *marten.Ctx) error {
+			err := next(c)
+			status <- c.StatusCode()
+			return err
+		}
+	})
+	app.GET("/raw", func(c *marten.Ctx) error {
+		conn, rw, err := http.NewResponseController(c.Writer).Hijack()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 3\r\nConnection: close\r\n\r\nraw")
+		return rw.Flush()
+	})
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/raw", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(bufio.NewReader(resp.Body))
+	if string(body) != "raw" {
+		t.Errorf("expected hijacked response, got %q", body)
+	}
+	if code := <-status; code != 0 {
+		t.Errorf("hijacked responses have no recorded status, got %d", code)
+	}
+}
+
+func TestResponseWriterETagStatus(t *testing.T) {
+	var status int
+	app := marten.New()
+	app.Use(func(next marten.Handler) marten.Handler {
+		return func(c *marten.Ctx) error {
+			err := next(c)
+			status = c.StatusCode()
+			return err
+		}
+	})
+	app.Use(middleware.ETag)
+	app.GET("/", func(c *marten.Ctx) error { return c.Text(200, "cached") })
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	rec = httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if rec.Code != 304 || status != 304 {
+		t.Errorf("expected 304 on the wire and in StatusCode, got %d and %d", rec.Code, status)
+	}
+}
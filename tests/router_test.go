@@ -320,3 +320,24 @@ func TestRouterConcurrent(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestRouterMiddlewarePerMethod(t *testing.T) {
+	app := marten.New()
+	deny := func(next marten.Handler) marten.Handler {
+		return func(c *marten.Ctx) error { return c.Forbidden("denied") }
+	}
+	app.DELETE("/items/:id", func(c *marten.Ctx) error { return c.NoContent() }, deny)
+	app.GET("/items/:id", func(c *marten.Ctx) error { return c.Text(200, "item") })
+
+	// Registering GET must not drop the middleware of DELETE on the same path
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("DELETE", "/items/1", nil))
+	if rec.Code != 403 {
+		t.Errorf("DELETE: expected 403, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/items/1", nil))
+	if rec.Code != 200 {
+		t.Errorf("GET: expected 200, got %d", rec.Code)
+	}
+}
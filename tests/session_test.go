@@ -0,0 +1,390 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gomarten/marten"
+	"github.com/gomarten/marten/martentest"
+	"github.com/gomarten/marten/middleware"
+)
+
+func sessionApp(cfg middleware.SessionConfig) *marten.App {
+	app := marten.New()
+	app.Use(middleware.Session(cfg))
+	app.GET("/get", func(c *marten.Ctx) error {
+		v, _ := c.Session().Get(c.Query("k")).(string)
+		return c.Text(200, v)
+	})
+	app.GET("/set", func(c *marten.Ctx) error {
+		c.Session().Set(c.Query("k"), c.Query("v"))
+		return c.Text(200, "ok")
+	})
+	app.GET("/id", func(c *marten.Ctx) error {
+		return c.Text(200, c.Session().ID())
+	})
+	app.GET("/login", func(c *marten.Ctx) error {
+		c.Session().Regenerate()
+		c.Session().Set("user", "alice")
+		return c.Text(200, "ok")
+	})
+	app.GET("/logout", func(c *marten.Ctx) error {
+		c.Session().Destroy()
+		return c.NoContent()
+	})
+	return app
+}
+
+func sessionStores(t *testing.T) map[string]middleware.SessionStore {
+	t.Helper()
+	mem := middleware.NewMemoryStore(0)
+	t.Cleanup(mem.Stop)
+	files, err := middleware.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, _ := marten.NewKeyring(cookieSecret)
+	return map[string]middleware.SessionStore{
+		"memory": mem,
+		"file":   files,
+		"cookie": middleware.NewCookieStore(keys),
+	}
+}
+
+func TestSessionStores(t *testing.T) {
+	for name, store := range sessionStores(t) {
+		t.Run(name, func(t *testing.T) {
+			app := sessionApp(middleware.SessionConfig{Store: store})
+			tr := martentest.New(app)
+
+			tr.GET("/get?k=user").Expect(t).Status(200).Body("")
+			tr.GET("/set?k=user&v=bob").Expect(t).Status(200)
+			tr.GET("/get?k=user").Expect(t).Body("bob")
+			tr.GET("/set?k=theme&v=dark").Expect(t).Status(200)
+			tr.GET("/get?k=user").Expect(t).Body("bob")
+			tr.GET("/get?k=theme").Expect(t).Body("dark")
+
+			// Another client has its own session
+			martentest.New(app).GET("/get?k=user").Expect(t).Body("")
+		})
+	}
+}
+
+func TestSessionLazySave(t *testing.T) {
+	store := middleware.NewMemoryStore(0)
+	defer store.Stop()
+	tr := martentest.New(sessionApp(middleware.SessionConfig{Store: store}))
+
+	rec := tr.GET("/get?k=user").Expect(t).Result()
+	if len(rec.Cookies()) != 0 || store.Len() != 0 {
+		t.Fatalf("unmodified new session should not be saved: %v, %d", rec.Cookies(), store.Len())
+	}
+
+	tr.GET("/set?k=user&v=bob").Expect(t).HeaderContains("Set-Cookie", "session=")
+	if store.Len() != 1 {
+		t.Fatalf("expected one stored session, got %d", store.Len())
+	}
+	if got := tr.GET("/get?k=user").Expect(t).Result().Header.Get("Set-Cookie"); got != "" {
+		t.Errorf("reading a session should not save it, got Set-Cookie %q", got)
+	}
+}
+
+func TestSessionCookieAttributes(t *testing.T) {
+	tr := martentest.New(sessionApp(middleware.SessionConfig{
+		CookieName:   "sid",
+		CookieSecure: true,
+		IdleTimeout:  time.Hour,
+	}))
+	ck := tr.GET("/set?k=a&v=b").Expect(t).Result().Cookies()[0]
+	if ck.Name != "sid" || !ck.HttpOnly || !ck.Secure || ck.SameSite != http.SameSiteLaxMode || ck.Path != "/" || ck.MaxAge != 3600 {
+		t.Errorf("unexpected cookie %+v", ck)
+	}
+}
+
+func TestSessionFlash(t *testing.T) {
+	app := sessionApp(middleware.SessionConfig{})
+	app.GET("/flash", func(c *marten.Ctx) error {
+		c.Session().AddFlash("notice", "saved")
+		c.Session().AddFlash("notice", "emailed")
+		return c.Redirect(303, "/show")
+	})
+	app.GET("/show", func(c *marten.Ctx) error {
+		return c.Text(200, strings.Join(c.Session().Flashes("notice"), ","))
+	})
+	tr := martentest.New(app)
+
+	tr.GET("/flash").Expect(t).Status(303)
+	tr.GET("/show").Expect(t).Body("saved,emailed")
+	tr.GET("/show").Expect(t).Body("")
+}
+
+func TestSessionRegenerate(t *testing.T) {
+	store := middleware.NewMemoryStore(0)
+	defer store.Stop()
+	app := sessionApp(middleware.SessionConfig{Store: store})
+	tr := martentest.New(app)
+
+	tr.GET("/set?k=cart&v=3").Expect(t)
+	before := tr.GET("/id").Expect(t).Recorder.Body.String()
+	old := &http.Cookie{Name: "session", Value: before}
+
+	tr.GET("/login").Expect(t)
+	after := tr.GET("/id").Expect(t).Recorder.Body.String()
+	if after == before || after == "" {
+		t.Fatalf("expected a new session ID, got %q -> %q", before, after)
+	}
+	tr.GET("/get?k=cart").Expect(t).Body("3")
+	tr.GET("/get?k=user").Expect(t).Body("alice")
+	if store.Len() != 1 {
+		t.Errorf("old session should be deleted, %d stored", store.Len())
+	}
+
+	// A fixated ID is useless after login
+	martentest.New(app).GET("/get?k=user").Cookie(old).Expect(t).Body("")
+}
+
+func TestSessionDestroy(t *testing.T) {
+	for name, store := range sessionStores(t) {
+		t.Run(name, func(t *testing.T) {
+			tr := martentest.New(sessionApp(middleware.SessionConfig{Store: store}))
+			tr.GET("/login").Expect(t)
+			tr.GET("/get?k=user").Expect(t).Body("alice")
+
+			tr.GET("/logout").Expect(t).Status(204).HeaderContains("Set-Cookie", "Max-Age=0")
+			tr.GET("/get?k=user").Expect(t).Body("")
+		})
+	}
+}
+
+func TestSessionDestroyThenSet(t *testing.T) {
+	app := sessionApp(middleware.SessionConfig{})
+	app.GET("/signout", func(c *marten.Ctx) error {
+		c.Session().Destroy()
+		c.Session().AddFlash("notice", "signed out")
+		return c.Text(200, "ok")
+	})
+	app.GET("/show", func(c *marten.Ctx) error {
+		return c.Text(200, strings.Join(c.Session().Flashes("notice"), ","))
+	})
+	tr := martentest.New(app)
+
+	tr.GET("/login").Expect(t)
+	before := tr.GET("/id").Expect(t).Recorder.Body.String()
+	tr.GET("/signout").Expect(t)
+	tr.GET("/get?k=user").Expect(t).Body("")
+	tr.GET("/show").Expect(t).Body("signed out")
+	if after := tr.GET("/id").Expect(t).Recorder.Body.String(); after == before {
+		t.Error("a session started after Destroy should have a new ID")
+	}
+}
+
+func TestSessionIdleTimeout(t *testing.T) {
+	tr := martentest.New(sessionApp(middleware.SessionConfig{IdleTimeout: 40 * time.Millisecond}))
+	tr.GET("/set?k=user&v=bob").Expect(t)
+	tr.GET("/get?k=user").Expect(t).Body("bob")
+
+	time.Sleep(60 * time.Millisecond)
+	tr.GET("/get?k=user").Expect(t).Body("").HeaderContains("Set-Cookie", "Max-Age=0")
+}
+
+func TestSessionIdleTimeoutSlides(t *testing.T) {
+	tr := martentest.New(sessionApp(middleware.SessionConfig{IdleTimeout: 200 * time.Millisecond}))
+	tr.GET("/set?k=user&v=bob").Expect(t)
+	for i := 0; i < 6; i++ {
+		time.Sleep(60 * time.Millisecond)
+		tr.GET("/get?k=user").Expect(t).Body("bob")
+	}
+}
+
+func TestSessionAbsoluteTimeout(t *testing.T) {
+	tr := martentest.New(sessionApp(middleware.SessionConfig{
+		IdleTimeout:     time.Hour,
+		AbsoluteTimeout: 80 * time.Millisecond,
+	}))
+	tr.GET("/set?k=user&v=bob").Expect(t)
+	time.Sleep(40 * time.Millisecond)
+	tr.GET("/set?k=theme&v=dark").Expect(t)
+	tr.GET("/get?k=user").Expect(t).Body("bob")
+
+	time.Sleep(50 * time.Millisecond)
+	tr.GET("/get?k=user").Expect(t).Body("")
+}
+
+func TestSessionSavedBeforeStreaming(t *testing.T) {
+	app := marten.New()
+	app.Use(middleware.Session(middleware.SessionConfig{}))
+	app.GET("/stream", func(c *marten.Ctx) error {
+		c.Session().Set("seen", "yes")
+		c.Writer.Write([]byte("chunk"))
+		return nil
+	})
+	app.GET("/seen", func(c *marten.Ctx) error {
+		return c.Text(200, c.Session().Get("seen").(string))
+	})
+	tr := martentest.New(app)
+
+	tr.GET("/stream").Expect(t).Body("chunk").HeaderContains("Set-Cookie", "session=")
+	tr.GET("/seen").Expect(t).Body("yes")
+}
+
+// failingStore loads nothing and fails every save.
+type failingStore struct{}
+
+func (failingStore) Load(context.Context, string) (string, []byte, error) { return "", nil, nil }
+func (failingStore) Save(context.Context, string, []byte, time.Duration) (string, error) {
+	return "", errors.New("store down")
+}
+func (failingStore) Delete(context.Context, string) error { return nil }
+
+func TestSessionSaveErrors(t *testing.T) {
+	var buf bytes.Buffer
+	app := marten.New()
+	app.SetLogger(slog.New(slog.NewJSONHandler(&buf, nil)))
+	app.Use(middleware.Session(middleware.SessionConfig{Store: failingStore{}}))
+	var handlerErr error
+	app.OnError(func(c *marten.Ctx, err error) {
+		handlerErr = err
+		c.Text(500, "failed")
+	})
+	app.GET("/written", func(c *marten.Ctx) error {
+		c.Session().Set("k", "v")
+		return c.Text(200, "ok")
+	})
+	app.GET("/empty", func(c *marten.Ctx) error {
+		c.Session().Set("k", "v")
+		return nil
+	})
+	tr := martentest.New(app)
+
+	tr.GET("/written").Expect(t).Status(200).Body("ok")
+	recs := logRecords(t, &buf)
+	if len(recs) != 1 || recs[0]["msg"] != "session save failed" || recs[0]["error"] != "store down" {
+		t.Errorf("expected the save error logged, got %v", recs)
+	}
+	if handlerErr != nil {
+		t.Errorf("error handler called with %v", handlerErr)
+	}
+
+	buf.Reset()
+	tr.GET("/empty").Expect(t).Status(500)
+	if handlerErr == nil || handlerErr.Error() != "store down" {
+		t.Errorf("expected the save error returned, got %v", handlerErr)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("unexpected log output %s", buf.String())
+	}
+}
+
+func TestSessionTypedValues(t *testing.T) {
+	for name, store := range sessionStores(t) {
+		t.Run(name, func(t *testing.T) {
+			app := marten.New()
+			app.Use(middleware.Session(middleware.SessionConfig{Store: store}))
+			app.GET("/set", func(c *marten.Ctx) error {
+				c.Session().Set("n", 42)
+				c.Session().Set("tags", []string{"a", "b"})
+				return c.NoContent()
+			})
+			app.GET("/get", func(c *marten.Ctx) error {
+				n, _ := c.Session().Get("n").(int)
+				tags, _ := c.Session().Get("tags").([]string)
+				return c.JSON(200, marten.M{"n": n, "tags": tags})
+			})
+			tr := martentest.New(app)
+			tr.GET("/set").Expect(t).Status(204)
+			tr.GET("/get").Expect(t).JSON(marten.M{"n": 42, "tags": []string{"a", "b"}})
+		})
+	}
+}
+
+func TestSessionCookieStoreTooLarge(t *testing.T) {
+	keys, _ := marten.NewKeyring(cookieSecret)
+	app := marten.New()
+	app.Use(middleware.Session(middleware.SessionConfig{Store: middleware.NewCookieStore(keys)}))
+	app.GET("/", func(c *marten.Ctx) error {
+		c.Session().Set("blob", strings.Repeat("x", 5000))
+		return nil
+	})
+	martentest.New(app).GET("/").Expect(t).Status(500)
+}
+
+func TestSessionCookieStoreTampered(t *testing.T) {
+	keys, _ := marten.NewKeyring(cookieSecret)
+	app := sessionApp(middleware.SessionConfig{Store: middleware.NewCookieStore(keys)})
+	tr := martentest.New(app)
+	ck := tr.GET("/login").Expect(t).Result().Cookies()[0]
+
+	ck.Value = flip(ck.Value, len(ck.Value)/2)
+	martentest.New(app).GET("/get?k=user").Cookie(ck).Expect(t).Body("")
+}
+
+func TestMemoryStoreEviction(t *testing.T) {
+	store := middleware.NewMemoryStore(10 * time.Millisecond)
+	defer store.Stop()
+	ctx := context.Background()
+	store.Save(ctx, "a", []byte("1"), 20*time.Millisecond)
+	store.Save(ctx, "b", []byte("2"), time.Hour)
+
+	time.Sleep(60 * time.Millisecond)
+	if store.Len() != 1 {
+		t.Errorf("expired session should be evicted, %d stored", store.Len())
+	}
+	if _, data, _ := store.Load(ctx, "b"); !bytes.Equal(data, []byte("2")) {
+		t.Errorf("got %q", data)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := middleware.NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	id := strings.Repeat("a", 43)
+	expired := strings.Repeat("b", 43)
+	store.Save(ctx, id, []byte("data"), time.Hour)
+	store.Save(ctx, expired, []byte("old"), time.Millisecond)
+
+	if got, data, err := store.Load(ctx, id); err != nil || got != id || string(data) != "data" {
+		t.Errorf("load: %q %q %v", got, data, err)
+	}
+	if _, err := store.Save(ctx, "../escape", []byte("x"), time.Hour); err == nil {
+		t.Error("expected an error for an invalid ID")
+	}
+	if _, data, err := store.Load(ctx, "../"+strings.Repeat("c", 40)); data != nil || err != nil {
+		t.Errorf("invalid IDs should not be loaded: %q %v", data, err)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if err := store.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+	if _, data, _ := store.Load(ctx, expired); data != nil {
+		t.Error("expired session should be removed")
+	}
+	if _, data, _ := store.Load(ctx, id); data == nil {
+		t.Error("live session should be kept")
+	}
+	store.Delete(ctx, id)
+	if _, data, _ := store.Load(ctx, id); data != nil {
+		t.Error("deleted session should be gone")
+	}
+}
+
+func TestSessionWithoutMiddleware(t *testing.T) {
+	app := marten.New()
+	app.GET("/", func(c *marten.Ctx) error {
+		if c.Session() != nil {
+			t.Error("expected nil session without middleware")
+		}
+		return nil
+	})
+	martentest.New(app).GET("/").Expect(t)
+}
@@ -0,0 +1,267 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gomarten/marten"
+	"github.com/gomarten/marten/middleware"
+)
+
+func TestSSESend(t *testing.T) {
+	app := marten.New()
+	app.GET("/events", func(c *marten.Ctx) error {
+		return c.SSE(func(s *marten.EventStream) error {
+			if err := s.Retry(3 * time.Second); err != nil {
+				return err
+			}
+			if err := s.Send("greeting", "1", "hello\nworld"); err != nil {
+				return err
+			}
+			return s.Send("", "2", marten.M{"n": 2})
+		})
+	})
+
+	req := httptest.NewRequest("GET", "/events", nil)
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	if rec.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("expected Cache-Control: no-cache")
+	}
+	// Connection is hop-by-hop and not allowed in HTTP/2
+	if v := rec.Header().Get("Connection"); v != "" {
+		t.Errorf("unexpected Connection header %q", v)
+	}
+	want := "retry: 3000\n\n" +
+		"event: greeting\nid: 1\ndata: hello\ndata: world\n\n" +
+		"id: 2\ndata: {\"n\":2}\n\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("unexpected stream:\n got %q\nwant %q", got, want)
+	}
+	if !rec.Flushed {
+		t.Error("expected stream to be flushed")
+	}
+}
+
+func TestSSELineTerminatorsInData(t *testing.T) {
+	app := marten.New()
+	app.GET("/events", func(c *marten.Ctx) error {
+		return c.SSE(func(s *marten.EventStream) error {
+			if err := s.Send("", "", "a\rb"); err != nil {
+				return err
+			}
+			if err := s.Send("", "", "x\revent: admin\r\ndata: y"); err != nil {
+				return err
+			}
+			return s.Comment("note\rid: 9\r\nretry: 1")
+		})
+	})
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/events", nil))
+	want := "data: a\ndata: b\n\n" +
+		"data: x\ndata: event: admin\ndata: data: y\n\n" +
+		": note\n: id: 9\n: retry: 1\n\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("unexpected stream:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestSSERejectsNewlinesInID(t *testing.T) {
+	app := marten.New()
+	var err error
+	app.GET("/events", func(c *marten.Ctx) error {
+		return c.SSE(func(s *marten.EventStream) error {
+			err = s.Send("x", "1\n2", "data")
+			return nil
+		})
+	})
+	app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/events", nil))
+	if err == nil {
+		t.Error("expected error for id with newline")
+	}
+}
+
+func TestSSELastEventID(t *testing.T) {
+	app := marten.New()
+	var lastID string
+	app.GET("/events", func(c *marten.Ctx) error {
+		return c.SSE(func(s *marten.EventStream) error {
+			lastID = s.LastEventID()
+			return nil
+		})
+	})
+
+	req := httptest.NewRequest("GET", "/events", nil)
+	req.Header.Set("Last-Event-ID", "42")
+	app.ServeHTTP(httptest.NewRecorder(), req)
+
+	if lastID != "42" {
+		t.Errorf("expected Last-Event-ID 42, got %q", lastID)
+	}
+}
+
+func TestSSEHeartbeat(t *testing.T) {
+	app := marten.New()
+	app.GET("/events", func(c *marten.Ctx) error {
+		return c.SSE(func(s *marten.EventStream) error {
+			s.SetHeartbeat(5 * time.Millisecond)
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		})
+	})
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/events", nil))
+
+	if !strings.Contains(rec.Body.String(), ": heartbeat\n\n") {
+		t.Errorf("expected heartbeat comment, got %q", rec.Body.String())
+	}
+}
+
+func TestSSESetHeartbeatConcurrent(t *testing.T) {
+	app := marten.New()
+	app.GET("/events", func(c *marten.Ctx) error {
+		return c.SSE(func(s *marten.EventStream) error {
+			var wg sync.WaitGroup
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					s.SetHeartbeat(time.Duration(i+1) * time.Millisecond)
+				}(i)
+			}
+			wg.Wait()
+			return nil
+		})
+	})
+
+	done := make(chan struct{})
+	go func() {
+		app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/events", nil))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("SetHeartbeat blocked")
+	}
+}
+
+// Run with -race: the heartbeat goroutine and the handler both write.
+func TestSSEContextCarriesValues(t *testing.T) {
+	app := marten.New()
+	app.Use(middleware.RequestID)
+	app.GET("/events", func(c *marten.Ctx) error {
+		return c.SSE(func(s *marten.EventStream) error {
+			s.SetHeartbeat(time.Millisecond)
+			for i := 0; i < 20; i++ {
+				if err := s.Send("", "", marten.RequestIDFromContext(s.Context())); err != nil {
+					return err
+				}
+				time.Sleep(time.Millisecond)
+			}
+			return nil
+		})
+	})
+
+	req := httptest.NewRequest("GET", "/events", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if !strings.Contains(rec.Body.String(), "data: req-1\n") {
+		t.Errorf("expected the request ID in events, got %q", rec.Body.String())
+	}
+}
+
+func TestSSEClientDisconnect(t *testing.T) {
+	app := marten.New()
+	result := make(chan error, 1)
+	app.GET("/events", func(c *marten.Ctx) error {
+		err := c.SSE(func(s *marten.EventStream) error {
+			<-s.Context().Done()
+			return s.Send("late", "", "data")
+		})
+		result <- err
+		return err
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("GET", "/events", nil).WithContext(ctx)
+	go app.ServeHTTP(httptest.NewRecorder(), req)
+	cancel()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("expected nil after disconnect, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler did not notice the disconnect")
+	}
+}
+
+func TestSSEThroughBufferingMiddleware(t *testing.T) {
+	app := marten.New()
+	app.Use(
+		middleware.Compress(middleware.CompressConfig{MinSize: 1}),
+		middleware.ETag,
+		middleware.TimeoutWithConfig(middleware.TimeoutConfig{
+			Timeout: 20 * time.Millisecond,
+			Skip:    func(c *marten.Ctx) bool { return c.RoutePattern() == "/events" },
+		}),
+	)
+	release := make(chan struct{})
+	app.GET("/events", func(c *marten.Ctx) error {
+		return c.SSE(func(s *marten.EventStream) error {
+			if err := s.Send("tick", "1", "first"); err != nil {
+				return err
+			}
+			select {
+			case <-release:
+			case <-s.Context().Done():
+			}
+			return nil
+		})
+	})
+
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/events", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.Header.Get("Content-Encoding") != "" || resp.Header.Get("ETag") != "" {
+		t.Errorf("stream should not be compressed or tagged: %v", resp.Header)
+	}
+
+	// The first event must arrive while the handler is still running,
+	// and after the Timeout middleware's deadline has passed
+	time.Sleep(40 * time.Millisecond)
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatalf("reading stream: %v", err)
+	}
+	if line != "event: tick\n" {
+		t.Errorf("unexpected first line %q", line)
+	}
+}
@@ -0,0 +1,255 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gomarten/marten"
+	"github.com/gomarten/marten/middleware"
+)
+
+func TestTimeoutWritesAfterTimeoutFail(t *testing.T) {
+	app := marten.New()
+	app.Use(middleware.Timeout(20 * time.Millisecond))
+
+	writeErr := make(chan error, 1)
+	app.GET("/slow", func(c *marten.Ctx) error {
+		time.Sleep(60 * time.Millisecond)
+		err := c.Text(200, "too late")
+		writeErr <- err
+		return err
+	})
+
+	req := httptest.NewRequest("GET", "/slow", nil)
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected 504, got %d", rec.Code)
+	}
+	if err := <-writeErr; !errors.Is(err, http.ErrHandlerTimeout) {
+		t.Errorf("expected ErrHandlerTimeout, got %v", err)
+	}
+	if strings.Contains(rec.Body.String(), "too late") {
+		t.Errorf("late write leaked into response: %q", rec.Body.String())
+	}
+}
+
+func TestTimeoutCommitsHeadersAndStore(t *testing.T) {
+	app := marten.New()
+	var seen string
+	app.Use(func(next marten.Handler) marten.Handler {
+		return func(c *marten.Ctx) error {
+			err := next(c)
+			seen = c.GetString("user")
+			return err
+		}
+	})
+	app.Use(middleware.Timeout(time.Second))
+	app.GET("/users/:id", func(c *marten.Ctx) error {
+		c.Set("user", "u"+c.Param("id"))
+		c.Header("X-Custom", "yes")
+		return c.Created(marten.M{"id": c.Param("id")})
+	})
+
+	req := httptest.NewRequest("GET", "/users/7", nil)
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	if rec.Code != 201 {
+		t.Errorf("expected 201, got %d", rec.Code)
+	}
+	if rec.Header().Get("X-Custom") != "yes" {
+		t.Errorf("expected header to be committed")
+	}
+	if !strings.Contains(rec.Body.String(), `"id":"7"`) {
+		t.Errorf("unexpected body %q", rec.Body.String())
+	}
+	if seen != "u7" {
+		t.Errorf("expected stored value to be joined, got %q", seen)
+	}
+}
+
+func TestTimeoutHandlerErrorReachesErrorHandler(t *testing.T) {
+	app := marten.New()
+	app.Use(middleware.Timeout(time.Second))
+	app.GET("/missing", func(c *marten.Ctx) error {
+		return marten.ErrNotFound("no such thing")
+	})
+
+	req := httptest.NewRequest("GET", "/missing", nil)
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	if rec.Code != 404 {
+		t.Errorf("expected 404, got %d", rec.Code)
+	}
+}
+
+func TestTimeoutStreamingBypassesBuffer(t *testing.T) {
+	app := marten.New()
+	app.Use(middleware.TimeoutWithConfig(middleware.TimeoutConfig{
+		Timeout:   time.Second,
+		Streaming: func(c *marten.Ctx) bool { return c.Path() == "/stream" },
+	}))
+	app.GET("/stream", func(c *marten.Ctx) error {
+		if _, ok := c.Context().Deadline(); !ok {
+			t.Error("expected deadline on streaming request")
+		}
+		if _, ok := c.Writer.(http.Flusher); !ok {
+			t.Error("expected direct writer for streaming request")
+		}
+		return c.Text(200, "chunk")
+	})
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/stream", nil))
+	if rec.Body.String() != "chunk" {
+		t.Errorf("unexpected body %q", rec.Body.String())
+	}
+}
+
+func TestTimeoutPanicInHandler(t *testing.T) {
+	app := marten.New()
+	app.Use(middleware.RecoverJSON, middleware.Timeout(time.Second))
+	app.GET("/panic", func(c *marten.Ctx) error {
+		panic("boom")
+	})
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/panic", nil))
+	if rec.Code != 500 || !strings.Contains(rec.Body.String(), "boom") {
+		t.Errorf("expected 500 with the panic value, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestTimeoutRepanics(t *testing.T) {
+	app := marten.New()
+	app.Use(middleware.Timeout(time.Second))
+	app.GET("/panic", func(c *marten.Ctx) error {
+		panic("boom")
+	})
+
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Errorf("expected the handler's panic, got %v", r)
+		}
+	}()
+	app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/panic", nil))
+}
+
+// Run with -race: handlers race the deadline while writing.
+func TestTimeoutConcurrentLoad(t *testing.T) {
+	app := marten.New()
+	app.Use(middleware.Timeout(5 * time.Millisecond))
+	app.GET("/work/:n", func(c *marten.Ctx) error {
+		if c.ParamInt("n")%2 == 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		c.Set("n", c.Param("n"))
+		c.Header("X-N", c.Param("n"))
+		for i := 0; i < 10; i++ {
+			if _, err := c.Writer.Write([]byte("x")); err != nil {
+				return nil
+			}
+		}
+		return nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			app.ServeHTTP(rec, httptest.NewRequest("GET", "/work/"+strconv.Itoa(i), nil))
+			switch rec.Code {
+			case 200:
+				if rec.Body.String() != "xxxxxxxxxx" {
+					t.Errorf("mixed response body %q", rec.Body.String())
+				}
+			case 504:
+				if strings.Contains(rec.Body.String(), "x") && !strings.Contains(rec.Body.String(), "timeout") {
+					t.Errorf("mixed response body %q", rec.Body.String())
+				}
+			default:
+				t.Errorf("unexpected status %d", rec.Code)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestTimeoutIgnoresStreamingRequestHeaders(t *testing.T) {
+	app := marten.New()
+	app.Use(middleware.Timeout(20 * time.Millisecond))
+	app.GET("/slow", func(c *marten.Ctx) error {
+		<-c.Context().Done()
+		return c.JSON(200, marten.M{"late": true})
+	})
+
+	for name, h := range map[string]http.Header{
+		"event-stream": {"Accept": {"text/event-stream"}},
+		"websocket":    {"Connection": {"Upgrade"}, "Upgrade": {"websocket"}},
+	} {
+		req := httptest.NewRequest("GET", "/slow", nil)
+		req.Header = h
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, req)
+		if rec.Code != http.StatusGatewayTimeout {
+			t.Errorf("%s: expected 504, got %d", name, rec.Code)
+		}
+	}
+}
+
+func TestTimeoutSkip(t *testing.T) {
+	app := marten.New()
+	app.Use(middleware.TimeoutWithConfig(middleware.TimeoutConfig{
+		Timeout: 10 * time.Millisecond,
+		Skip:    func(c *marten.Ctx) bool { return c.RoutePattern() == "/events" },
+	}))
+	app.GET("/events", func(c *marten.Ctx) error {
+		if _, ok := c.Context().Deadline(); ok {
+			t.Error("skipped request should have no deadline")
+		}
+		time.Sleep(20 * time.Millisecond)
+		return c.Text(200, "done")
+	})
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/events", nil))
+	if rec.Code != 200 || rec.Body.String() != "done" {
+		t.Errorf("got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestTimeoutPassesEventStreamThrough(t *testing.T) {
+	app := marten.New()
+	app.Use(middleware.Timeout(30 * time.Millisecond))
+	app.GET("/events", func(c *marten.Ctx) error {
+		return c.SSE(func(s *marten.EventStream) error {
+			if err := s.Send("", "1", "first"); err != nil {
+				return err
+			}
+			// The stream keeps its response past the deadline and ends
+			// when the context does.
+			<-s.Context().Done()
+			return s.Context().Err()
+		})
+	})
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/events", nil))
+	if rec.Code != 200 || rec.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %d %v", rec.Code, rec.Header())
+	}
+	if rec.Body.String() != "id: 1\ndata: first\n\n" || !rec.Flushed {
+		t.Errorf("unexpected stream %q (flushed %v)", rec.Body.String(), rec.Flushed)
+	}
+}
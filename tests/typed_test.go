@@ -0,0 +1,151 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gomarten/marten"
+)
+
+type typedCreateUser struct {
+	Org  string `param:"org" json:"-"`
+	Name string `json:"name" validate:"required,min=3"`
+}
+
+type typedUser struct {
+	ID   int    `json:"id"`
+	Org  string `json:"org"`
+	Name string `json:"name"`
+}
+
+func createTypedUser(_ context.Context, req typedCreateUser) (marten.Response[typedUser], error) {
+	return marten.Status(201, typedUser{ID: 1, Org: req.Org, Name: req.Name}), nil
+}
+
+func TestTypedHandler(t *testing.T) {
+	app := marten.New()
+	app.POST("/orgs/:org/users", marten.Typed(createTypedUser))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/orgs/acme/users", strings.NewReader(`{"name":"alice"}`))
+	req.Header.Set("Content-Type", "application/json")
+	app.ServeHTTP(rec, req)
+
+	if rec.Code != 201 {
+		t.Fatalf("expected 201, got %d %s", rec.Code, rec.Body.String())
+	}
+	var u typedUser
+	json.Unmarshal(rec.Body.Bytes(), &u)
+	if u.Org != "acme" || u.Name != "alice" {
+		t.Errorf("unexpected user %+v", u)
+	}
+}
+
+func TestTypedHandlerErrors(t *testing.T) {
+	app := marten.New()
+	app.POST("/orgs/:org/users", marten.Typed(createTypedUser))
+
+	tests := []struct {
+		body string
+		code int
+	}{
+		{`{"name":`, 400},
+		{`{"name":"al"}`, 422},
+		{``, 422}, // body is optional with param fields; name is required
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/orgs/acme/users", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		app.ServeHTTP(rec, req)
+		if rec.Code != tt.code {
+			t.Errorf("%q: expected %d, got %d", tt.body, tt.code, rec.Code)
+		}
+	}
+}
+
+func TestTypedHandlerQueryWithoutBody(t *testing.T) {
+	type listReq struct {
+		Limit int `query:"limit" default:"10"`
+	}
+	app := marten.New()
+	app.GET("/items", marten.Typed(func(_ context.Context, req listReq) ([]int, error) {
+		return make([]int, req.Limit), nil
+	}))
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/items?limit=3", nil))
+	if rec.Code != 200 || strings.TrimSpace(rec.Body.String()) != "[0,0,0]" {
+		t.Errorf("got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestTypedHandlerEmptyRequestAndResponse(t *testing.T) {
+	called := false
+	app := marten.New()
+	app.DELETE("/cache", marten.Typed(func(ctx context.Context, _ struct{}) (struct{}, error) {
+		called = ctx != nil
+		return struct{}{}, nil
+	}))
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("DELETE", "/cache", nil))
+	if rec.Code != 204 || rec.Body.Len() != 0 || !called {
+		t.Errorf("got %d %q called=%v", rec.Code, rec.Body.String(), called)
+	}
+}
+
+func TestTypedHandlerError(t *testing.T) {
+	errGone := errors.New("gone")
+	app := marten.New()
+	app.MapError(errGone, 410)
+	app.GET("/", marten.Typed(func(context.Context, struct{}) (string, error) {
+		return "", errGone
+	}))
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != 410 {
+		t.Errorf("expected 410, got %d", rec.Code)
+	}
+}
+
+func TestTypedResponseHeader(t *testing.T) {
+	app := marten.New()
+	app.POST("/things", marten.Typed(func(context.Context, struct{}) (marten.Response[string], error) {
+		r := marten.Status(202, "queued")
+		r.Header = map[string][]string{"Location": {"/things/1"}}
+		return r, nil
+	}))
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("POST", "/things", nil))
+	if rec.Code != 202 || rec.Header().Get("Location") != "/things/1" || !strings.Contains(rec.Body.String(), "queued") {
+		t.Errorf("got %d %v %s", rec.Code, rec.Header(), rec.Body.String())
+	}
+}
+
+func TestTypedResponseZeroCode(t *testing.T) {
+	app := marten.New()
+	app.GET("/user", marten.Typed(func(context.Context, struct{}) (marten.Response[typedUser], error) {
+		return marten.Response[typedUser]{Body: typedUser{ID: 1, Name: "ann"}}, nil
+	}))
+
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest("GET", "/user", nil))
+	if rec.Code != 200 || !strings.Contains(rec.Body.String(), `"name":"ann"`) {
+		t.Errorf("got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestTypedFunctionWithoutCtx(t *testing.T) {
+	// Typed functions are plain functions and can be tested directly
+	resp, err := createTypedUser(context.Background(), typedCreateUser{Org: "acme", Name: "bob"})
+	if err != nil || resp.Code != 201 || resp.Body.Name != "bob" {
+		t.Errorf("got %+v %v", resp, err)
+	}
+}
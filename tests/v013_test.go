@@ -385,6 +385,7 @@ func TestContextStoreMultipleOperations(t *testing.T) {
 // Test ClientIP with various header combinations
 func TestClientIPPrecedence(t *testing.T) {
 	app := marten.New()
+	app.TrustedProxies([]string{"192.0.2.0/24", "2.2.2.2", "3.3.3.3"})
 	app.GET("/ip", func(c *marten.Ctx) error {
 		return c.Text(200, c.ClientIP())
 	})
@@ -0,0 +1,203 @@
+package tests
+
+import (
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gomarten/marten"
+)
+
+type validateAddress struct {
+	City string `json:"city" validate:"required"`
+	Zip  string `json:"zip" validate:"len=5,numeric"`
+}
+
+type validateUser struct {
+	Name     string            `json:"name" validate:"required,min=3,max=8"`
+	Email    string            `json:"email" validate:"required,email"`
+	Role     string            `json:"role" validate:"oneof=admin member"`
+	ID       string            `json:"id" validate:"omitempty,uuid"`
+	Website  string            `json:"website" validate:"omitempty,url"`
+	Age      int               `json:"age" validate:"gte=18,lt=130"`
+	Nickname *string           `json:"nickname" validate:"min=2"`
+	Tags     []string          `json:"tags" validate:"max=3,dive,alphanum"`
+	Address  validateAddress   `json:"address"`
+	Others   []validateAddress `json:"others" validate:"dive"`
+}
+
+func validUser() validateUser {
+	return validateUser{
+		Name:    "alice",
+		Email:   "alice@example.com",
+		Role:    "admin",
+		ID:      "123e4567-e89b-12d3-a456-426614174000",
+		Website: "https://example.com",
+		Age:     30,
+		Tags:    []string{"go", "web"},
+		Address: validateAddress{City: "Oslo", Zip: "01234"},
+	}
+}
+
+func TestValidateValid(t *testing.T) {
+	u := validUser()
+	if err := marten.Validate(&u); err != nil {
+		t.Errorf("expected valid, got %v", err)
+	}
+}
+
+func TestValidateFieldErrors(t *testing.T) {
+	short := "x"
+	u := validUser()
+	u.Name = "al"
+	u.Email = "not-an-email"
+	u.Role = "guest"
+	u.ID = "nope"
+	u.Age = 12
+	u.Nickname = &short
+	u.Tags = []string{"ok", "b@d"}
+	u.Address.Zip = "12"
+	u.Others = []validateAddress{{City: "", Zip: "12345"}}
+
+	err := marten.Validate(u)
+	var ve marten.ValidationErrors
+	if !errors.As(err, &ve) {
+		t.Fatalf("expected ValidationErrors, got %v", err)
+	}
+
+	want := map[string]string{
+		"name":           "min",
+		"email":          "email",
+		"role":           "oneof",
+		"id":             "uuid",
+		"age":            "gte",
+		"nickname":       "min",
+		"tags[1]":        "alphanum",
+		"address.zip":    "len",
+		"others[0].city": "required",
+	}
+	got := map[string]string{}
+	for _, fe := range ve {
+		got[fe.Field] = fe.Rule
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected errors:\n got  %v\n want %v", got, want)
+	}
+	for _, fe := range ve {
+		if fe.Field == "name" && fe.Message != "must be at least 3 characters" {
+			t.Errorf("unexpected message %q", fe.Message)
+		}
+	}
+}
+
+func TestValidateRequired(t *testing.T) {
+	var u validateUser
+	err := marten.Validate(&u)
+	if err == nil || !strings.Contains(err.Error(), "name is required") {
+		t.Errorf("expected required errors, got %v", err)
+	}
+}
+
+func TestValidateCustomRule(t *testing.T) {
+	marten.RegisterValidation("even", func(v reflect.Value, _ string) bool {
+		return v.Int()%2 == 0
+	})
+	type payload struct {
+		N int `json:"n" validate:"even"`
+	}
+	if err := marten.Validate(payload{N: 2}); err != nil {
+		t.Errorf("expected valid, got %v", err)
+	}
+	err := marten.Validate(payload{N: 3})
+	var ve marten.ValidationErrors
+	if !errors.As(err, &ve) || ve[0].Rule != "even" {
+		t.Errorf("expected custom rule failure, got %v", err)
+	}
+}
+
+func TestValidateMalformedTags(t *testing.T) {
+	type unknownRule struct {
+		Name string `json:"name" validate:"required,shiny"`
+	}
+	type badBound struct {
+		Name string `json:"name" validate:"min=three"`
+	}
+	type nested struct {
+		Inner []badBound `json:"inner" validate:"dive"`
+	}
+	tests := []struct {
+		v    any
+		want string
+	}{
+		{unknownRule{Name: "x"}, `Name: unknown rule "shiny"`},
+		{badBound{Name: "x"}, `Name: rule min needs a number, got "three"`},
+		{nested{Inner: []badBound{{Name: "x"}}}, `Name: rule min needs a number`},
+	}
+	for _, tt := range tests {
+		err := marten.Validate(tt.v)
+		var ve marten.ValidationErrors
+		if err == nil || errors.As(err, &ve) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Validate(%T) = %v, want an error containing %q", tt.v, err, tt.want)
+		}
+	}
+
+	app := marten.New()
+	app.POST("/", func(c *marten.Ctx) error {
+		var v badBound
+		return c.BindValid(&v, nil)
+	})
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"x"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if rec.Code != 500 {
+		t.Errorf("expected 500 for a malformed tag, got %d", rec.Code)
+	}
+}
+
+func TestBindValidReturns422(t *testing.T) {
+	app := marten.New()
+	app.POST("/users", func(c *marten.Ctx) error {
+		var u validateUser
+		if err := c.BindValid(&u, nil); err != nil {
+			return err
+		}
+		return c.Created(u)
+	})
+
+	req := httptest.NewRequest("POST", "/users", strings.NewReader(`{"name":"al","email":"a@b.co","role":"admin","age":20,"address":{"city":"X","zip":"12345"}}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	if rec.Code != 422 {
+		t.Errorf("expected 422, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, `"error":"validation failed"`) || !strings.Contains(body, `"field":"name","rule":"min","param":"3"`) {
+		t.Errorf("unexpected body %s", body)
+	}
+}
+
+func TestBindValid422ProblemDetails(t *testing.T) {
+	app := marten.New()
+	app.SetProblemDetails(true)
+	app.POST("/users", func(c *marten.Ctx) error {
+		var u validateUser
+		return c.BindValid(&u, nil)
+	})
+
+	req := httptest.NewRequest("POST", "/users", strings.NewReader(`{"name":"alice"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+
+	if rec.Code != 422 {
+		t.Errorf("expected 422, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `{"name":"email","reason":"is required"}`) {
+		t.Errorf("expected invalid-params, got %s", rec.Body.String())
+	}
+}
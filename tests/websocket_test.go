@@ -0,0 +1,556 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gomarten/marten"
+	"github.com/gomarten/marten/middleware"
+)
+
+// wsClient is a minimal RFC 6455 client for conformance tests. It can send
+// malformed frames on purpose.
+type wsClient struct {
+	t    *testing.T
+	conn net.Conn
+	br   *bufio.Reader
+	resp *http.Response
+}
+
+type wsTestFrame struct {
+	fin     bool
+	rsv1    bool
+	opcode  int
+	payload []byte
+}
+
+func dialWS(t *testing.T, srv *httptest.Server, path string, header http.Header) *wsClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	key := make([]byte, 16)
+	_, _ = rand.Read(key)
+	req, _ := http.NewRequest("GET", srv.URL+path, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", base64.StdEncoding.EncodeToString(key))
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &wsClient{t: t, conn: conn, br: br, resp: resp}
+}
+
+func (c *wsClient) writeFrame(f wsTestFrame) {
+	c.t.Helper()
+	b0 := byte(f.opcode)
+	if f.fin {
+		b0 |= 0x80
+	}
+	if f.rsv1 {
+		b0 |= 0x40
+	}
+	buf := []byte{b0}
+	switch n := len(f.payload); {
+	case n <= 125:
+		buf = append(buf, 0x80|byte(n))
+	case n <= 0xffff:
+		buf = append(buf, 0x80|126)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(n))
+	default:
+		buf = append(buf, 0x80|127)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(n))
+	}
+	mask := [4]byte{1, 2, 3, 4}
+	buf = append(buf, mask[:]...)
+	for i, b := range f.payload {
+		buf = append(buf, b^mask[i&3])
+	}
+	if _, err := c.conn.Write(buf); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *wsClient) send(opcode int, payload string) {
+	c.t.Helper()
+	c.writeFrame(wsTestFrame{fin: true, opcode: opcode, payload: []byte(payload)})
+}
+
+func (c *wsClient) readFrame() wsTestFrame {
+	c.t.Helper()
+	var hdr [2]byte
+	if _, err := io.ReadFull(c.br, hdr[:]); err != nil {
+		c.t.Fatalf("reading frame: %v", err)
+	}
+	if hdr[1]&0x80 != 0 {
+		c.t.Fatal("server frames must not be masked")
+	}
+	n := int(hdr[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		_, _ = io.ReadFull(c.br, ext[:])
+		n = int(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		_, _ = io.ReadFull(c.br, ext[:])
+		n = int(binary.BigEndian.Uint64(ext[:]))
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		c.t.Fatalf("reading payload: %v", err)
+	}
+	return wsTestFrame{fin: hdr[0]&0x80 != 0, rsv1: hdr[0]&0x40 != 0, opcode: int(hdr[0] & 0x0f), payload: payload}
+}
+
+// expectClose reads frames until a close frame and checks its code.
+func (c *wsClient) expectClose(code int) {
+	c.t.Helper()
+	for {
+		f := c.readFrame()
+		if f.opcode != marten.CloseMessage {
+			continue
+		}
+		got := marten.CloseNoStatus
+		if len(f.payload) >= 2 {
+			got = int(binary.BigEndian.Uint16(f.payload))
+		}
+		if got != code {
+			c.t.Fatalf("expected close %d, got %d (%q)", code, got, f.payload)
+		}
+		return
+	}
+}
+
+func closePayload(code int, reason string) string {
+	return string(binary.BigEndian.AppendUint16(nil, uint16(code))) + reason
+}
+
+func newEchoServer(t *testing.T, opts marten.UpgradeOptions) (*marten.App, *httptest.Server) {
+	t.Helper()
+	app := marten.New()
+	app.GET("/ws", func(c *marten.Ctx) error {
+		ws, err := c.Upgrade(opts)
+		if err != nil {
+			return err
+		}
+		defer ws.Close()
+		for {
+			typ, msg, err := ws.ReadMessage()
+			if err != nil {
+				return nil
+			}
+			if err := ws.WriteMessage(typ, msg); err != nil {
+				return nil
+			}
+		}
+	})
+	srv := httptest.NewServer(app)
+	t.Cleanup(srv.Close)
+	return app, srv
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+	_, srv := newEchoServer(t, marten.UpgradeOptions{Subprotocols: []string{"v2", "v1"}})
+
+	c := dialWS(t, srv, "/ws", http.Header{"Sec-Websocket-Protocol": {"v1, v2"}})
+	if c.resp.StatusCode != 101 {
+		t.Fatalf("expected 101, got %d", c.resp.StatusCode)
+	}
+	if got := c.resp.Header.Get("Sec-WebSocket-Protocol"); got != "v2" {
+		t.Errorf("expected subprotocol v2, got %q", got)
+	}
+	if c.resp.Header.Get("Sec-WebSocket-Accept") == "" {
+		t.Error("missing Sec-WebSocket-Accept")
+	}
+}
+
+func TestWebSocketHandshakeRejections(t *testing.T) {
+	_, srv := newEchoServer(t, marten.UpgradeOptions{})
+
+	tests := []struct {
+		name   string
+		header http.Header
+		code   int
+	}{
+		{"cross origin", http.Header{"Origin": {"https://evil.example"}}, 403},
+		{"bad version", http.Header{"Sec-Websocket-Version": {"8"}}, 426},
+		{"bad key", http.Header{"Sec-Websocket-Key": {"short"}}, 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := dialWS(t, srv, "/ws", tt.header)
+			if c.resp.StatusCode != tt.code {
+				t.Errorf("expected %d, got %d", tt.code, c.resp.StatusCode)
+			}
+		})
+	}
+
+	resp, err := http.Get(srv.URL + "/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 400 {
+		t.Errorf("plain GET: expected 400, got %d", resp.StatusCode)
+	}
+}
+
+// TestWebSocketConformance follows the sections of the Autobahn test suite.
+func TestWebSocketConformance(t *testing.T) {
+	_, srv := newEchoServer(t, marten.UpgradeOptions{ReadLimit: 1 << 20})
+	big := strings.Repeat("*", 65536)
+
+	tests := []struct {
+		name string
+		run  func(c *wsClient)
+	}{
+		// 1.x framing
+		{"1.1 text payloads", func(c *wsClient) {
+			for _, p := range []string{"", "hello", big[:125], big[:126], big[:65535], big} {
+				c.send(marten.TextMessage, p)
+				if f := c.readFrame(); f.opcode != marten.TextMessage || string(f.payload) != p {
+					t.Fatalf("echo of %d bytes failed", len(p))
+				}
+			}
+		}},
+		{"1.2 binary payload", func(c *wsClient) {
+			c.send(marten.BinaryMessage, "\x00\xff\xfe")
+			if f := c.readFrame(); f.opcode != marten.BinaryMessage || string(f.payload) != "\x00\xff\xfe" {
+				t.Fatalf("unexpected echo %+v", f)
+			}
+		}},
+		// 2.x ping/pong
+		{"2.1 ping gets pong", func(c *wsClient) {
+			c.send(marten.PingMessage, "are you there")
+			if f := c.readFrame(); f.opcode != marten.PongMessage || string(f.payload) != "are you there" {
+				t.Fatalf("unexpected reply %+v", f)
+			}
+		}},
+		{"2.2 unsolicited pong is ignored", func(c *wsClient) {
+			c.send(marten.PongMessage, "x")
+			c.send(marten.TextMessage, "after")
+			if f := c.readFrame(); string(f.payload) != "after" {
+				t.Fatalf("unexpected reply %+v", f)
+			}
+		}},
+		{"2.3 oversized ping", func(c *wsClient) {
+			c.send(marten.PingMessage, big[:126])
+			c.expectClose(marten.CloseProtocolError)
+		}},
+		// 3.x reserved bits
+		{"3.1 reserved bit", func(c *wsClient) {
+			c.writeFrame(wsTestFrame{fin: true, rsv1: true, opcode: marten.TextMessage, payload: []byte("x")})
+			c.expectClose(marten.CloseProtocolError)
+		}},
+		// 4.x opcodes
+		{"4.1 reserved data opcode", func(c *wsClient) {
+			c.send(3, "")
+			c.expectClose(marten.CloseProtocolError)
+		}},
+		{"4.2 reserved control opcode", func(c *wsClient) {
+			c.send(11, "")
+			c.expectClose(marten.CloseProtocolError)
+		}},
+		// 5.x fragmentation
+		{"5.1 fragmented text with ping in between", func(c *wsClient) {
+			c.writeFrame(wsTestFrame{opcode: marten.TextMessage, payload: []byte("frag")})
+			c.send(marten.PingMessage, "p")
+			c.writeFrame(wsTestFrame{opcode: 0, payload: []byte("men")})
+			c.writeFrame(wsTestFrame{fin: true, opcode: 0, payload: []byte("ted")})
+			if f := c.readFrame(); f.opcode != marten.PongMessage {
+				t.Fatalf("expected pong first, got %+v", f)
+			}
+			if f := c.readFrame(); string(f.payload) != "fragmented" {
+				t.Fatalf("unexpected echo %q", f.payload)
+			}
+		}},
+		{"5.2 continuation without start", func(c *wsClient) {
+			c.writeFrame(wsTestFrame{fin: true, opcode: 0, payload: []byte("x")})
+			c.expectClose(marten.CloseProtocolError)
+		}},
+		{"5.3 new message during fragmented message", func(c *wsClient) {
+			c.writeFrame(wsTestFrame{opcode: marten.TextMessage, payload: []byte("a")})
+			c.send(marten.TextMessage, "b")
+			c.expectClose(marten.CloseProtocolError)
+		}},
+		{"5.4 fragmented ping", func(c *wsClient) {
+			c.writeFrame(wsTestFrame{opcode: marten.PingMessage, payload: []byte("a")})
+			c.expectClose(marten.CloseProtocolError)
+		}},
+		// 6.x UTF-8
+		{"6.1 multi-byte character split across fragments", func(c *wsClient) {
+			c.writeFrame(wsTestFrame{opcode: marten.TextMessage, payload: []byte("\xce")})
+			c.writeFrame(wsTestFrame{fin: true, opcode: 0, payload: []byte("\xba")})
+			if f := c.readFrame(); string(f.payload) != "κ" {
+				t.Fatalf("unexpected echo %q", f.payload)
+			}
+		}},
+		{"6.2 invalid UTF-8", func(c *wsClient) {
+			c.send(marten.TextMessage, "\xce\xba\xe1\xbd")
+			c.expectClose(marten.CloseInvalidPayload)
+		}},
+		// 7.x close handling
+		{"7.1 close is echoed", func(c *wsClient) {
+			c.send(marten.CloseMessage, closePayload(marten.CloseNormal, "bye"))
+			c.expectClose(marten.CloseNormal)
+		}},
+		{"7.2 empty close", func(c *wsClient) {
+			c.send(marten.CloseMessage, "")
+			f := c.readFrame()
+			if f.opcode != marten.CloseMessage || len(f.payload) != 0 {
+				t.Fatalf("expected empty close, got %+v", f)
+			}
+		}},
+		{"7.3 one-byte close payload", func(c *wsClient) {
+			c.send(marten.CloseMessage, "\x03")
+			c.expectClose(marten.CloseProtocolError)
+		}},
+		{"7.4 invalid close code", func(c *wsClient) {
+			c.send(marten.CloseMessage, closePayload(999, ""))
+			c.expectClose(marten.CloseProtocolError)
+		}},
+		{"7.5 application close code", func(c *wsClient) {
+			c.send(marten.CloseMessage, closePayload(4000, ""))
+			c.expectClose(4000)
+		}},
+		{"7.6 invalid UTF-8 close reason", func(c *wsClient) {
+			c.send(marten.CloseMessage, closePayload(marten.CloseNormal, "\xff"))
+			c.expectClose(marten.CloseInvalidPayload)
+		}},
+		{"7.7 connection closed after close", func(c *wsClient) {
+			c.send(marten.CloseMessage, closePayload(marten.CloseNormal, ""))
+			c.expectClose(marten.CloseNormal)
+			if _, err := c.br.ReadByte(); err != io.EOF {
+				t.Fatalf("expected EOF after close, got %v", err)
+			}
+		}},
+		// 9.x limits
+		{"9.1 message over read limit", func(c *wsClient) {
+			c.writeFrame(wsTestFrame{opcode: marten.BinaryMessage, payload: make([]byte, 1<<19)})
+			c.writeFrame(wsTestFrame{fin: true, opcode: 0, payload: make([]byte, 1<<19+1)})
+			c.expectClose(marten.CloseMessageTooBig)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := dialWS(t, srv, "/ws", nil)
+			if c.resp.StatusCode != 101 {
+				t.Fatalf("expected 101, got %d", c.resp.StatusCode)
+			}
+			tt.run(c)
+		})
+	}
+}
+
+func deflateBytes(t *testing.T, p []byte) []byte {
+	var buf bytes.Buffer
+	fw, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	fw.Write(p)
+	fw.Flush()
+	return bytes.TrimSuffix(buf.Bytes(), []byte{0, 0, 0xff, 0xff})
+}
+
+func inflateBytes(t *testing.T, p []byte) []byte {
+	t.Helper()
+	r := flate.NewReader(io.MultiReader(bytes.NewReader(p), bytes.NewReader([]byte{0, 0, 0xff, 0xff, 1, 0, 0, 0xff, 0xff})))
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("inflate: %v", err)
+	}
+	return out
+}
+
+func TestWebSocketCompression(t *testing.T) {
+	_, srv := newEchoServer(t, marten.UpgradeOptions{Compression: true})
+
+	c := dialWS(t, srv, "/ws", http.Header{"Sec-Websocket-Extensions": {"permessage-deflate; client_max_window_bits"}})
+	if ext := c.resp.Header.Get("Sec-WebSocket-Extensions"); !strings.HasPrefix(ext, "permessage-deflate") {
+		t.Fatalf("expected permessage-deflate, got %q", ext)
+	}
+
+	msg := strings.Repeat("compress me ", 1000)
+	c.writeFrame(wsTestFrame{fin: true, rsv1: true, opcode: marten.TextMessage, payload: deflateBytes(t, []byte(msg))})
+	f := c.readFrame()
+	if !f.rsv1 {
+		t.Fatal("expected compressed reply")
+	}
+	if got := string(inflateBytes(t, f.payload)); got != msg {
+		t.Errorf("unexpected echo of %d bytes", len(got))
+	}
+
+	// Uncompressed messages are still accepted
+	c.send(marten.TextMessage, "plain")
+	if got := string(inflateBytes(t, c.readFrame().payload)); got != "plain" {
+		t.Errorf("unexpected echo %q", got)
+	}
+
+	// Unsupported parameters decline the extension
+	c2 := dialWS(t, srv, "/ws", http.Header{"Sec-Websocket-Extensions": {"permessage-deflate; server_max_window_bits=10"}})
+	if ext := c2.resp.Header.Get("Sec-WebSocket-Extensions"); ext != "" {
+		t.Errorf("expected extension to be declined, got %q", ext)
+	}
+}
+
+func TestWebSocketFragmentedWrite(t *testing.T) {
+	app := marten.New()
+	app.GET("/ws", func(c *marten.Ctx) error {
+		ws, err := c.Upgrade(marten.UpgradeOptions{})
+		if err != nil {
+			return err
+		}
+		defer ws.Close()
+		w, _ := ws.NextWriter(marten.TextMessage)
+		io.WriteString(w, "hello ")
+		io.WriteString(w, "world")
+		return w.Close()
+	})
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	c := dialWS(t, srv, "/ws", nil)
+	var frames []wsTestFrame
+	for {
+		f := c.readFrame()
+		frames = append(frames, f)
+		if f.fin {
+			break
+		}
+	}
+	if len(frames) != 3 || frames[0].opcode != marten.TextMessage || frames[1].opcode != 0 {
+		t.Fatalf("unexpected frames %+v", frames)
+	}
+	var msg string
+	for _, f := range frames {
+		msg += string(f.payload)
+	}
+	if msg != "hello world" {
+		t.Errorf("unexpected message %q", msg)
+	}
+	c.send(marten.CloseMessage, closePayload(marten.CloseNormal, ""))
+}
+
+func TestWebSocketServerClose(t *testing.T) {
+	app := marten.New()
+	result := make(chan error, 1)
+	app.GET("/ws", func(c *marten.Ctx) error {
+		ws, err := c.Upgrade(marten.UpgradeOptions{})
+		if err != nil {
+			return err
+		}
+		result <- ws.CloseWithCode(marten.ClosePolicyViolation, "go away")
+		return nil
+	})
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	c := dialWS(t, srv, "/ws", nil)
+	f := c.readFrame()
+	if f.opcode != marten.CloseMessage || string(f.payload[2:]) != "go away" {
+		t.Fatalf("unexpected frame %+v", f)
+	}
+	c.send(marten.CloseMessage, string(f.payload[:2]))
+	if err := <-result; err != nil {
+		t.Errorf("unexpected close error: %v", err)
+	}
+}
+
+func TestWebSocketCloseOnShutdown(t *testing.T) {
+	app, srv := newEchoServer(t, marten.UpgradeOptions{})
+	c := dialWS(t, srv, "/ws", nil)
+	c.send(marten.TextMessage, "hi")
+	c.readFrame()
+
+	done := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		done <- app.CloseWebSockets(ctx)
+	}()
+
+	c.expectClose(marten.CloseGoingAway)
+	c.send(marten.CloseMessage, closePayload(marten.CloseGoingAway, ""))
+	if err := <-done; err != nil {
+		t.Errorf("CloseWebSockets: %v", err)
+	}
+}
+
+func TestWebSocketThroughMiddleware(t *testing.T) {
+	app := marten.New()
+	app.Use(
+		middleware.Compress(middleware.DefaultCompressConfig()),
+		middleware.ETag,
+		middleware.TimeoutWithConfig(middleware.TimeoutConfig{
+			Timeout: 50 * time.Millisecond,
+			Skip:    func(c *marten.Ctx) bool { return c.RoutePattern() == "/ws" },
+		}),
+	)
+	app.GET("/ws", func(c *marten.Ctx) error {
+		ws, err := c.Upgrade(marten.UpgradeOptions{})
+		if err != nil {
+			return err
+		}
+		defer ws.Close()
+		time.Sleep(100 * time.Millisecond)
+		return ws.WriteMessage(marten.TextMessage, []byte("late"))
+	})
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	c := dialWS(t, srv, "/ws", http.Header{"Accept-Encoding": {"gzip"}})
+	if c.resp.StatusCode != 101 {
+		t.Fatalf("expected 101, got %d", c.resp.StatusCode)
+	}
+	if f := c.readFrame(); string(f.payload) != "late" {
+		t.Errorf("unexpected frame %+v", f)
+	}
+	c.send(marten.CloseMessage, "")
+}
+
+func TestWebSocketBehindDefaultTimeout(t *testing.T) {
+	app := marten.New()
+	app.Use(middleware.Timeout(time.Second))
+	app.GET("/ws", func(c *marten.Ctx) error {
+		ws, err := c.Upgrade(marten.UpgradeOptions{})
+		if err != nil {
+			return err
+		}
+		defer ws.Close()
+		return ws.WriteMessage(marten.TextMessage, []byte("hi"))
+	})
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	c := dialWS(t, srv, "/ws", nil)
+	if c.resp.StatusCode != 101 {
+		t.Fatalf("expected 101, got %d", c.resp.StatusCode)
+	}
+	if f := c.readFrame(); string(f.payload) != "hi" {
+		t.Errorf("unexpected frame %+v", f)
+	}
+}
@@ -0,0 +1,140 @@
+package marten
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+)
+
+// Response is a typed handler result with an explicit status code and
+// optional headers. Create one with Status; a zero Code means 200 OK.
+type Response[T any] struct {
+	Code   int
+	Body   T
+	Header http.Header
+}
+
+// Status returns a Response that is written with the given status code.
+//
+//	return marten.Status(http.StatusCreated, user), nil
+func Status[T any](code int, v T) Response[T] {
+	return Response[T]{Code: code, Body: v}
+}
+
+func (r Response[T]) response() (int, any, http.Header) {
+	if r.Code == 0 {
+		return http.StatusOK, r.Body, r.Header
+	}
+	return r.Code, r.Body, r.Header
+}
+
+func (Response[T]) bodyType() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// typedResponse is implemented by Response.
+type typedResponse interface {
+	response() (int, any, http.Header)
+	bodyType() reflect.Type
+}
+
+// Typed adapts a context-based function to a Handler. The request is bound
+// into Req from the path, query, headers, cookies and body (see Bind), then
+// checked with Validate. The result is written as JSON with 200 OK, or with
+// the status of a Response returned by Status; an empty struct result
+// responds with 204 No Content. Errors go to the app's error handler, so bind
+// failures respond with 400 and validation failures with 422.
+//
+// The body is optional for GET, HEAD, DELETE and OPTIONS requests.
+//
+//	func createUser(ctx context.Context, req CreateUser) (marten.Response[User], error) {
+//		u, err := store.Create(ctx, req.Name)
+//		return marten.Status(http.StatusCreated, u), err
+//	}
+//
+//	app.POST("/users", marten.Typed(createUser))
+func Typed[Req, Resp any](fn func(context.Context, Req) (Resp, error)) Handler {
+	return func(c *Ctx) error {
+		var req Req
+		if bindable(reflect.TypeOf(req)) {
+			if err := c.bind(&req, !methodHasBody(c.Request.Method)); err != nil {
+				return err
+			}
+			if err := Validate(&req); err != nil {
+				return err
+			}
+		}
+
+		resp, err := fn(c.Context(), req)
+		if err != nil {
+			return err
+		}
+
+		code, body := http.StatusOK, any(resp)
+		if r, ok := body.(typedResponse); ok {
+			var header http.Header
+			code, body, header = r.response()
+			for k, v := range header {
+				c.Writer.Header()[k] = v
+			}
+		}
+		if code == http.StatusNoContent || isEmptyStruct(reflect.TypeOf(body)) {
+			if code == http.StatusOK {
+				code = http.StatusNoContent
+			}
+			c.Status(code)
+			return nil
+		}
+		return c.JSON(code, body)
+	}
+}
+
+// Registrar registers routes. App, Router and Group implement it.
+type Registrar interface {
+	Handle(method, path string, h Handler, mw ...Middleware) *RouteInfo
+}
+
+// TypedRoute registers Typed(fn) on r and documents fn's request and
+// response types in the OpenAPI document.
+//
+//	marten.TypedRoute(app, "POST", "/users", createUser).Doc(marten.Operation{Status: 201})
+func TypedRoute[Req, Resp any](r Registrar, method, path string, fn func(context.Context, Req) (Resp, error), mw ...Middleware) *RouteInfo {
+	info := r.Handle(method, path, Typed(fn), mw...)
+	info.typed = &typedInfo{
+		request:  reflect.TypeOf((*Req)(nil)).Elem(),
+		response: responseType(reflect.TypeOf((*Resp)(nil)).Elem()),
+	}
+	return info
+}
+
+// typedInfo describes the request and response body types of a typed handler.
+type typedInfo struct {
+	request  reflect.Type
+	response reflect.Type
+}
+
+// responseType unwraps Response[T] to T.
+func responseType(t reflect.Type) reflect.Type {
+	if t.Implements(reflect.TypeOf((*typedResponse)(nil)).Elem()) {
+		return reflect.Zero(t).Interface().(typedResponse).bodyType()
+	}
+	return t
+}
+
+// bindable reports whether a typed handler binds its request into t.
+func bindable(t reflect.Type) bool {
+	return t != nil && !isEmptyStruct(t)
+}
+
+func isEmptyStruct(t reflect.Type) bool {
+	return t != nil && t.Kind() == reflect.Struct && t.NumField() == 0
+}
+
+// methodHasBody reports whether requests with method usually carry a body.
+func methodHasBody(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete, http.MethodOptions:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,422 @@
+package marten
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ValidationFunc reports whether v satisfies a rule with the given parameter
+// (the text after "=" in the tag, or "").
+type ValidationFunc func(v reflect.Value, param string) bool
+
+// ValidationError describes a single failed rule.
+type ValidationError struct {
+	Field   string `json:"field"` // path using JSON names, e.g. "items[0].name"
+	Rule    string `json:"rule"`  // rule name, e.g. "min"
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// ValidationErrors is returned by Validate when one or more rules fail.
+// The default error handler responds to it with 422 Unprocessable Entity.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+var (
+	validatorsMu sync.RWMutex
+	validators   = map[string]ValidationFunc{}
+
+	emailRegex    = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	uuidRegex     = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	numericRegex  = regexp.MustCompile(`^[-+]?[0-9]+(\.[0-9]+)?$`)
+	validateCache sync.Map // reflect.Type -> []validateField
+)
+
+// RegisterValidation registers a custom rule usable in validate tags.
+// It replaces any built-in or previously registered rule with the same name.
+//
+//	marten.RegisterValidation("slug", func(v reflect.Value, _ string) bool {
+//		return slugRe.MatchString(v.String())
+//	})
+func RegisterValidation(name string, fn ValidationFunc) {
+	validatorsMu.Lock()
+	defer validatorsMu.Unlock()
+	validators[name] = fn
+}
+
+// Validate checks v (a struct or pointer to struct) against its validate tags.
+// It returns ValidationErrors when any rule fails, or another error if a tag
+// is malformed (an unknown rule or a non-numeric bound).
+//
+// Supported rules: required, omitempty, min, max, len, eq, ne, gt, gte, lt,
+// lte, oneof, email, url, uuid, alpha, alphanum, numeric and dive. min, max,
+// len, gt, gte, lt and lte compare the length of strings, slices and maps and
+// the value of numbers. dive applies the following rules to each element of a
+// slice, array or map. Nested structs are validated recursively.
+//
+//	type CreateUser struct {
+//		Name  string   `json:"name" validate:"required,min=3,max=64"`
+//		Email string   `json:"email" validate:"required,email"`
+//		Role  string   `json:"role" validate:"oneof=admin member"`
+//		Tags  []string `json:"tags" validate:"max=5,dive,min=2"`
+//	}
+func Validate(v any) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	var errs ValidationErrors
+	if err := validateStruct(rv, "", &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validateField is a struct field with parsed rules.
+type validateField struct {
+	index int
+	name  string
+	rules []rule
+}
+
+type rule struct {
+	name  string
+	param string
+	limit float64 // numeric param of min, max, len, gt, gte, lt and lte
+}
+
+func validateStruct(rv reflect.Value, prefix string, errs *ValidationErrors) error {
+	fields, err := validateFields(rv.Type())
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if err := validateValue(rv.Field(f.index), prefix+f.name, f.rules, errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateValue applies rules to v, then recurses into nested structs.
+func validateValue(v reflect.Value, path string, rules []rule, errs *ValidationErrors) error {
+	for i, r := range rules {
+		switch r.name {
+		case "omitempty":
+			if isEmpty(v) {
+				return nil
+			}
+			continue
+		case "dive":
+			return diveInto(v, path, rules[i+1:], errs)
+		}
+		if r.name != "required" && isEmpty(v) && v.Kind() == reflect.Pointer {
+			// Nil pointers only fail "required"
+			continue
+		}
+		if !checkRule(v, r) {
+			*errs = append(*errs, ValidationError{Field: path, Rule: r.name, Param: r.param, Message: ruleMessage(v, r)})
+			return nil
+		}
+	}
+
+	// Recurse into nested structs
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct && !isLeafType(v.Type()) {
+		if path != "" && !strings.HasSuffix(path, ".") {
+			path += "."
+		}
+		return validateStruct(v, path, errs)
+	}
+	return nil
+}
+
+// diveInto applies rules to each element of a slice, array or map.
+func diveInto(v reflect.Value, path string, rules []rule, errs *ValidationErrors) error {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), rules, errs); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, k := range keys {
+			if err := validateValue(v.MapIndex(k), fmt.Sprintf("%s[%v]", path, k.Interface()), rules, errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkRule runs a single rule against v. Rules were checked by
+// compileRules, so every name is known.
+func checkRule(v reflect.Value, r rule) bool {
+	validatorsMu.RLock()
+	fn, ok := validators[r.name]
+	validatorsMu.RUnlock()
+	if ok {
+		return fn(v, r.param)
+	}
+
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return r.name != "required"
+		}
+		v = v.Elem()
+	}
+
+	switch r.name {
+	case "required":
+		return !isEmpty(v)
+	case "min", "gte":
+		return compare(v, r.limit, func(a, b float64) bool { return a >= b })
+	case "max", "lte":
+		return compare(v, r.limit, func(a, b float64) bool { return a <= b })
+	case "gt":
+		return compare(v, r.limit, func(a, b float64) bool { return a > b })
+	case "lt":
+		return compare(v, r.limit, func(a, b float64) bool { return a < b })
+	case "len":
+		return compare(v, r.limit, func(a, b float64) bool { return a == b })
+	case "eq":
+		return fmt.Sprint(v.Interface()) == r.param
+	case "ne":
+		return fmt.Sprint(v.Interface()) != r.param
+	case "oneof":
+		s := fmt.Sprint(v.Interface())
+		for _, opt := range strings.Fields(r.param) {
+			if s == opt {
+				return true
+			}
+		}
+		return false
+	case "email":
+		s := v.String()
+		if !emailRegex.MatchString(s) {
+			return false
+		}
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Address == s
+	case "url":
+		u, err := url.ParseRequestURI(v.String())
+		return err == nil && u.Scheme != "" && u.Host != ""
+	case "uuid":
+		return uuidRegex.MatchString(v.String())
+	case "alpha":
+		return v.String() != "" && strings.IndexFunc(v.String(), func(r rune) bool { return !unicode.IsLetter(r) }) < 0
+	case "alphanum":
+		return v.String() != "" && strings.IndexFunc(v.String(), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) < 0
+	case "numeric":
+		return numericRegex.MatchString(v.String())
+	}
+	return false
+}
+
+// compare checks the size of v (length or numeric value) against limit.
+func compare(v reflect.Value, limit float64, ok func(a, b float64) bool) bool {
+	switch v.Kind() {
+	case reflect.String:
+		return ok(float64(utf8.RuneCountInString(v.String())), limit)
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return ok(float64(v.Len()), limit)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return ok(float64(v.Int()), limit)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return ok(float64(v.Uint()), limit)
+	case reflect.Float32, reflect.Float64:
+		return ok(v.Float(), limit)
+	}
+	return false
+}
+
+// isEmpty reports whether v is the zero value (or an empty collection).
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.String, reflect.Array:
+		return v.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	}
+	return v.IsZero()
+}
+
+// ruleMessage returns a human-readable message for a failed rule.
+func ruleMessage(v reflect.Value, r rule) string {
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	unit := ""
+	switch v.Kind() {
+	case reflect.String:
+		unit = " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		unit = " items"
+	}
+
+	switch r.name {
+	case "required":
+		return "is required"
+	case "min", "gte":
+		return "must be at least " + r.param + unit
+	case "max", "lte":
+		return "must be at most " + r.param + unit
+	case "gt":
+		return "must be greater than " + r.param + unit
+	case "lt":
+		return "must be less than " + r.param + unit
+	case "len":
+		return "must be exactly " + r.param + unit
+	case "eq":
+		return "must be " + r.param
+	case "ne":
+		return "must not be " + r.param
+	case "oneof":
+		return "must be one of: " + strings.Join(strings.Fields(r.param), ", ")
+	case "email":
+		return "must be a valid email address"
+	case "url":
+		return "must be a valid URL"
+	case "uuid":
+		return "must be a valid UUID"
+	case "alpha":
+		return "must contain only letters"
+	case "alphanum":
+		return "must contain only letters and digits"
+	case "numeric":
+		return "must be numeric"
+	}
+	return "failed the " + r.name + " rule"
+}
+
+// validateFields returns the fields of t that carry rules or may nest
+// structs. Their rules are checked once, before t is cached.
+func validateFields(t reflect.Type) ([]validateField, error) {
+	if cached, ok := validateCache.Load(t); ok {
+		return cached.([]validateField), nil
+	}
+	var fields []validateField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		tag := sf.Tag.Get("validate")
+		if tag == "-" {
+			continue
+		}
+		name := sf.Name
+		if jsonName, _, _ := strings.Cut(sf.Tag.Get("json"), ","); jsonName != "" && jsonName != "-" {
+			name = jsonName
+		}
+		rules := parseRules(tag)
+		if rules == nil && !mayNest(sf.Type) {
+			continue
+		}
+		if err := compileRules(rules); err != nil {
+			return nil, fmt.Errorf("marten: validate tag of %s.%s: %w", t, sf.Name, err)
+		}
+		if sf.Anonymous && rules == nil {
+			name = ""
+		}
+		fields = append(fields, validateField{index: i, name: name, rules: rules})
+	}
+	validateCache.Store(t, fields)
+	return fields, nil
+}
+
+// parseRules parses a validate tag into rules.
+func parseRules(tag string) []rule {
+	var rules []rule
+	for _, part := range strings.Split(tag, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		n, p, _ := strings.Cut(part, "=")
+		rules = append(rules, rule{name: n, param: p})
+	}
+	return rules
+}
+
+// compileRules checks that every rule is known and parses numeric
+// parameters into rule.limit.
+func compileRules(rules []rule) error {
+	for i := range rules {
+		r := &rules[i]
+		validatorsMu.RLock()
+		_, custom := validators[r.name]
+		validatorsMu.RUnlock()
+		if custom {
+			continue
+		}
+		switch r.name {
+		case "min", "max", "len", "gt", "gte", "lt", "lte":
+			n, err := strconv.ParseFloat(r.param, 64)
+			if err != nil {
+				return fmt.Errorf("rule %s needs a number, got %q", r.name, r.param)
+			}
+			r.limit = n
+		case "required", "omitempty", "dive", "eq", "ne", "oneof", "email", "url", "uuid",
+			"alpha", "alphanum", "numeric":
+		default:
+			return fmt.Errorf("unknown rule %q", r.name)
+		}
+	}
+	return nil
+}
+
+// mayNest reports whether values of t can contain structs to validate.
+func mayNest(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && !isLeafType(t)
+}
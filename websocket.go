@@ -0,0 +1,822 @@
+package marten
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// WebSocket message types (RFC 6455 opcodes).
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+
+	continuationFrame = 0
+)
+
+// WebSocket close codes (RFC 6455 Section 7.4.1).
+const (
+	CloseNormal             = 1000
+	CloseGoingAway          = 1001
+	CloseProtocolError      = 1002
+	CloseUnsupportedData    = 1003
+	CloseNoStatus           = 1005
+	CloseAbnormal           = 1006
+	CloseInvalidPayload     = 1007
+	ClosePolicyViolation    = 1008
+	CloseMessageTooBig      = 1009
+	CloseMandatoryExtension = 1010
+	CloseInternalError      = 1011
+)
+
+const (
+	websocketGUID      = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	defaultReadLimit   = 32 << 20 // 32MB
+	closeTimeout       = 5 * time.Second
+	maxControlPayload  = 125
+	deflateFrameLength = 4096
+)
+
+// deflateTail is the sync flush marker stripped from compressed messages
+// (RFC 7692 Section 7.2.1).
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff}
+
+// ErrWebSocketClosed is returned when writing after the close handshake started.
+var ErrWebSocketClosed = errors.New("marten: websocket closed")
+
+// CloseError is returned by ReadMessage when the connection was closed,
+// either by the client or because the client violated the protocol. Code is
+// the close code received or sent.
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	if e.Text != "" {
+		return fmt.Sprintf("websocket: close %d: %s", e.Code, e.Text)
+	}
+	return fmt.Sprintf("websocket: close %d", e.Code)
+}
+
+// UpgradeOptions configures Ctx.Upgrade.
+type UpgradeOptions struct {
+	// Subprotocols lists supported subprotocols in preference order. The first
+	// one also offered by the client is selected (optional).
+	Subprotocols []string
+	// CheckOrigin reports whether the request's Origin is allowed (default:
+	// allow requests without Origin, or whose Origin host matches Ctx.Host).
+	CheckOrigin func(r *http.Request) bool
+	// ReadLimit is the maximum size of a message in bytes (default: 32MB).
+	// Larger messages close the connection with 1009.
+	ReadLimit int64
+	// Compression negotiates permessage-deflate when the client offers it.
+	Compression bool
+}
+
+// WebSocket is a server-side WebSocket connection. One goroutine may read
+// while others write; writes are serialized. The connection outlives the
+// handler, so it must be closed with Close or CloseWithCode.
+type WebSocket struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	app         *App
+	subprotocol string
+	compress    bool
+	readLimit   int64
+
+	msgMu     sync.Mutex // held while a message is written
+	frameMu   sync.Mutex // held while a frame is written
+	closeSent bool       // guarded by frameMu
+
+	reading   atomic.Bool
+	readErr   error
+	closeRecv chan struct{}
+	recvOnce  sync.Once
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	pingHandler func(data []byte) error
+	pongHandler func(data []byte) error
+}
+
+// Upgrade performs the WebSocket handshake (RFC 6455) and takes over the
+// connection. On failure it writes an error response (400, 403 or 426) and
+// returns the error, which the handler can return as-is.
+//
+//	ws, err := c.Upgrade(marten.UpgradeOptions{Subprotocols: []string{"chat"}})
+//	if err != nil {
+//		return err
+//	}
+//	defer ws.Close()
+//	for {
+//		typ, msg, err := ws.ReadMessage()
+//		if err != nil {
+//			return nil
+//		}
+//		if err := ws.WriteMessage(typ, msg); err != nil {
+//			return err
+//		}
+//	}
+func (c *Ctx) Upgrade(opts UpgradeOptions) (*WebSocket, error) {
+	c.checkLive()
+	r := c.Request
+	if r.Method != http.MethodGet || !c.IsWebSocket() {
+		return nil, c.upgradeError(http.StatusBadRequest, "not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		c.Writer.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, c.upgradeError(http.StatusUpgradeRequired, "unsupported websocket version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if b, err := base64.StdEncoding.DecodeString(key); err != nil || len(b) != 16 {
+		return nil, c.upgradeError(http.StatusBadRequest, "invalid Sec-WebSocket-Key")
+	}
+	checkOrigin := opts.CheckOrigin
+	if checkOrigin == nil {
+		host := c.Host()
+		checkOrigin = func(r *http.Request) bool { return sameOrigin(r, host) }
+	}
+	if !checkOrigin(r) {
+		return nil, c.upgradeError(http.StatusForbidden, "origin not allowed")
+	}
+
+	subprotocol := selectSubprotocol(r.Header, opts.Subprotocols)
+	compress := opts.Compression && offersDeflate(r.Header)
+
+	conn, brw, err := http.NewResponseController(c.Writer).Hijack()
+	if err != nil {
+		_ = c.Error(http.StatusInternalServerError, "websocket upgrade failed")
+		return nil, fmt.Errorf("marten: websocket upgrade: %w", err)
+	}
+	// Clear deadlines set by http.Server; the connection is ours now
+	_ = conn.SetDeadline(time.Time{})
+
+	var b strings.Builder
+	b.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	b.WriteString("Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n")
+	if subprotocol != "" {
+		b.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	if compress {
+		b.WriteString("Sec-WebSocket-Extensions: permessage-deflate; server_no_context_takeover; client_no_context_takeover\r\n")
+	}
+	b.WriteString("\r\n")
+	if _, err := conn.Write([]byte(b.String())); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c.written = true
+	c.statusCode = http.StatusSwitchingProtocols
+
+	ws := &WebSocket{
+		conn:        conn,
+		br:          brw.Reader,
+		app:         c.app,
+		subprotocol: subprotocol,
+		compress:    compress,
+		readLimit:   opts.ReadLimit,
+		closeRecv:   make(chan struct{}),
+		closed:      make(chan struct{}),
+	}
+	if ws.readLimit <= 0 {
+		ws.readLimit = defaultReadLimit
+	}
+	if c.app != nil {
+		c.app.trackWebSocket(ws)
+	}
+	return ws, nil
+}
+
+// IsWebSocket reports whether the request asks for a WebSocket upgrade.
+func (c *Ctx) IsWebSocket() bool {
+	c.checkLive()
+	return headerHasToken(c.Request.Header, "Connection", "upgrade") &&
+		headerHasToken(c.Request.Header, "Upgrade", "websocket")
+}
+
+func (c *Ctx) upgradeError(code int, message string) error {
+	_ = c.Error(code, message)
+	return NewHTTPError(code, message)
+}
+
+// Subprotocol returns the negotiated subprotocol, or "".
+func (ws *WebSocket) Subprotocol() string {
+	return ws.subprotocol
+}
+
+// Compressed reports whether permessage-deflate was negotiated.
+func (ws *WebSocket) Compressed() bool {
+	return ws.compress
+}
+
+// RemoteAddr returns the client's network address.
+func (ws *WebSocket) RemoteAddr() net.Addr {
+	return ws.conn.RemoteAddr()
+}
+
+// SetReadLimit sets the maximum message size in bytes.
+func (ws *WebSocket) SetReadLimit(limit int64) {
+	ws.readLimit = limit
+}
+
+// SetReadDeadline sets the deadline for reading the next message.
+func (ws *WebSocket) SetReadDeadline(t time.Time) error {
+	return ws.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the deadline for writes.
+func (ws *WebSocket) SetWriteDeadline(t time.Time) error {
+	return ws.conn.SetWriteDeadline(t)
+}
+
+// SetPingHandler sets the function called for ping frames received while
+// reading. The default replies with a pong carrying the same data.
+func (ws *WebSocket) SetPingHandler(fn func(data []byte) error) {
+	ws.pingHandler = fn
+}
+
+// SetPongHandler sets the function called for pong frames received while
+// reading (default: none).
+func (ws *WebSocket) SetPongHandler(fn func(data []byte) error) {
+	ws.pongHandler = fn
+}
+
+// Ping sends a ping frame. data may be at most 125 bytes.
+func (ws *WebSocket) Ping(data []byte) error {
+	return ws.writeControl(PingMessage, data)
+}
+
+// ReadMessage reads the next text or binary message, reassembling
+// fragments and answering pings. It returns a *CloseError once the client
+// closes the connection. Only one goroutine may read at a time.
+func (ws *WebSocket) ReadMessage() (messageType int, data []byte, err error) {
+	if !ws.reading.CompareAndSwap(false, true) {
+		return 0, nil, errors.New("marten: concurrent websocket read")
+	}
+	defer ws.reading.Store(false)
+	if ws.readErr != nil {
+		return 0, nil, ws.readErr
+	}
+
+	compressed := false
+	for {
+		f, err := ws.readFrame(ws.readLimit - int64(len(data)))
+		if err != nil {
+			return 0, nil, ws.readFailed(err)
+		}
+
+		switch f.opcode {
+		case PingMessage:
+			if ws.pingHandler != nil {
+				err = ws.pingHandler(f.payload)
+			} else {
+				err = ws.writeControl(PongMessage, f.payload)
+			}
+			if err != nil && !errors.Is(err, ErrWebSocketClosed) {
+				return 0, nil, ws.readFailed(err)
+			}
+			continue
+		case PongMessage:
+			if ws.pongHandler != nil {
+				if err := ws.pongHandler(f.payload); err != nil {
+					return 0, nil, ws.readFailed(err)
+				}
+			}
+			continue
+		case CloseMessage:
+			return 0, nil, ws.readFailed(ws.handleClose(f.payload))
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, ws.readFailed(ws.fail(CloseProtocolError, "unexpected continuation frame"))
+			}
+			if f.rsv1 {
+				return 0, nil, ws.readFailed(ws.fail(CloseProtocolError, "reserved bits set"))
+			}
+		default:
+			if messageType != 0 {
+				return 0, nil, ws.readFailed(ws.fail(CloseProtocolError, "expected continuation frame"))
+			}
+			messageType = f.opcode
+			compressed = f.rsv1
+		}
+
+		data = append(data, f.payload...)
+		if !f.fin {
+			continue
+		}
+		if compressed {
+			if data, err = ws.inflate(data); err != nil {
+				return 0, nil, ws.readFailed(err)
+			}
+		}
+		if messageType == TextMessage && !utf8.Valid(data) {
+			return 0, nil, ws.readFailed(ws.fail(CloseInvalidPayload, "invalid UTF-8"))
+		}
+		if data == nil {
+			data = []byte{}
+		}
+		return messageType, data, nil
+	}
+}
+
+// WriteMessage sends a text or binary message as a single frame.
+func (ws *WebSocket) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("marten: invalid websocket message type %d", messageType)
+	}
+	ws.msgMu.Lock()
+	defer ws.msgMu.Unlock()
+
+	rsv1 := false
+	if ws.compress {
+		var buf bytes.Buffer
+		fw := getFlateWriter(&buf)
+		_, _ = fw.Write(data)
+		err := fw.Flush()
+		flateWriterPool.Put(fw)
+		if err != nil {
+			return err
+		}
+		data, rsv1 = bytes.TrimSuffix(buf.Bytes(), deflateTail), true
+	}
+	return ws.writeFrame(true, rsv1, messageType, data)
+}
+
+// NextWriter returns a writer for a fragmented message: each Write sends a
+// frame, and Close ends the message. Other messages wait until Close.
+func (ws *WebSocket) NextWriter(messageType int) (io.WriteCloser, error) {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return nil, fmt.Errorf("marten: invalid websocket message type %d", messageType)
+	}
+	ws.msgMu.Lock()
+	w := &messageWriter{ws: ws, opcode: messageType}
+	if ws.compress {
+		w.rsv1 = true
+		w.fw = getFlateWriter(deflateSink{w})
+	}
+	return w, nil
+}
+
+// Close performs the close handshake with 1000 Normal Closure.
+func (ws *WebSocket) Close() error {
+	return ws.CloseWithCode(CloseNormal, "")
+}
+
+// CloseWithCode sends a close frame with code and reason, waits up to five
+// seconds for the client's close frame, and closes the connection.
+func (ws *WebSocket) CloseWithCode(code int, reason string) error {
+	if err := ws.sendClose(code, reason); err != nil && !errors.Is(err, ErrWebSocketClosed) {
+		ws.closeConn()
+		return err
+	}
+
+	if ws.reading.CompareAndSwap(false, true) {
+		// No active reader: read until the client's close frame
+		_ = ws.conn.SetReadDeadline(time.Now().Add(closeTimeout))
+		for {
+			f, err := ws.readFrame(ws.readLimit)
+			if err != nil || f.opcode == CloseMessage {
+				break
+			}
+		}
+		ws.reading.Store(false)
+	} else {
+		timer := time.NewTimer(closeTimeout)
+		defer timer.Stop()
+		select {
+		case <-ws.closeRecv:
+		case <-ws.closed:
+		case <-timer.C:
+		}
+	}
+	return ws.closeConn()
+}
+
+// wsFrame is a single frame read from the client.
+type wsFrame struct {
+	fin     bool
+	rsv1    bool
+	opcode  int
+	payload []byte
+}
+
+// readFrame reads and unmasks a frame. Data frames larger than limit fail
+// the connection with 1009.
+func (ws *WebSocket) readFrame(limit int64) (wsFrame, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(ws.br, hdr[:]); err != nil {
+		return wsFrame{}, err
+	}
+	f := wsFrame{fin: hdr[0]&0x80 != 0, rsv1: hdr[0]&0x40 != 0, opcode: int(hdr[0] & 0x0f)}
+	if hdr[0]&0x30 != 0 || f.rsv1 && !ws.compress {
+		return f, ws.fail(CloseProtocolError, "reserved bits set")
+	}
+	if hdr[1]&0x80 == 0 {
+		return f, ws.fail(CloseProtocolError, "client frames must be masked")
+	}
+
+	length := int64(hdr[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.br, ext[:]); err != nil {
+			return f, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.br, ext[:]); err != nil {
+			return f, err
+		}
+		n := binary.BigEndian.Uint64(ext[:])
+		if n>>63 != 0 {
+			return f, ws.fail(CloseProtocolError, "invalid frame length")
+		}
+		length = int64(n)
+	}
+
+	switch f.opcode {
+	case continuationFrame, TextMessage, BinaryMessage:
+		if length > limit {
+			return f, ws.fail(CloseMessageTooBig, "message too big")
+		}
+	case CloseMessage, PingMessage, PongMessage:
+		if length > maxControlPayload || !f.fin || f.rsv1 {
+			return f, ws.fail(CloseProtocolError, "invalid control frame")
+		}
+	default:
+		return f, ws.fail(CloseProtocolError, "reserved opcode")
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.br, mask[:]); err != nil {
+		return f, err
+	}
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(ws.br, f.payload); err != nil {
+		return f, err
+	}
+	for i := range f.payload {
+		f.payload[i] ^= mask[i&3]
+	}
+	return f, nil
+}
+
+// handleClose answers a close frame from the client and closes the connection.
+func (ws *WebSocket) handleClose(payload []byte) error {
+	code, text := CloseNoStatus, ""
+	switch {
+	case len(payload) == 1:
+		return ws.fail(CloseProtocolError, "invalid close payload")
+	case len(payload) >= 2:
+		code, text = int(binary.BigEndian.Uint16(payload)), string(payload[2:])
+		if !validCloseCode(code) {
+			return ws.fail(CloseProtocolError, "invalid close code")
+		}
+		if !utf8.ValidString(text) {
+			return ws.fail(CloseInvalidPayload, "invalid close reason")
+		}
+	}
+	ws.recvOnce.Do(func() { close(ws.closeRecv) })
+	_ = ws.sendClose(code, "")
+	ws.closeConn()
+	return &CloseError{Code: code, Text: text}
+}
+
+// fail sends a close frame for a protocol violation and closes the connection.
+func (ws *WebSocket) fail(code int, text string) error {
+	_ = ws.sendClose(code, text)
+	ws.closeConn()
+	return &CloseError{Code: code, Text: text}
+}
+
+// readFailed makes err sticky for later reads.
+func (ws *WebSocket) readFailed(err error) error {
+	if errors.Is(err, net.ErrClosed) {
+		err = ErrWebSocketClosed
+	}
+	ws.readErr = err
+	return err
+}
+
+func (ws *WebSocket) inflate(data []byte) ([]byte, error) {
+	// Restore the stripped sync marker and add an empty final block
+	src := io.MultiReader(bytes.NewReader(data), bytes.NewReader(deflateTail),
+		bytes.NewReader([]byte{0x01, 0x00, 0x00, 0xff, 0xff}))
+	fr := flate.NewReader(src)
+	defer fr.Close()
+	out, err := io.ReadAll(io.LimitReader(fr, ws.readLimit+1))
+	if err != nil {
+		return nil, ws.fail(CloseInvalidPayload, "invalid compressed data")
+	}
+	if int64(len(out)) > ws.readLimit {
+		return nil, ws.fail(CloseMessageTooBig, "message too big")
+	}
+	return out, nil
+}
+
+func (ws *WebSocket) writeControl(opcode int, data []byte) error {
+	if len(data) > maxControlPayload {
+		return fmt.Errorf("marten: websocket control frame payload exceeds %d bytes", maxControlPayload)
+	}
+	return ws.writeFrame(true, false, opcode, data)
+}
+
+func (ws *WebSocket) sendClose(code int, reason string) error {
+	var payload []byte
+	if code != CloseNoStatus {
+		for len(reason) > maxControlPayload-2 {
+			_, size := utf8.DecodeLastRuneInString(reason)
+			reason = reason[:len(reason)-size]
+		}
+		payload = binary.BigEndian.AppendUint16(nil, uint16(code))
+		payload = append(payload, reason...)
+	}
+	return ws.writeFrame(true, false, CloseMessage, payload)
+}
+
+// writeFrame writes a single unmasked frame. Nothing may follow a close frame.
+func (ws *WebSocket) writeFrame(fin, rsv1 bool, opcode int, payload []byte) error {
+	ws.frameMu.Lock()
+	defer ws.frameMu.Unlock()
+	if ws.closeSent {
+		return ErrWebSocketClosed
+	}
+	if opcode == CloseMessage {
+		ws.closeSent = true
+	}
+
+	b0 := byte(opcode)
+	if fin {
+		b0 |= 0x80
+	}
+	if rsv1 {
+		b0 |= 0x40
+	}
+	hdr := make([]byte, 2, 10)
+	hdr[0] = b0
+	switch n := len(payload); {
+	case n <= 125:
+		hdr[1] = byte(n)
+	case n <= 0xffff:
+		hdr[1] = 126
+		hdr = binary.BigEndian.AppendUint16(hdr, uint16(n))
+	default:
+		hdr[1] = 127
+		hdr = binary.BigEndian.AppendUint64(hdr, uint64(n))
+	}
+	bufs := net.Buffers{hdr, payload}
+	_, err := bufs.WriteTo(ws.conn)
+	return err
+}
+
+func (ws *WebSocket) closeConn() error {
+	var err error
+	ws.closeOnce.Do(func() {
+		close(ws.closed)
+		err = ws.conn.Close()
+		if ws.app != nil {
+			ws.app.untrackWebSocket(ws)
+		}
+	})
+	return err
+}
+
+// messageWriter writes a message as a sequence of frames.
+type messageWriter struct {
+	ws      *WebSocket
+	opcode  int  // opcode of the next frame: the message type, then continuation
+	rsv1    bool // set on the first frame of a compressed message
+	fw      *flate.Writer
+	pending []byte // compressed bytes not yet sent
+	closed  bool
+}
+
+func (w *messageWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, ErrWebSocketClosed
+	}
+	if w.fw != nil {
+		return w.fw.Write(p)
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if err := w.frame(false, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *messageWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	defer w.ws.msgMu.Unlock()
+	if w.fw != nil {
+		err := w.fw.Flush()
+		flateWriterPool.Put(w.fw)
+		if err != nil {
+			return err
+		}
+		return w.frame(true, bytes.TrimSuffix(w.pending, deflateTail))
+	}
+	return w.frame(true, nil)
+}
+
+func (w *messageWriter) frame(fin bool, p []byte) error {
+	err := w.ws.writeFrame(fin, w.rsv1, w.opcode, p)
+	w.opcode, w.rsv1 = continuationFrame, false
+	return err
+}
+
+// deflateSink collects compressed output, sending full frames as they fill.
+// The last four bytes are held back so the sync marker can be stripped.
+type deflateSink struct{ w *messageWriter }
+
+func (s deflateSink) Write(p []byte) (int, error) {
+	w := s.w
+	w.pending = append(w.pending, p...)
+	if n := len(w.pending) - len(deflateTail); n >= deflateFrameLength {
+		if err := w.frame(false, w.pending[:n]); err != nil {
+			return 0, err
+		}
+		w.pending = append(w.pending[:0], w.pending[n:]...)
+	}
+	return len(p), nil
+}
+
+var flateWriterPool sync.Pool
+
+func getFlateWriter(w io.Writer) *flate.Writer {
+	if fw, ok := flateWriterPool.Get().(*flate.Writer); ok {
+		fw.Reset(w)
+		return fw
+	}
+	fw, _ := flate.NewWriter(w, flate.BestSpeed)
+	return fw
+}
+
+// trackWebSocket registers an open connection for CloseWebSockets.
+func (a *App) trackWebSocket(ws *WebSocket) {
+	a.wsMu.Lock()
+	defer a.wsMu.Unlock()
+	if a.websockets == nil {
+		a.websockets = make(map[*WebSocket]struct{})
+	}
+	a.websockets[ws] = struct{}{}
+}
+
+func (a *App) untrackWebSocket(ws *WebSocket) {
+	a.wsMu.Lock()
+	defer a.wsMu.Unlock()
+	delete(a.websockets, ws)
+}
+
+// CloseWebSockets sends 1001 Going Away to every open WebSocket and waits
+// for the close handshakes. Connections still open when ctx is done are
+// closed forcibly. RunGraceful calls it on shutdown.
+func (a *App) CloseWebSockets(ctx context.Context) error {
+	a.wsMu.Lock()
+	conns := make([]*WebSocket, 0, len(a.websockets))
+	for ws := range a.websockets {
+		conns = append(conns, ws)
+	}
+	a.wsMu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, ws := range conns {
+		wg.Add(1)
+		go func(ws *WebSocket) {
+			defer wg.Done()
+			_ = ws.CloseWithCode(CloseGoingAway, "server shutting down")
+		}(ws)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		for _, ws := range conns {
+			ws.closeConn()
+		}
+		return ctx.Err()
+	}
+}
+
+// acceptKey computes Sec-WebSocket-Accept for a client key.
+func acceptKey(key string) string {
+	h := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// sameOrigin allows requests without Origin or whose Origin host matches host.
+func sameOrigin(r *http.Request, host string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, host)
+}
+
+// headerTokens returns the comma-separated tokens of all values of a header.
+func headerTokens(h http.Header, name string) []string {
+	var tokens []string
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				tokens = append(tokens, t)
+			}
+		}
+	}
+	return tokens
+}
+
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, t := range headerTokens(h, name) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectSubprotocol picks the first server subprotocol offered by the client.
+func selectSubprotocol(h http.Header, supported []string) string {
+	offered := headerTokens(h, "Sec-WebSocket-Protocol")
+	for _, s := range supported {
+		for _, o := range offered {
+			if s == o {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// offersDeflate reports whether the client offered permessage-deflate with
+// parameters the server can accept. Messages are compressed without context
+// takeover, so any client window size works; the server always uses 15 bits.
+func offersDeflate(h http.Header) bool {
+	for _, ext := range headerTokens(h, "Sec-WebSocket-Extensions") {
+		params := strings.Split(ext, ";")
+		if strings.TrimSpace(params[0]) != "permessage-deflate" {
+			continue
+		}
+		ok := true
+		for _, p := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(p), "=")
+			switch strings.TrimSpace(name) {
+			case "server_no_context_takeover", "client_no_context_takeover", "client_max_window_bits":
+			case "server_max_window_bits":
+				ok = ok && strings.Trim(strings.TrimSpace(value), `"`) == "15"
+			default:
+				ok = false
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// validCloseCode reports whether code may appear in a close frame.
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1014:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}